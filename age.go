@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeWindow 是允许处理的修改时间范围 [after, before)，零值的一端不限制
+type timeWindow struct {
+	after  time.Time
+	before time.Time
+}
+
+// active 判断是否设置了任何时间条件
+func (w timeWindow) active() bool {
+	return !w.after.IsZero() || !w.before.IsZero()
+}
+
+// contains 判断修改时间是否在范围内
+func (w timeWindow) contains(t time.Time) bool {
+	if !w.after.IsZero() && t.Before(w.after) {
+		return false
+	}
+	if !w.before.IsZero() && !t.Before(w.before) {
+		return false
+	}
+	return true
+}
+
+// String 以可读形式描述范围
+func (w timeWindow) String() string {
+	const layout = "2006-01-02 15:04:05"
+	switch {
+	case w.after.IsZero():
+		return "早于 " + w.before.Format(layout)
+	case w.before.IsZero():
+		return "不早于 " + w.after.Format(layout)
+	}
+	return "不早于 " + w.after.Format(layout) + "，早于 " + w.before.Format(layout)
+}
+
+// buildTimeWindow 把 --since/--before 的绝对时间和 --newer-than/--older-than 的
+// 相对时长（相对于 now）合成一个范围。四者同时生效：下限取 --since 与
+// now-newer-than 中较晚者，上限取 --before 与 now-older-than 中较早者；
+// 合成后为空的范围是参数错误。
+func buildTimeWindow(since, before, newerThan, olderThan string, now time.Time) (timeWindow, error) {
+	var w timeWindow
+	bounds := []struct {
+		flag, value string
+		lower       bool
+		parse       func(string) (time.Time, error)
+	}{
+		{"--since", since, true, parseTimeFlag},
+		{"--before", before, false, parseTimeFlag},
+		{"--newer-than", newerThan, true, func(s string) (time.Time, error) { return ageBefore(s, now) }},
+		{"--older-than", olderThan, false, func(s string) (time.Time, error) { return ageBefore(s, now) }},
+	}
+	for _, b := range bounds {
+		if b.value == "" {
+			continue
+		}
+		t, err := b.parse(b.value)
+		if err != nil {
+			return w, fmt.Errorf("无效的 %s: %w", b.flag, err)
+		}
+		switch {
+		case b.lower && (w.after.IsZero() || t.After(w.after)):
+			w.after = t
+		case !b.lower && (w.before.IsZero() || t.Before(w.before)):
+			w.before = t
+		}
+	}
+
+	if !w.after.IsZero() && !w.before.IsZero() && !w.after.Before(w.before) {
+		return w, fmt.Errorf("修改时间范围为空：%s 的文件不存在", w)
+	}
+	return w, nil
+}
+
+// timeLayouts 是 --since/--before 接受的时间格式，不带时区的按本地时间解释
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseTimeFlag 解析绝对时间
+func parseTimeFlag(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q 不是有效的时间（例如 2024-01-31 或 2024-01-31T08:00:00+08:00）", s)
+}
+
+// ageBefore 返回 now 之前 s 所表示时长的时间点
+func ageBefore(s string, now time.Time) (time.Time, error) {
+	d, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-d), nil
+}
+
+// parseAge 解析时长；在 time.ParseDuration 的基础上支持 d（天）和 w（周），如 30d、2w、1d12h
+func parseAge(s string) (time.Duration, error) {
+	var total time.Duration
+	rest := s
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if i := strings.Index(rest, unit.suffix); i >= 0 {
+			n, err := strconv.ParseFloat(rest[:i], 64)
+			if err != nil {
+				return 0, fmt.Errorf("%q 不是有效的时长（例如 90m、36h、30d、2w）", s)
+			}
+			total += time.Duration(n * float64(unit.size))
+			rest = rest[i+1:]
+		}
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("%q 不是有效的时长（例如 90m、36h、30d、2w）", s)
+		}
+		total += d
+	}
+	if total < 0 {
+		return 0, fmt.Errorf("时长 %q 不能为负数", s)
+	}
+	return total, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 注释输出格式
+const (
+	AnnotateNone   = ""
+	AnnotateGitHub = "github"
+)
+
+// GitHub Actions 每个步骤最多显示的警告注释数，超出的部分会被丢弃
+const githubAnnotationLimit = 10
+
+// githubAnnotator 把每处匹配输出为 GitHub Actions 的 ::warning 工作流命令，
+// 使匹配以行内注释的形式显示在拉取请求上
+type githubAnnotator struct {
+	silentReporter
+	w    io.Writer
+	root string // 注释中的路径相对于此目录（仓库根目录）
+
+	mu          sync.Mutex
+	annotations []githubAnnotation
+}
+
+type githubAnnotation struct {
+	path string
+	line int
+	col  int
+}
+
+// newGitHubAnnotator 创建 GitHub Actions 注释输出，路径相对于 GITHUB_WORKSPACE
+func newGitHubAnnotator(w io.Writer) *githubAnnotator {
+	root := os.Getenv("GITHUB_WORKSPACE")
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	return &githubAnnotator{w: w, root: root}
+}
+
+func (r *githubAnnotator) FileMatched(ev FileEvent) {
+	path := ev.Path
+	if rel, err := filepath.Rel(r.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = filepath.ToSlash(rel)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, lm := range firstMatchLines(ev.Preview, maxPreviewLines) {
+		for _, m := range lm.Matches {
+			col := utf8.RuneCountInString(lm.Line[:m.Start]) + 1
+			r.annotations = append(r.annotations, githubAnnotation{path: path, line: lm.LineNo, col: col})
+		}
+	}
+}
+
+func (r *githubAnnotator) FileReplaced(ev FileEvent) { r.FileMatched(ev) }
+
+func (r *githubAnnotator) Summary(config *Config, result *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.annotations, func(i, j int) bool {
+		a, b := r.annotations[i], r.annotations[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.col < b.col
+	})
+
+	// Leave room for the truncation notice within the per-step limit
+	shown := r.annotations
+	if len(shown) > githubAnnotationLimit {
+		shown = shown[:githubAnnotationLimit-1]
+	}
+
+	message := githubData(fmt.Sprintf("仍包含源字符串 '%s'", config.SourceString))
+	for _, a := range shown {
+		fmt.Fprintf(r.w, "::warning file=%s,line=%d,col=%d::%s\n", githubProperty(a.path), a.line, a.col, message)
+	}
+	if rest := len(r.annotations) - len(shown); rest > 0 {
+		fmt.Fprintf(r.w, "::warning::%s\n", githubData(fmt.Sprintf("另有 %d 处匹配未作为注释显示（共 %d 处）", rest, len(r.annotations))))
+	}
+}
+
+// githubData 转义工作流命令的消息部分
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty 转义工作流命令的属性值
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
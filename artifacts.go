@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// artifactSet 记录本次运行自身生成的输出文件和目录（报告、日志、备份目录等）。
+// 这些路径可能位于源目录之内，遍历时必须排除，以免读取或改写自己的输出。
+type artifactSet struct {
+	mu    sync.RWMutex
+	files map[string]bool
+	dirs  []string
+}
+
+// addFile 登记一个输出文件
+func (a *artifactSet) addFile(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.files == nil {
+		a.files = make(map[string]bool)
+	}
+	a.files[filepath.Clean(abs)] = true
+}
+
+// addDir 登记一个输出目录，其下整个子树都会被排除
+func (a *artifactSet) addDir(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dirs = append(a.dirs, filepath.Clean(abs))
+}
+
+// contains 判断路径是否是已登记的输出文件，或位于已登记的输出目录之下
+func (a *artifactSet) contains(path string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	path = filepath.Clean(path)
+	if a.files[path] {
+		return true
+	}
+
+	for _, dir := range a.dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
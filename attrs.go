@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// attrFS 是能修改文件属性的 FileSystem，osFS 实现了它。
+// 其他实现（如内存文件系统）没有这些属性，替换时不保留。
+type attrFS interface {
+	Chmod(name string, mode fs.FileMode) error
+	Lchown(name string, uid, gid int) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+func (osFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (osFS) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// preservedModeBits 是带到新文件上的权限位，包括 setuid、setgid 和 sticky
+const preservedModeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// copyAttrs 在重命名之前把原文件的所有者、权限和（opts.keepTimes 时）访问及修改时间
+// 应用到将要替换它的临时文件上。临时文件以当前用户身份、0600 权限创建，
+// 不处理的话可执行脚本会丢掉 x 位，文件的属组也会改变。
+// 只有 root 或属组成员才能改变所有者，失败时只通过 ownerErr 报告，不影响替换。
+func copyAttrs(fsys FileSystem, tempPath string, orig fs.FileInfo, opts writeOptions) (ownerErr, err error) {
+	afs, ok := fsys.(attrFS)
+	if !ok {
+		return nil, nil
+	}
+
+	// Changing the owner clears setuid/setgid, so it goes before chmod
+	if uid, gid, ok := fileOwnership(orig); ok {
+		if temp, err := fsys.Lstat(tempPath); err == nil {
+			if tuid, tgid, ok := fileOwnership(temp); ok && (tuid != uid || tgid != gid) {
+				ownerErr = afs.Lchown(tempPath, uid, gid)
+			}
+		}
+	}
+
+	if err := afs.Chmod(tempPath, orig.Mode()&preservedModeBits); err != nil {
+		return ownerErr, err
+	}
+	if opts.keepTimes {
+		atime := opts.atime
+		if atime.IsZero() {
+			atime = accessTime(orig)
+		}
+		if err := afs.Chtimes(tempPath, atime, orig.ModTime()); err != nil {
+			return ownerErr, err
+		}
+	}
+	return ownerErr, nil
+}
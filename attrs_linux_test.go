@@ -0,0 +1,71 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 可执行文件和 setgid 等特殊位在替换后保留，--encoding 时同样如此
+func TestReplaceKeepsExecutable(t *testing.T) {
+	filesystems := map[string]FileSystem{
+		"os":       osFS{},
+		"encoding": encodingFS{FileSystem: osFS{}, enc: simplifiedchinese.GBK, name: "gbk"},
+	}
+	for name, fsys := range filesystems {
+		for _, mode := range []os.FileMode{0o755, 0o700, 0o755 | os.ModeSetgid} {
+			dir := t.TempDir()
+			path := writeTestFile(t, dir, "run.sh", "#!/bin/sh\necho old\n", mode)
+
+			if _, err := replaceTest(t, fsys, path, newLiteralMatcher("old", "new"), writeOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode() & preservedModeBits; got != mode {
+				t.Errorf("%s: 权限 %v 替换后变为 %v", name, mode, got)
+			}
+			if got := readTestFile(t, path); got != "#!/bin/sh\necho new\n" {
+				t.Errorf("%s: 内容 = %q", name, got)
+			}
+		}
+	}
+}
+
+// --keep-times 保留访问和修改时间，访问时间是检测和计数读取文件之前的值；
+// 不指定时修改时间为替换的时间
+func TestReplaceKeepTimes(t *testing.T) {
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, keepTimes := range []bool{true, false} {
+		dir := t.TempDir()
+		path := writeTestFile(t, dir, "a.txt", "old text\n", 0o644)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		runTest(t, &Config{SourceDir: dir, SourceString: "old", TargetString: "new", KeepTimes: keepTimes})
+
+		// Stat before reading the content back, which updates the access time
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readTestFile(t, path); got != "new text\n" {
+			t.Fatalf("内容 = %q", got)
+		}
+		if keepTimes {
+			if !info.ModTime().Equal(old) || !accessTime(info).Equal(old) {
+				t.Errorf("--keep-times: 修改时间 %v、访问时间 %v，应为 %v", info.ModTime(), accessTime(info), old)
+			}
+		} else if info.ModTime().Equal(old) {
+			t.Error("未指定 --keep-times 时修改时间不应保留")
+		}
+	}
+}
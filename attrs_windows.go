@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileOwnership 在 Windows 上不可用：所有者是 SID，新文件继承目录的 ACL
+func fileOwnership(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// accessTime 返回文件的访问时间，无法获取时返回修改时间
+func accessTime(info fs.FileInfo) time.Time {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(0, data.LastAccessTime.Nanoseconds())
+}
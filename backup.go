@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// defaultBackupSuffix 是 --backup 不带值时的备份文件后缀
+const defaultBackupSuffix = ".bak"
+
+// maxBackupNumber 是编号备份（.bak.1、.bak.2 ...）的上限
+const maxBackupNumber = 1000
+
+// writeBackup 在替换之前把原文件复制到同一目录中，保留权限和修改时间，
+// 返回备份文件路径；没有启用 --backup 时返回空字符串。
+// 已存在的备份不会被覆盖，依次改用 .bak.1、.bak.2 等编号后缀。
+func writeBackup(config *Config, path string) (string, error) {
+	if config.Backup == "" {
+		return "", nil
+	}
+
+	info, err := config.FS.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	src, err := config.FS.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	for n := 0; n <= maxBackupNumber; n++ {
+		name := path + config.Backup
+		if n > 0 {
+			name += "." + strconv.Itoa(n)
+		}
+		// Registered first so the walk never picks the copy up as input
+		config.artifacts.addFile(name)
+		dst, err := config.FS.CreateExcl(name, info.Mode().Perm())
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := copyBackup(config.FS, dst, src, info); err != nil {
+			config.FS.Remove(name)
+			return "", err
+		}
+		return name, nil
+	}
+	return "", errors.New("已有过多同名备份文件")
+}
+
+// copyBackup 写入备份内容，并在支持属性的文件系统上恢复原文件的权限
+// （不受 umask 影响）和修改时间
+func copyBackup(fsys FileSystem, dst TempFile, src io.Reader, info fs.FileInfo) error {
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	afs, ok := fsys.(attrFS)
+	if !ok {
+		return nil
+	}
+	if err := afs.Chmod(dst.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return afs.Chtimes(dst.Name(), info.ModTime(), info.ModTime())
+}
+
+// discardBackup 在替换没有发生时删除刚写入的备份
+func discardBackup(config *Config, name string) {
+	if name != "" {
+		config.FS.Remove(name)
+	}
+}
+
+// isBackupName 判断文件名是否是 --backup 写出的备份（带后缀或编号后缀）
+func isBackupName(name, suffix string) bool {
+	if suffix == "" {
+		return false
+	}
+	if strings.HasSuffix(name, suffix) {
+		return true
+	}
+	i := strings.LastIndex(name, suffix+".")
+	if i < 0 {
+		return false
+	}
+	_, err := strconv.Atoi(name[i+len(suffix)+1:])
+	return err == nil
+}
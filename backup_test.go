@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backupFS 记录经过 CreateExcl 和 Remove 的文件；failExcl 不为 nil 时 CreateExcl 失败，
+// failTemp 时创建临时文件失败
+type backupFS struct {
+	osFS
+	failExcl error
+	failTemp bool
+	created  []string
+	removed  []string
+}
+
+func (f *backupFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	if f.failTemp {
+		return nil, errInjected
+	}
+	return f.osFS.CreateTemp(dir, pattern)
+}
+
+func (f *backupFS) CreateExcl(name string, perm fs.FileMode) (TempFile, error) {
+	if f.failExcl != nil {
+		return nil, f.failExcl
+	}
+	f.created = append(f.created, filepath.Base(name))
+	return f.osFS.CreateExcl(name, perm)
+}
+
+func (f *backupFS) Remove(name string) error {
+	f.removed = append(f.removed, filepath.Base(name))
+	return f.osFS.Remove(name)
+}
+
+// 备份经过 config.FS 写出，保留原文件的内容、权限和修改时间；
+// 再次运行时已有的备份不被覆盖也不作为输入，新的备份使用编号后缀
+func TestBackupRun(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "foo one\n", 0o640)
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := &backupFS{}
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Backup: ".bak", FS: fsys})
+	if result.Backups != 1 || len(fsys.created) != 1 || fsys.created[0] != "a.txt.bak" {
+		t.Fatalf("备份 %d 个，经过文件系统创建 %v，应为 1 个 a.txt.bak", result.Backups, fsys.created)
+	}
+	backup := path + ".bak"
+	if got := readTestFile(t, backup); got != "foo one\n" {
+		t.Errorf("备份内容 %q", got)
+	}
+	info, err := os.Stat(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 || !info.ModTime().Equal(mtime) {
+		t.Errorf("备份的权限 %v、修改时间 %v，应为 0640 和 %v", info.Mode().Perm(), info.ModTime(), mtime)
+	}
+
+	writeTestFile(t, dir, "a.txt", "foo two\n", 0o640)
+	result = runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Backup: ".bak", FS: &backupFS{}})
+	if result.Skipped[SkipBackup] != 1 || result.FilesMatches != 1 {
+		t.Errorf("跳过备份 %d 个、修改 %d 个文件，应均为 1", result.Skipped[SkipBackup], result.FilesMatches)
+	}
+	if got := readTestFile(t, backup); got != "foo one\n" {
+		t.Errorf("已有的备份被改写为 %q", got)
+	}
+	if got := readTestFile(t, backup+".1"); got != "foo two\n" {
+		t.Errorf("编号备份的内容 %q", got)
+	}
+}
+
+// 无法创建备份时文件不被替换，记为错误
+func TestBackupCreateFails(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Backup: ".bak", FS: &backupFS{failExcl: errInjected}})
+	if result.Errors != 1 || result.Backups != 0 {
+		t.Errorf("错误 %d 个、备份 %d 个，应为 1 和 0", result.Errors, result.Backups)
+	}
+	if got := readTestFile(t, path); got != "foo\n" {
+		t.Errorf("内容 = %q，应保持不变", got)
+	}
+}
+
+// 替换失败时刚写入的备份经过 config.FS 删除
+func TestBackupDiscardedOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+
+	fsys := &backupFS{failTemp: true}
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Backup: ".bak", FS: fsys})
+	if result.Errors != 1 || result.Backups != 0 {
+		t.Errorf("错误 %d 个、备份 %d 个，应为 1 和 0", result.Errors, result.Backups)
+	}
+	if len(fsys.removed) != 1 || fsys.removed[0] != "a.txt.bak" {
+		t.Errorf("经过文件系统删除 %v，应为 a.txt.bak", fsys.removed)
+	}
+	if _, err := os.Lstat(path + ".bak"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("备份没有删除: %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
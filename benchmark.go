@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// benchRounds 是 benchmark 每种模式计时的轮数
+var benchRounds int
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark [路径...]",
+	Short: "比较顺序模式（--seq）与并行模式扫描同一目录树的耗时",
+	Long: `在同一目录树上交替以顺序模式和 --workers 个工人的并行模式只查找不替换，
+输出每种模式的最短和平均耗时，用来判断这块磁盘是否应该使用 --seq。
+计时之前先完整扫描一遍，使两种模式都在同样的页缓存状态下运行`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBenchmark(args)
+	},
+}
+
+func init() {
+	addMatchFlags(benchmarkCmd.Flags())
+	benchmarkCmd.Flags().IntVar(&benchRounds, "rounds", 3, "每种模式计时的轮数")
+
+	rootCmd.AddCommand(benchmarkCmd)
+}
+
+// benchMode 是 benchmark 比较的一种运行方式
+type benchMode struct {
+	name       string
+	sequential bool
+	workers    int
+	times      []time.Duration
+}
+
+// runBenchmark 执行 benchmark：以试验模式轮流运行顺序和并行扫描并计时
+func runBenchmark(args []string) error {
+	promptMissing(&cfg.SourceString, nil)
+	if cfg.SourceString == "" {
+		return configError("必须指定要查找的字符串（--from 参数）")
+	}
+	if cfg.Sequential {
+		return configError("benchmark 本身会比较顺序和并行模式，不能指定 --seq")
+	}
+	if benchRounds <= 0 {
+		return configError("--rounds 必须大于0")
+	}
+	if err := validateMatchFlags(); err != nil {
+		return err
+	}
+
+	// Same read-only scan as find: nothing is written whatever the mode
+	cfg.Trial = true
+	cfg.TargetString = cfg.SourceString
+	cfg.searchOnly = true
+	cfg.Reporter = silentReporter{}
+	if err := prepareRun(args); err != nil {
+		return err
+	}
+
+	modes := []*benchMode{
+		{name: "顺序 (--seq)", sequential: true, workers: 1},
+		{name: fmt.Sprintf("并行 (%d 个工人)", cfg.Workers), workers: cfg.Workers},
+	}
+
+	// The warm-up pass fills the page cache so neither mode profits from
+	// the other having read the tree first
+	if _, _, err := benchRun(modes[1]); err != nil {
+		return err
+	}
+	var files int32
+	for range benchRounds {
+		for _, mode := range modes {
+			result, elapsed, err := benchRun(mode)
+			if err != nil {
+				return err
+			}
+			mode.times = append(mode.times, elapsed)
+			files = result.FilesProcessed
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "扫描文件数: %d, 每种模式 %d 轮\n", files, benchRounds)
+	for _, mode := range modes {
+		best, total := mode.times[0], time.Duration(0)
+		for _, d := range mode.times {
+			best = min(best, d)
+			total += d
+		}
+		fmt.Fprintf(os.Stdout, "%s: 最短 %v, 平均 %v\n", mode.name, best.Round(time.Millisecond), (total / time.Duration(len(mode.times))).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// benchRun 以 mode 运行一次扫描，返回结果和耗时
+func benchRun(mode *benchMode) (*Result, time.Duration, error) {
+	cfg.Sequential = mode.sequential
+	cfg.Workers = mode.workers
+	// Run wraps the reporter (--fail-fast, --group-depth); start from the
+	// bare one every time so the wrappers do not pile up
+	cfg.Reporter = silentReporter{}
+
+	start := time.Now()
+	result, err := Run(&cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, time.Since(start), nil
+}
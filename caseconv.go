@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// caseTemplate 是 --regex 的替换文本按大小写转换转义拆成的若干段。
+// \U 和 \L 把之后的文本转为大写或小写，直到 \E 或另一个 \U、\L；
+// \u 和 \l 只转换紧随其后输出的第一个字符，在 \U、\L 之后生效，所以 \u\L$1 得到首字母大写。
+// 转换作用于展开后的文本，分组内容和字面文本一样转换；\\ 是一个反斜杠，
+// 其余的反斜杠原样保留。大小写映射与 --template-replace 的 upper、lower 相同，
+// 是 Unicode 的简单映射（ß 不变），\u 使用标题大小写（ǆ 变为 ǅ）。
+type caseTemplate []casePart
+
+// casePart 是一段待展开的文本，或者一个转义（op 为 U、L、u、l、E）
+type casePart struct {
+	text string
+	op   byte
+}
+
+// parseCaseTemplate 拆分替换文本；没有大小写转义和 \\ 时返回 nil，调用方直接展开
+func parseCaseTemplate(s string) caseTemplate {
+	var parts caseTemplate
+	var text strings.Builder
+	found := false
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			text.WriteByte(s[i])
+			continue
+		}
+		switch c := s[i+1]; c {
+		case 'U', 'L', 'u', 'l', 'E':
+			if text.Len() > 0 {
+				parts = append(parts, casePart{text: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, casePart{op: c})
+		case '\\':
+			text.WriteByte('\\')
+		default:
+			text.WriteByte('\\')
+			continue
+		}
+		found = true
+		i++
+	}
+	if !found {
+		return nil
+	}
+	if text.Len() > 0 {
+		parts = append(parts, casePart{text: text.String()})
+	}
+	return parts
+}
+
+// expand 展开 loc 处的匹配并按转义转换大小写
+func (t caseTemplate) expand(pattern *regexp.Regexp, line string, loc []int) string {
+	var sb strings.Builder
+	var mode, next byte
+	for _, p := range t {
+		switch p.op {
+		case 'U', 'L':
+			mode = p.op
+			continue
+		case 'E':
+			mode = 0
+			continue
+		case 'u', 'l':
+			next = p.op
+			continue
+		}
+
+		text := string(pattern.ExpandString(nil, p.text, line, loc))
+		switch mode {
+		case 'U':
+			text = strings.ToUpper(text)
+		case 'L':
+			text = strings.ToLower(text)
+		}
+		// A \u before an empty group waits for the next character
+		if next != 0 && text != "" {
+			if r, size := utf8.DecodeRuneInString(text); r != utf8.RuneError {
+				if next == 'u' {
+					r = unicode.ToTitle(r)
+				} else {
+					r = unicode.ToLower(r)
+				}
+				sb.WriteRune(r)
+				text = text[size:]
+			}
+			next = 0
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
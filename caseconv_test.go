@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// --regex 的替换文本中的 \U、\L、\u、\l、\E 作用于展开后的文本
+func TestCaseEscapes(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		template string
+		line     string
+		want     string
+	}{
+		{`(\w+)_id`, `\U$1\E_ID`, "user_id", "USER_ID"},
+		{`(\w+)_id`, `\U${1}_id`, "user_id", "USER_ID"}, // literal text is converted as well
+		{`(\w+)_id`, `\U$1\E_id`, "user_id", "USER_id"},
+		{`\w+`, `\L$0`, "HeLLo WoRLD", "hello world"},
+		{`\w+`, `\u$0`, "hello world", "Hello World"},
+		{`\w+`, `\l$0`, "Hello World", "hello world"},
+		{`\w+`, `\u\L$0`, "mCDONALD", "Mcdonald"},
+		{`\w+`, `\L\u$0`, "mCDONALD", "Mcdonald"},
+		{`\w+`, `\l\U$0`, "hello", "hELLO"},
+		{`(\w)(\w*)`, `\U$1\L$2`, "hELLO", "Hello"},
+		{`(\w+)`, `\U$1\L-$1\E-$1`, "Ab", "AB-ab-Ab"},
+		{`(x)?(\w+)`, `\u$1$2`, "abc", "Abc"}, // \u waits for the next character
+		{`(\w+)`, `\u`, "abc", ""},
+		{`(\w+)`, `a\Eb`, "x", "ab"},
+		{`(\w+)`, `${1}\u_$1`, "ab", "ab_ab"}, // _ has no case
+
+		// Backslashes
+		{`(\w+)`, `\\U$1`, "ab", `\Uab`},
+		{`(\w+)`, `\\\U$1`, "ab", `\AB`},
+		{`(\w+)`, `C:\dir\$1`, "ab", `C:\dir\ab`},
+		{`(\w+)`, `a\\\\b`, "x", `a\\b`},
+		{`(\w+)`, `\n$1\t`, "ab", `\nab\t`},
+		{`(\w+)`, `$1\`, "ab", `ab\`},
+		{`(\w+)`, `\U$$1`, "ab", "$1"},
+
+		// Unicode
+		{`(\pL+)`, `\U$1`, "éclair", "ÉCLAIR"},
+		{`(\pL+)`, `\u\L$1`, "ÉCOLE", "École"},
+		{`(\pL+)`, `\u$1`, "привет", "Привет"},
+		{`(\pL+)`, `\U$1`, "straße", "STRAßE"}, // simple mapping: ß has no single uppercase
+		{`(\pL+)`, `\u$1`, "ǆemal", "ǅemal"},   // title case, not upper case
+		{`(\pL+)`, `\L$1`, "ΣΟΦΙΑ", "σοφια"},
+		{`(\pL+)`, `\U$1`, "日本", "日本"},
+		{`(\S+)`, `\u$1`, "\xffab", "\xffab"}, // invalid UTF-8 is left alone
+	}
+	for _, tt := range tests {
+		pattern := regexp.MustCompile(tt.pattern)
+		if err := checkGroupRefs(pattern, tt.template); err != nil {
+			t.Errorf("%q: %v", tt.template, err)
+			continue
+		}
+		m := newRegexMatcher(pattern, tt.template, true)
+		if got := applyMatches(tt.line, m.FindAll(tt.line)); got != tt.want {
+			t.Errorf("%s → %s 作用于 %q = %q，应为 %q", tt.pattern, tt.template, tt.line, got, tt.want)
+		}
+	}
+}
+
+// 不展开替换文本时（--ignore-case、--ignore-whitespace）转义原样插入
+func TestCaseEscapesLiteral(t *testing.T) {
+	m := newRegexMatcher(regexp.MustCompile(`(?i)id`), `\U$1\\`, false)
+	if got := applyMatches("ID", m.FindAll("ID")); got != `\U$1\\` {
+		t.Errorf("替换后 %q，应原样插入", got)
+	}
+}
+
+// --map 的 regex 规则同样支持大小写转义
+func TestCaseEscapesMapRun(t *testing.T) {
+	dir := t.TempDir()
+	mapPath := writeTestFile(t, t.TempDir(), "rules.map", "(\\w+)_id\t\\U$1\\E_ID\tregex\n\"(\\\\w+)_key\"\t\"\\\\u${1}Key\"\tregex\n", 0o644)
+	path := writeTestFile(t, dir, "a.txt", "user_id api_key\n", 0o644)
+
+	rules, err := loadMap(mapPath, mapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runTest(t, &Config{SourceDir: dir, Map: mapPath, mapRules: rules})
+	if got := readTestFile(t, path); got != "USER_ID ApiKey\n" {
+		t.Errorf("替换后 %q", strings.TrimSpace(got))
+	}
+}
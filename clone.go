@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// cloneTarget 是以写时复制方式克隆了原文件的临时文件（--clone）。
+// 克隆后临时文件已经是原文件的完整副本，长度不变的替换直接覆盖写入对应的字节，
+// 其余数据块仍与原文件共享。遇到第一处改变长度的行时，从该行起截断临时文件，
+// 之后的内容照常流式写出。
+type cloneTarget struct {
+	file   *os.File
+	offset int64 // 当前行在文件中的字节位置
+	shared int64 // 未重新写入、仍与原文件共享的字节数
+	active bool  // 仍在给克隆打补丁，尚未切换为流式写入
+}
+
+// tryClone 把 in 克隆到 out；不是本地文件或文件系统不支持时返回 nil，调用方照常复制
+func tryClone(in io.ReadCloser, out TempFile) *cloneTarget {
+	src, ok := in.(*os.File)
+	if !ok {
+		return nil
+	}
+	dst, ok := osFile(out)
+	if !ok {
+		return nil
+	}
+	if err := cloneFile(dst, src); err != nil {
+		return nil
+	}
+	return &cloneTarget{file: dst, active: true}
+}
+
+// osFile 返回临时文件底层的 *os.File
+func osFile(t TempFile) (*os.File, bool) {
+	switch f := t.(type) {
+	case *os.File:
+		return f, true
+	case interface{ osFile() *os.File }:
+		return f.osFile(), true
+	}
+	return nil, false
+}
+
+// patch 把一行的替换直接写进克隆并返回 true。行内有改变长度的替换时
+// 截断克隆并切换为流式写入，返回 false，由调用方写出这一行及其后的内容。
+func (c *cloneTarget) patch(line []byte, matches []Match) (bool, error) {
+	if c == nil || !c.active {
+		return false, nil
+	}
+
+	for _, m := range matches {
+		if len(m.Replacement) != m.End-m.Start {
+			c.active = false
+			if err := c.file.Truncate(c.offset); err != nil {
+				return false, err
+			}
+			_, err := c.file.Seek(c.offset, io.SeekStart)
+			return false, err
+		}
+	}
+
+	c.shared += int64(len(line))
+	for _, m := range matches {
+		if _, err := c.file.WriteAt([]byte(m.Replacement), c.offset+int64(m.Start)); err != nil {
+			return false, err
+		}
+		c.shared -= int64(len(m.Replacement))
+	}
+	c.offset += int64(len(line))
+	return true, nil
+}
+
+// sharedBytes 返回没有重新写入的字节数，nil 时为 0
+func (c *cloneTarget) sharedBytes() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.shared
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile 用 FICLONE 让 dst 以写时复制方式共享 src 的全部数据块（Btrfs、XFS 等）；
+// 文件系统不支持或两者不在同一文件系统上时返回错误
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
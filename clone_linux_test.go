@@ -0,0 +1,161 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cloneDir 返回克隆测试使用的目录：设置了 RESTR_CLONE_DIR 时在其中（应位于 Btrfs 或 XFS 上）
+// 新建一个目录，否则为测试的临时目录。第二个返回值说明该文件系统是否支持 FICLONE。
+func cloneDir(tb testing.TB) (string, error) {
+	tb.Helper()
+	dir := tb.TempDir()
+	if root := os.Getenv("RESTR_CLONE_DIR"); root != "" {
+		var err error
+		if dir, err = os.MkdirTemp(root, "reStr-clone-"); err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() { os.RemoveAll(dir) })
+	}
+
+	src, err := os.Create(filepath.Join(dir, "probe-src"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+	if _, err := src.WriteString("probe\n"); err != nil {
+		tb.Fatal(err)
+	}
+	dst, err := os.Create(filepath.Join(dir, "probe-dst"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+	return dir, cloneFile(dst, src)
+}
+
+// cloneContent 生成约 size 字节的文本，每 every 行有一处 needle
+func cloneContent(size, every int) string {
+	var sb strings.Builder
+	sb.Grow(size + 128)
+	for i := 0; sb.Len() < size; i++ {
+		if i%every == every-1 {
+			fmt.Fprintf(&sb, "line %d: the needle is here\n", i)
+		} else {
+			fmt.Fprintf(&sb, "line %d: nothing to see here, just ordinary text\n", i)
+		}
+	}
+	return sb.String()
+}
+
+// --clone 与普通复制的结果相同；文件系统不支持克隆时透明地回退为复制
+func TestCloneMatchesCopy(t *testing.T) {
+	dir, _ := cloneDir(t)
+	content := cloneContent(1<<20, 1000)
+	tests := []struct {
+		name    string
+		matcher Matcher
+	}{
+		{"等长", newLiteralMatcher("needle", "pinpin")},
+		{"变短", newLiteralMatcher("needle", "pin")},
+		{"变长", newLiteralMatcher("needle", "haystack")},
+	}
+	for _, tt := range tests {
+		for _, clone := range []bool{false, true} {
+			path := writeTestFile(t, dir, "a.txt", content, 0o644)
+			rewrite, err := replaceTest(t, osFS{}, path, tt.matcher, writeOptions{clone: clone})
+			if err != nil {
+				t.Fatalf("%s（clone=%v）: %v", tt.name, clone, err)
+			}
+			if want := strings.Count(content, "needle"); rewrite.Replaced != want {
+				t.Errorf("%s（clone=%v）: 替换 %d 处，应为 %d 处", tt.name, clone, rewrite.Replaced, want)
+			}
+			m := tt.matcher.(*literalMatcher)
+			if got := readTestFile(t, path); got != strings.ReplaceAll(content, "needle", m.replace) {
+				t.Errorf("%s（clone=%v）: 替换后的内容不正确", tt.name, clone)
+			}
+			assertNoTempFiles(t, dir)
+		}
+	}
+}
+
+// 等长替换只写入被替换的字节，其余数据块仍与原文件共享；
+// 第一处改变长度的替换之后的内容照常写出
+func TestCloneWritesOnlyPatches(t *testing.T) {
+	dir, err := cloneDir(t)
+	if err != nil {
+		t.Skipf("%s 不支持 FICLONE，可用 RESTR_CLONE_DIR 指向 Btrfs 或 XFS 上的目录: %v", dir, err)
+	}
+	content := cloneContent(4<<20, 1000)
+	matches := strings.Count(content, "needle")
+
+	path := writeTestFile(t, dir, "a.txt", content, 0o644)
+	stats := &IOStats{}
+	if _, err := replaceInFile(osFS{}, path, "", newLiteralMatcher("needle", "pinpin"), 0, writeOptions{clone: true}, stats); err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(matches * len("pinpin")); stats.BytesWritten != want {
+		t.Errorf("等长替换写入 %d 字节，应为 %d 字节", stats.BytesWritten, want)
+	}
+
+	// Shortening from the middle on rewrites the second half only
+	half := strings.Index(content[len(content)/2:], "needle") + len(content)/2
+	content = content[:half] + strings.Replace(content[half:], "needle", "noodle", 1)
+	path = writeTestFile(t, dir, "b.txt", content, 0o644)
+	stats = &IOStats{}
+	if _, err := replaceInFile(osFS{}, path, "", newLiteralMatcher("noodle", "pin"), 0, writeOptions{clone: true}, stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.BytesWritten >= int64(len(content)) || stats.BytesWritten < int64(len(content)-half-4096) {
+		t.Errorf("从中间开始变短的替换写入 %d 字节，应约为 %d 字节", stats.BytesWritten, len(content)-half)
+	}
+	if got := readTestFile(t, path); got != strings.ReplaceAll(content, "noodle", "pin") {
+		t.Error("替换后的内容不正确")
+	}
+}
+
+// 64MB、每 10000 行一处等长替换的文件：普通复制与写时复制克隆的耗时和写入量。
+// 克隆只在支持 FICLONE 的文件系统上运行，例如
+// RESTR_CLONE_DIR=/mnt/btrfs go test -run '^$' -bench Clone
+func BenchmarkClone(b *testing.B) {
+	dir, cloneErr := cloneDir(b)
+	content := cloneContent(64<<20, 10000)
+	path := filepath.Join(dir, "large.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	// Alternating the two directions keeps the file the same size
+	// without writing the original back between iterations
+	matchers := []Matcher{newLiteralMatcher("needle", "pinpin"), newLiteralMatcher("pinpin", "needle")}
+
+	for _, clone := range []bool{false, true} {
+		name := "copy"
+		if clone {
+			name = "clone"
+		}
+		b.Run(name, func(b *testing.B) {
+			if clone && cloneErr != nil {
+				b.Skipf("%s 不支持 FICLONE: %v", dir, cloneErr)
+			}
+			b.SetBytes(int64(len(content)))
+			stats := &IOStats{}
+			i := 0
+			for b.Loop() {
+				_, err := replaceInFile(osFS{}, path, "", matchers[i%2], 0, writeOptions{clone: clone}, stats)
+				if err != nil && !errors.Is(err, errUnchanged) {
+					b.Fatal(err)
+				}
+				i++
+			}
+			b.ReportMetric(float64(stats.BytesWritten)/float64(i), "written-B/op")
+		})
+	}
+}
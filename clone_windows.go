@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile 在 Windows 上不支持，总是回退到复制
+func cloneFile(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
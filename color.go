@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 彩色输出模式
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ANSI 颜色，与 grep 的默认配色一致
+const (
+	ansiReset   = "\x1b[0m"
+	ansiMatch   = "\x1b[1;31m"
+	ansiReplace = "\x1b[1;32m"
+	ansiPath    = "\x1b[35m"
+	ansiLineNo  = "\x1b[32m"
+	ansiSep     = "\x1b[36m"
+)
+
+// colorEnabled 决定输出到 f 时是否使用颜色。
+// 设置了 NO_COLOR 或 --color=never 时总是不用颜色；auto 只在终端上使用。
+// Windows 控制台无法启用 ANSI 转义序列处理时回退为无颜色输出。
+func colorEnabled(mode string, f *os.File) bool {
+	if mode == ColorNever || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	terminal := isTerminal(f)
+	if mode == ColorAuto && (!terminal || os.Getenv("TERM") == "dumb") {
+		return false
+	}
+
+	// Redirected output with --color=always gets the sequences as is
+	return !terminal || enableVirtualTerminal(f)
+}
+
+// paint 在 on 时用颜色包裹文本
+func paint(s, color string, on bool) string {
+	if !on || s == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// formatHighlightColor 与 formatHighlight 相同，但用颜色代替方括号标记匹配。
+// 控制字符在着色之前转义，文件内容无法混入转义序列。
+func formatHighlightColor(line string, matches []Match) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(escapeControl(line[last:m.Start]))
+		fmt.Fprintf(&sb, "%s→%s", paint(escapeControl(line[m.Start:m.End]), ansiMatch, true), paint(escapeControl(m.Replacement), ansiReplace, true))
+		last = m.End
+	}
+	sb.WriteString(escapeControl(line[last:]))
+	return sb.String()
+}
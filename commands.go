@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+)
+
+// find 和 verify 的退出码
+const (
+	ExitNoMatches    = 1 // find 没有找到任何匹配
+	ExitStillPresent = 1 // verify 发现源字符串仍然存在
+	ExitFindError    = 2 // find 出错且没有找到匹配
+)
+
+var findOpts findOptions
+
+// annotate 是 verify 的 --annotate 选项
+var annotate string
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace [路径...]",
+	Short: "替换字符串（默认子命令）",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runApp(args)
+	},
+}
+
+var findCmd = &cobra.Command{
+	Use:   "find [路径...]",
+	Short: "只查找匹配，不做替换",
+	Long: `只查找匹配，不做替换，输出格式与 grep 相同（路径:行号:内容）。
+找到匹配时退出码为 0，没有找到为 1，出错且没有找到为 2`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFind(args, false)
+	},
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [路径...]",
+	Short: "确认源字符串已不存在",
+	Long:  "确认迁移完成：源字符串不再出现时退出码为 0，否则列出仍包含它的文件并以 1 退出",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFind(args, true)
+	},
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "撤销最近一次使用 --journal 的替换",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUndo()
+	},
+}
+
+func init() {
+	addMatchFlags(replaceCmd.Flags())
+	addReplaceFlags(replaceCmd.Flags())
+
+	addMatchFlags(findCmd.Flags())
+	findCmd.Flags().BoolVarP(&findOpts.FilesOnly, "files-with-matches", "l", false, "只输出包含匹配的文件名")
+	findCmd.Flags().BoolVarP(&findOpts.Count,     "count",              "c", false, "只输出每个文件的匹配行数")
+	findCmd.Flags().BoolVarP(&findOpts.Null,      "null",               "0", false, "文件名后输出 NUL 字符（配合 xargs -0）")
+	findCmd.Flags().IntVarP( &cfg.Context,        "context",            "C", 0,     "同时输出匹配行前后的 N 行")
+	findCmd.Flags().IntVar(  &findOpts.Unique,    "unique-lines",            0,     "汇总不同的匹配行（去掉首尾空白），按包含它的文件数列出前 N 种")
+	findCmd.Flags().Lookup("unique-lines").NoOptDefVal = "20"
+	addMatchFlags(verifyCmd.Flags())
+	verifyCmd.Flags().StringVar(&cfg.ReportJUnit, "report-junit", "", "把 JUnit XML 报告写入指定文件，仍包含源字符串的文件记为失败")
+	verifyCmd.Flags().StringVar(&annotate,        "annotate",     "", "把匹配输出为 CI 注释: github（在 GitHub Actions 中默认启用）")
+
+	undoCmd.Flags().BoolVarP(&cfg.Trial, "test", "T", false, "试验模式（只列出将要恢复的文件）")
+
+	rootCmd.AddCommand(replaceCmd, findCmd, verifyCmd, undoCmd)
+}
+
+// runFind 执行 find 或 verify：在试验模式下复用替换的扫描流程，只报告匹配
+func runFind(args []string, verify bool) error {
+	promptMissing(&cfg.SourceString, nil)
+	if cfg.SourceString == "" {
+		return configError("必须指定要查找的字符串（--from 参数）")
+	}
+	if err := validateMatchFlags(); err != nil {
+		return err
+	}
+	if cfg.Context < 0 {
+		return configError("--context 不能为负数")
+	}
+	// A match spanning lines has no single line to print
+	if !verify && multilinePattern(cfg.SourceString) && !findOpts.FilesOnly {
+		return configError("含有换行符的 --from 只能与 -l 一起查找")
+	}
+	if findOpts.Unique < 0 {
+		return configError("--unique-lines 不能为负数")
+	}
+	if findOpts.Unique > 0 && (findOpts.FilesOnly || findOpts.Count || findOpts.Null || cfg.Context > 0 || cfg.Format != FormatConsole) {
+		return configError("--unique-lines 只用于控制台输出，不能与 -l、-c、-0 或 -C 一起使用")
+	}
+
+	// 以源字符串替换自身，扫描结果与替换模式完全一致且不会写入任何文件
+	cfg.Trial = true
+	cfg.TargetString = cfg.SourceString
+	cfg.previewAll = !findOpts.FilesOnly
+	cfg.searchOnly = true
+
+	if cfg.Format == FormatConsole {
+		find := newFindReporter(os.Stdout, os.Stderr, findOpts, verify)
+		cfg.Reporter = find
+		if findOpts.Unique > 0 {
+			cfg.Reporter = newUniqueReporter(find, findOpts.Unique)
+		}
+	}
+	if err := prepareRun(args); err != nil {
+		return err
+	}
+
+	// Inside GitHub Actions verify annotates the pull request by default
+	if verify && annotate == AnnotateNone && os.Getenv("GITHUB_ACTIONS") == "true" {
+		annotate = AnnotateGitHub
+	}
+	switch annotate {
+	case AnnotateNone:
+	case AnnotateGitHub:
+		cfg.Reporter = teeReporter{newGitHubAnnotator(os.Stdout), cfg.Reporter}
+	default:
+		return configError("无效的注释格式: %s（可选 github）", annotate)
+	}
+
+	result, err := Run(&cfg)
+	if err != nil {
+		return err
+	}
+	found := atomic.LoadInt32(&result.Matches) > 0
+	switch {
+	case verify && found:
+		return ErrStillPresent
+	case !verify && !found && atomic.LoadInt32(&result.Errors) > 0:
+		return ErrFindFailed
+	case !verify && !found:
+		return ErrNoMatches
+	}
+	return nil
+}
+
+// runUndo 恢复最近一次记录了撤销日志的运行所修改的文件
+func runUndo() error {
+	absSourceDir, err := absPath(cfg.SourceDir)
+	if err != nil {
+		return fmt.Errorf("无法获取源目录的绝对路径: %w", err)
+	}
+
+	dir, err := latestJournal(absSourceDir)
+	if err != nil {
+		return err
+	}
+	entries, err := readJournal(dir)
+	if err != nil {
+		return fmt.Errorf("读取撤销日志 %s 时发生错误: %w", dir, err)
+	}
+
+	fmt.Printf("撤销运行记录: %s\n", filepath.Base(dir))
+
+	// 按修改的相反顺序恢复
+	restored, failed := 0, 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if cfg.Trial {
+			fmt.Printf("[试验] 恢复: %s\n", entry.Path)
+			restored++
+			continue
+		}
+		if err := restoreEntry(osFS{}, dir, entry, cfg.Force); err != nil {
+			fmt.Fprintf(os.Stderr, "恢复 %s 失败: %v\n", entry.Path, err)
+			failed++
+			continue
+		}
+		if cfg.Verbose {
+			fmt.Printf("恢复: %s\n", entry.Path)
+		}
+		restored++
+	}
+
+	fmt.Printf("\n恢复文件数: %d, 失败: %d\n", restored, failed)
+	if cfg.Trial {
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("部分文件未能恢复，撤销日志保留在 %s", dir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("删除撤销日志 %s 时发生错误: %w", dir, err)
+	}
+	return nil
+}
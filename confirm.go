@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// confirmBlastRadius 先以试验模式扫描一遍，统计将被修改的文件数。
+// 超过 --confirm-over 阈值时打印概要并请求用户确认；不继续执行替换时返回错误。
+func confirmBlastRadius(config *Config, rules []string) error {
+	fmt.Fprintln(os.Stderr, "预扫描（试验模式）...")
+
+	// The scan runs silently; only its totals are shown before asking
+	scan, err := prescan(config, rules, silentReporter{})
+	if err != nil {
+		return kindErrorf(ErrWalkFailed, "预扫描目录时发生错误: %w", err)
+	}
+
+	files := atomic.LoadInt32(&scan.FilesMatches)
+	fmt.Fprintf(os.Stderr, "\n预扫描结果: %d 个文件将被修改，共 %d 处替换\n", files, atomic.LoadInt32(&scan.Matches))
+	if int(files) <= config.ConfirmOver {
+		fmt.Fprintln(os.Stderr)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "将被修改的文件数超过确认阈值 %d.\n", config.ConfirmOver)
+	if config.Yes {
+		fmt.Fprintln(os.Stderr, "已指定 --yes，继续执行替换.")
+		fmt.Fprintln(os.Stderr)
+		return nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		return kindErrorf(ErrCanceled, "标准输入不是终端，无法确认；如需无人值守运行请使用 --yes")
+	}
+
+	fmt.Fprint(os.Stderr, "是否继续执行替换? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	fmt.Fprintln(os.Stderr)
+	if answer != "y" && answer != "yes" {
+		return kindErrorf(ErrCanceled, "已取消，未修改任何文件")
+	}
+	return nil
+}
+
+// prescan 在正式运行之前以试验模式扫描一遍，事件交给 reporter 而不是输出。
+// 确认、权限预检和审阅界面共用这一扫描，返回前恢复配置。
+func prescan(config *Config, rules []string, reporter Reporter) (*Result, error) {
+	trial, cleanStale, saved := config.Trial, config.CleanStale, config.Reporter
+	config.Trial, config.CleanStale, config.Reporter = true, false, reporter
+	defer func() {
+		config.Trial, config.CleanStale, config.Reporter = trial, cleanStale, saved
+	}()
+
+	scan := &Result{RuleMatches: make([]int32, len(rules))}
+	return scan, processDirectory(config, scan)
+}
+
+// isTerminal 判断文件是否连接到终端
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
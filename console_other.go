@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// setupConsole 在 Windows 以外的平台上无需处理，终端直接显示 UTF-8
+func setupConsole() (restore func()) {
+	return func() {}
+}
+
+// enableVirtualTerminal 在 Windows 以外的平台上终端总是支持 ANSI 转义序列
+func enableVirtualTerminal(*os.File) bool {
+	return true
+}
@@ -0,0 +1,48 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// cpUTF8 是 UTF-8 的代码页编号
+const cpUTF8 = 65001
+
+// setupConsole 在标准输出是控制台时把输出代码页切换为 UTF-8，
+// 使中文提示在使用旧代码页（如 936）的 cmd.exe/PowerShell 中正常显示。
+// 输出被重定向到文件或管道时不做任何改变，写出的仍是 UTF-8。
+// 返回的函数恢复原来的代码页；控制台代码页在进程退出后仍然有效，必须恢复。
+func setupConsole() (restore func()) {
+	restore = func() {}
+
+	var mode uint32
+	out := windows.Handle(os.Stdout.Fd())
+	if windows.GetConsoleMode(out, &mode) != nil {
+		return restore
+	}
+
+	previous, err := windows.GetConsoleOutputCP()
+	if err != nil || previous == cpUTF8 {
+		return restore
+	}
+	if windows.SetConsoleOutputCP(cpUTF8) != nil {
+		return restore
+	}
+	return func() { windows.SetConsoleOutputCP(previous) }
+}
+
+// enableVirtualTerminal 为控制台开启 ANSI 转义序列处理，失败时返回 false
+func enableVirtualTerminal(f *os.File) bool {
+	var mode uint32
+	h := windows.Handle(f.Fd())
+	if windows.GetConsoleMode(h, &mode) != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
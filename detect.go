@@ -1,8 +1,8 @@
 package main
 
 import (
+	"bytes"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 	"unicode/utf8"
@@ -17,8 +17,8 @@ const (
 	Unknown
 )
 
-// DetectFileType 综合检测文件类型
-func DetectFileType(filePath string) (FileType, error) {
+// DetectFileType 综合检测文件类型，stats 可为 nil
+func DetectFileType(fsys FileSystem, filePath string, stats *IOStats) (FileType, error) {
 	// 检查扩展名
 	if hasBinaryExtension(filePath) {
 		return BinaryFile, nil
@@ -29,13 +29,16 @@ func DetectFileType(filePath string) (FileType, error) {
 	  return TextFile, nil
 	}
 
-	// 内容检测
-	return detectByContent(filePath)
+	// 内容检测；指定了 --encoding 时按该编码解码后检测
+	if efs, ok := fsys.(encodingFS); ok {
+		return detectEncoded(efs, filePath, stats)
+	}
+	return detectByContent(fsys, filePath, stats)
 }
 
 // detectByContent 通过文件内容检测类型
-func detectByContent(filePath string) (FileType, error) {
-	file, err := os.Open(filePath)
+func detectByContent(fsys FileSystem, filePath string, stats *IOStats) (FileType, error) {
+	file, err := stats.open(fsys, filePath)
 	if err != nil {
 		return Unknown, err
 	}
@@ -43,6 +46,7 @@ func detectByContent(filePath string) (FileType, error) {
 
 	buffer := make([]byte, 4096) // 4KB
 	n, err := file.Read(buffer)
+	stats.addRead(int64(n))
 	if err != nil && err != io.EOF {
 		return Unknown, err
 	}
@@ -58,6 +62,12 @@ func detectByContent(filePath string) (FileType, error) {
 		}
 	}
 
+	// 以 #! 开头的脚本（bin/deploy、git 钩子等）直接视为文本，
+	// 不再依赖可打印字符比例；带有二进制负载的自解压脚本已被上面的 null 字节检查排除
+	if bytes.HasPrefix(buffer[:n], []byte("#!")) {
+		return TextFile, nil
+	}
+
 	// 检查 UTF-8 有效性
 	if (n < 4096 || utf8.Valid(buffer[:n])) {
 		// 进一步检查可打印字符比例
@@ -66,12 +76,9 @@ func detectByContent(filePath string) (FileType, error) {
 		} else {
 			return BinaryFile, nil
 		}
-	} else {
-		return BinaryFile, nil
 	}
 
-	// 默认认为是文本文件（保守策略）
-	return TextFile, nil
+	return BinaryFile, nil
 }
 
 // calculatePrintableRatio 计算可打印字符比例
@@ -143,8 +150,8 @@ func hasTextExtension(filePath string) bool {
 }
 
 // isBinaryFile 决定是否跳过二进制文件
-func isBinaryFile(filePath string) (bool, error) {
-	fileType, err := DetectFileType(filePath)
+func isBinaryFile(fsys FileSystem, filePath string, stats *IOStats) (bool, error) {
+	fileType, err := DetectFileType(fsys, filePath, stats)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/shebang 中没有扩展名的脚本：以 #! 开头即视为文本，不论解释器写成
+// /usr/bin/env 还是直接路径，也不论其余内容是否为 ASCII 或有效的 UTF-8
+func TestDetectShebang(t *testing.T) {
+	tests := []struct {
+		name string
+		want FileType
+	}{
+		{"deploy", TextFile},      // #!/usr/bin/env bash, mostly Chinese
+		{"manage", TextFile},      // #!/usr/bin/python3, mostly Chinese
+		{"run-tests", TextFile},   // #!/usr/bin/env -S perl -w
+		{"post-commit", TextFile}, // #!/bin/sh
+		{"greet", TextFile},       // #!/usr/bin/perl, Latin-1
+		{"notes", BinaryFile},     // the same kind of text as deploy without a shebang
+	}
+	for _, tt := range tests {
+		path := filepath.Join("testdata", "shebang", tt.name)
+		got, err := detectByContent(osFS{}, path, &IOStats{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s 检测为 %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 带 shebang 的脚本照常参与替换：有匹配的被改写，没有匹配的保持不变
+func TestShebangRun(t *testing.T) {
+	dir := copyFixtures(t, "shebang")
+	before := map[string]string{}
+	for _, name := range []string{"post-commit", "run-tests", "notes"} {
+		before[name] = readTestFile(t, filepath.Join(dir, name))
+	}
+
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "staging.example.com", TargetString: "preprod.example.com"})
+
+	for _, name := range []string{"deploy", "manage", "greet"} {
+		got := readTestFile(t, filepath.Join(dir, name))
+		if strings.Contains(got, "staging") || !strings.Contains(got, "preprod.example.com") {
+			t.Errorf("%s 没有被替换", name)
+		}
+		if !strings.HasPrefix(got, "#!") {
+			t.Errorf("%s 的 shebang 行被改动", name)
+		}
+	}
+	for name, content := range before {
+		if got := readTestFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s 不应被改写", name)
+		}
+	}
+	if result.FilesMatches != 3 || result.Matches != 3 {
+		t.Errorf("修改 %d 个文件、%d 处，应为 3 个文件、3 处", result.FilesMatches, result.Matches)
+	}
+	if got := result.Skipped[SkipBinary]; got != 1 {
+		t.Errorf("作为二进制文件跳过 %d 个，应为 1 个（notes）", got)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// deviceID 返回目录所在文件系统的设备号；WalkDir 已经 lstat 过，直接复用
+func deviceID(path string, d fs.DirEntry) (uint64, error) {
+	info, err := d.Info()
+	if err != nil {
+		return 0, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("无法获取 %s 的设备号", path)
+	}
+	return uint64(st.Dev), nil
+}
+
+// fileKey 返回标识文件或目录本身的键（设备号和 inode），不同路径指向同一文件时相同
+func fileKey(path string, info fs.FileInfo) string {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+	}
+	return resolvedKey(path)
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/windows"
+)
+
+// deviceID 返回目录所在卷的序列号。目录项中没有卷信息，需要打开目录句柄查询；
+// 只打开读取属性的权限，不影响其他进程使用该目录。
+func deviceID(path string, d fs.DirEntry) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return 0, err
+	}
+
+	h, err := windows.CreateFile(p, windows.FILE_READ_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &info); err != nil {
+		return 0, err
+	}
+	return uint64(info.VolumeSerialNumber), nil
+}
+
+// fileKey 返回标识文件或目录本身的键。目录项中没有文件 ID，
+// 为避免每个文件多打开一次句柄，使用解析符号链接后的路径。
+func fileKey(path string, info fs.FileInfo) string {
+	return resolvedKey(path)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext 是 --diff 每处修改前后显示的不变行数
+const diffContext = 3
+
+// diffLine 是差异中的一行，old 和 new 为其在原文件和替换后文件中的行号
+type diffLine struct {
+	text     string
+	old, new int
+}
+
+// unifiedDiff 逐行生成统一差异格式 (diff -u) 的输出。只保留当前块和
+// 最多 2*diffContext 行上下文，不需要同时持有替换前后的完整内容。
+type unifiedDiff struct {
+	sb    *strings.Builder
+	color bool
+
+	oldNo, newNo int        // 已读入的行数
+	before       []diffLine // 块开始前的上下文行
+	pending      []diffLine // 块内最后一处修改之后的不变行
+	open         bool       // 当前有未输出的块
+	hunk         []string   // 当前块的内容，已带 " "、"-"、"+" 前缀
+	added        []string   // 连续修改的新行，在这组修改的删除行之后输出
+	oldStart     int
+	newStart     int
+	oldLines     int
+	newLines     int
+}
+
+// fileDiff 用 matcher 扫描文件，返回替换产生的差异；没有改变时返回空字符串。
+// 替换后与原文相同的行视为不变
+func fileDiff(fsys FileSystem, filePath string, matcher Matcher, color bool, stats *IOStats) (string, error) {
+	file, err := stats.open(fsys, filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var sb strings.Builder
+	d := &unifiedDiff{sb: &sb, color: color}
+	lines := newLineReader(stats.reader(file))
+	for {
+		_, body, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		line := lineString(body)
+		matches := matcher.FindAll(line)
+		if len(matches) == 0 || sameReplacements(line, matches) {
+			d.same(strings.Clone(line))
+		} else {
+			d.change(line, applyMatches(line, matches))
+		}
+	}
+	d.flush()
+
+	if sb.Len() == 0 {
+		return "", nil
+	}
+	path := escapeControl(filePath)
+	return paint("--- "+path, ansiMatch, color) + "\n" + paint("+++ "+path, ansiReplace, color) + "\n" + sb.String(), nil
+}
+
+// same 记录一行不变的内容
+func (d *unifiedDiff) same(text string) {
+	d.oldNo++
+	d.newNo++
+	l := diffLine{text: text, old: d.oldNo, new: d.newNo}
+
+	if !d.open {
+		if len(d.before) == diffContext {
+			d.before = append(d.before[:0], d.before[1:]...)
+		}
+		d.before = append(d.before, l)
+		return
+	}
+
+	// A gap wider than both contexts ends the hunk; its tail becomes the
+	// leading context of the next one
+	d.pending = append(d.pending, l)
+	if len(d.pending) > 2*diffContext {
+		d.flush()
+		d.before = append(d.before[:0], d.pending[len(d.pending)-diffContext:]...)
+		d.pending = d.pending[:0]
+	}
+}
+
+// change 记录一行被替换的内容，替换结果中的换行产生多行
+func (d *unifiedDiff) change(old, new string) {
+	if !d.open {
+		d.open = true
+		d.hunk = d.hunk[:0]
+		d.oldStart, d.newStart = d.oldNo+1, d.newNo+1
+		if len(d.before) > 0 {
+			d.oldStart, d.newStart = d.before[0].old, d.before[0].new
+		}
+		d.oldLines, d.newLines = 0, 0
+		for _, l := range d.before {
+			d.context(l)
+		}
+		d.before = d.before[:0]
+	}
+	for _, l := range d.pending {
+		d.context(l)
+	}
+	d.pending = d.pending[:0]
+
+	d.oldNo++
+	d.oldLines++
+	d.hunk = append(d.hunk, paint("-"+escapeControl(old), ansiMatch, d.color))
+	for _, part := range strings.Split(new, "\n") {
+		d.newNo++
+		d.newLines++
+		d.added = append(d.added, paint("+"+escapeControl(part), ansiReplace, d.color))
+	}
+}
+
+func (d *unifiedDiff) context(l diffLine) {
+	d.hunk = append(d.hunk, d.added...)
+	d.added = d.added[:0]
+	d.hunk = append(d.hunk, " "+escapeControl(l.text))
+	d.oldLines++
+	d.newLines++
+}
+
+// flush 输出当前块，块后最多带 diffContext 行上下文
+func (d *unifiedDiff) flush() {
+	if !d.open {
+		return
+	}
+	for _, l := range d.pending[:min(len(d.pending), diffContext)] {
+		d.context(l)
+	}
+	d.hunk = append(d.hunk, d.added...)
+	d.added = d.added[:0]
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(d.oldStart, d.oldLines), hunkRange(d.newStart, d.newLines))
+	d.sb.WriteString(paint(header, ansiSep, d.color))
+	d.sb.WriteByte('\n')
+	for _, line := range d.hunk {
+		d.sb.WriteString(line)
+		d.sb.WriteByte('\n')
+	}
+	d.open = false
+}
+
+// hunkRange 按 diff -u 的习惯格式化块的行范围：只有一行时省略行数
+func hunkRange(start, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math/rand"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"unchanged", "a\nb\n", ""},
+		{"one line", "a\nfoo\nb\n", "@@ -1,3 +1,3 @@\n a\n-foo\n+bar\n b\n"},
+		{"first line", "foo\na\nb\nc\nd\ne\n", "@@ -1,4 +1,4 @@\n-foo\n+bar\n a\n b\n c\n"},
+		{"last line", "a\nb\nc\nd\nfoo\n", "@@ -2,4 +2,4 @@\n b\n c\n d\n-foo\n+bar\n"},
+		{"adjacent", "a\nfoo\nfoo x\nb\n", "@@ -1,4 +1,4 @@\n a\n-foo\n-foo x\n+bar\n+bar x\n b\n"},
+		{"merged", "foo\n1\n2\n3\n4\n5\n6\nfoo\n", "@@ -1,8 +1,8 @@\n-foo\n+bar\n 1\n 2\n 3\n 4\n 5\n 6\n-foo\n+bar\n"},
+		{"two hunks", "foo\n1\n2\n3\n4\n5\n6\n7\nfoo\n", "@@ -1,4 +1,4 @@\n-foo\n+bar\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-foo\n+bar\n"},
+		{"crlf", "a\r\nfoo\r\n", "@@ -1,2 +1,2 @@\n a\n-foo\n+bar\n"},
+	}
+	for _, tt := range tests {
+		path := writeTestFile(t, t.TempDir(), "a.txt", tt.in, 0o644)
+		got, err := fileDiff(osFS{}, path, newLiteralMatcher("foo", "bar"), false, &IOStats{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want != "" {
+			tt.want = "--- " + path + "\n+++ " + path + "\n" + tt.want
+		}
+		if got != tt.want {
+			t.Errorf("%s: 差异\n%s\n应为\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 随机内容上的块划分、块头和上下文与 diff -u 相同
+func TestFileDiffMatchesDiffU(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("没有 diff")
+	}
+	rng := rand.New(rand.NewSource(507))
+	words := []string{"foo", "a foo b", "plain", "other", "", "x"}
+	for i := range 200 {
+		var sb strings.Builder
+		for range rng.Intn(40) {
+			sb.WriteString(words[rng.Intn(len(words))] + "\n")
+		}
+		content := sb.String()
+		dir := t.TempDir()
+		before := writeTestFile(t, dir, "before.txt", content, 0o644)
+		after := writeTestFile(t, dir, "after.txt", strings.ReplaceAll(content, "foo", "bar"), 0o644)
+
+		got, err := fileDiff(osFS{}, before, newLiteralMatcher("foo", "bar"), false, &IOStats{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// diff exits with 1 when the files differ
+		out, _ := exec.Command("diff", "-u", before, after).Output()
+		want := string(out)
+		if _, hunks, ok := strings.Cut(want, "\n@@"); ok {
+			want = "--- " + before + "\n+++ " + before + "\n@@" + hunks
+		}
+		if got != want {
+			t.Fatalf("第 %d 个文件 %q 的差异\n%s\n应为\n%s", i, content, got, want)
+		}
+	}
+}
+
+// diffRecorder 记录试验模式下每个文件的差异
+type diffRecorder struct {
+	silentReporter
+	diffs map[string]string
+}
+
+func (r *diffRecorder) FileMatched(ev FileEvent) {
+	r.diffs[filepath.Base(ev.Path)] = ev.Diff
+}
+
+// 试验模式的 --diff 随匹配事件给出差异，不写入任何文件
+func TestDiffRun(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "a\nfoo\nb\n", 0o644)
+	writeTestFile(t, dir, "b.txt", "no match\n", 0o644)
+
+	rec := &diffRecorder{diffs: map[string]string{}}
+	config := &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Trial: true, Diff: true, Workers: 1, NoLock: true, Reporter: rec}
+	if _, err := Run(config); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.diffs) != 1 || !strings.HasSuffix(rec.diffs["a.txt"], "@@ -1,3 +1,3 @@\n a\n-foo\n+bar\n b\n") {
+		t.Errorf("差异 %q", rec.diffs)
+	}
+	if got := readTestFile(t, path); got != "a\nfoo\nb\n" {
+		t.Errorf("试验模式修改了文件: %q", got)
+	}
+	assertNoTempFiles(t, dir)
+}
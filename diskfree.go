@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"sync/atomic"
+)
+
+// reserveTempSpace 在改写文件之前确认目标文件系统有足够空间容纳临时文件。
+// 所有工人正在写的临时文件都计入预计用量；空间不足时返回 false，
+// 调用方应跳过该文件。无法查询可用空间时不做限制。
+// 返回的 release 在临时文件被重命名或删除后调用。
+func reserveTempSpace(config *Config, result *Result, path string, size int64) (release func(), ok bool) {
+	release = func() {}
+	if config.Force {
+		return release, true
+	}
+
+	inflight := atomic.AddInt64(&result.tempInFlight, size)
+	release = func() { atomic.AddInt64(&result.tempInFlight, -size) }
+
+	dir := config.TempDir
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	free, err := freeSpace(dir)
+	if err != nil || uint64(inflight) <= free {
+		return release, true
+	}
+
+	release()
+	return func() {}, false
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// freeSpace 返回 path 所在文件系统上非特权用户可用的字节数
+func freeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// isNoSpace 判断错误是否由磁盘空间不足引起
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
+
+// isCrossDevice 判断重命名失败是否因为源和目标位于不同的文件系统
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// --emit-script 的脚本类型
+const (
+	ScriptSed        = "sed"
+	ScriptPowerShell = "powershell"
+)
+
+// scriptReporter 在试验模式下收集有匹配的文件，结束时输出一个用系统自带工具
+// 完成同样替换的脚本（--emit-script），每个文件一条命令。
+// 脚本只在标准输出上输出，错误写到标准错误，便于直接重定向到文件中审阅。
+type scriptReporter struct {
+	silentReporter
+	w     io.Writer
+	kind  string
+	mu    sync.Mutex
+	files []string
+}
+
+// newScriptReporter 创建输出 kind 类型脚本的 Reporter
+func newScriptReporter(w io.Writer, kind string) *scriptReporter {
+	return &scriptReporter{w: w, kind: kind}
+}
+
+func (r *scriptReporter) FileMatched(ev FileEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = append(r.files, ev.Path)
+}
+
+func (r *scriptReporter) Error(path string, err error) {
+	log.Print(escapeControl(err.Error()))
+}
+
+func (r *scriptReporter) Summary(config *Config, result *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Strings(r.files)
+
+	var sb strings.Builder
+	switch r.kind {
+	case ScriptSed:
+		writeSedScript(&sb, config.SourceString, config.TargetString, r.files)
+	case ScriptPowerShell:
+		writePowerShellScript(&sb, config.SourceString, config.TargetString, r.files)
+	}
+	io.WriteString(r.w, sb.String())
+}
+
+// writeSedScript 生成 GNU sed 脚本。LC_ALL=C 让 sed 按字节匹配，与 reStr 一致；
+// 源字符串按基本正则表达式转义，目标字符串转义 \、&、分隔符和换行符，整体放在单引号中。
+func writeSedScript(sb *strings.Builder, from, to string, files []string) {
+	fmt.Fprintf(sb, "#!/bin/sh\n# 由 reStr --emit-script sed 生成: %d 个文件\n", len(files))
+	fmt.Fprintf(sb, "# %s -> %s\nset -e\n\n", scriptComment(from), scriptComment(to))
+	expr := "s/" + sedPattern(from) + "/" + sedReplacement(to) + "/g"
+	for _, f := range files {
+		fmt.Fprintf(sb, "LC_ALL=C sed -i -e %s -- %s\n", shellQuote(expr), shellQuote(f))
+	}
+}
+
+// sedPattern 转义基本正则表达式中的特殊字符和分隔符 /
+func sedPattern(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`\/.*[]^$`, s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// sedReplacement 转义替换文本中的 \、&、分隔符 / 和换行符；
+// 换行符写成反斜杠加换行，POSIX sed 和 GNU sed 都把它当作替换中的换行
+func sedReplacement(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("\\/&\n", s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// shellQuote 把 s 放进 POSIX shell 的单引号中，内部的单引号先结束引号、转义后再重新开始
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writePowerShellScript 生成 PowerShell 脚本。文件按 ISO-8859-1 读写，每个字节
+// 对应一个字符，替换是逐字节的字面替换（String.Replace，而不是正则 -replace），
+// 文件的编码、BOM 和换行符都原样保留。
+func writePowerShellScript(sb *strings.Builder, from, to string, files []string) {
+	fmt.Fprintf(sb, "# 由 reStr --emit-script powershell 生成: %d 个文件\n", len(files))
+	fmt.Fprintf(sb, "# %s -> %s\n$ErrorActionPreference = 'Stop'\n", scriptComment(from), scriptComment(to))
+	sb.WriteString("$e = [Text.Encoding]::GetEncoding(28591)\n")
+	fmt.Fprintf(sb, "$from = %s\n$to = %s\n\n", psBytes(from), psBytes(to))
+	for _, f := range files {
+		p := psString(f)
+		fmt.Fprintf(sb, "[IO.File]::WriteAllText(%s, [IO.File]::ReadAllText(%s, $e).Replace($from, $to), $e)\n", p, p)
+	}
+}
+
+// psString 生成表示 s 的 PowerShell 表达式：可打印的 ASCII 放在单引号中（单引号双写），
+// 否则按 UTF-8 字节构造。PowerShell 把弯引号也当作单引号，脚本文件的编码
+// 也会影响非 ASCII 字符，字节形式不受两者影响。
+func psString(s string) string {
+	if !isPrintableASCII(s) {
+		return "[Text.Encoding]::UTF8.GetString(" + psByteArray(s) + ")"
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// psBytes 生成按 ISO-8859-1 解码 s 的字节得到的字符串，与按 $e 读入的文件内容逐字节对应
+func psBytes(s string) string {
+	if isPrintableASCII(s) {
+		return psString(s)
+	}
+	return "$e.GetString(" + psByteArray(s) + ")"
+}
+
+// psByteArray 生成 [byte[]](0x..,0x..) 形式的字节数组
+func psByteArray(s string) string {
+	parts := make([]string, len(s))
+	for i := 0; i < len(s); i++ {
+		parts[i] = fmt.Sprintf("0x%02x", s[i])
+	}
+	return "[byte[]](" + strings.Join(parts, ",") + ")"
+}
+
+// isPrintableASCII 判断 s 只含可打印的 ASCII 字符
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// scriptComment 把字符串放进脚本的注释行，控制字符已转义，不会提前结束注释
+func scriptComment(s string) string {
+	return "'" + escapeControl(s) + "'"
+}
+
+// validateEmitScript 检查 --emit-script：脚本只能表达普通的字面替换
+func validateEmitScript() error {
+	if cfg.EmitScript == "" {
+		return nil
+	}
+	switch cfg.EmitScript {
+	case ScriptSed, ScriptPowerShell:
+	default:
+		return configError("无效的脚本类型: %s（可选 sed|powershell）", cfg.EmitScript)
+	}
+	if !cfg.Trial {
+		return configError("--emit-script 只能在试验模式（--test）下使用")
+	}
+	if cfg.Format != FormatConsole {
+		return configError("--emit-script 代替普通输出，不能与 --format 一起使用")
+	}
+	if whitespaceMode(&cfg) || cfg.TrimTrailing || cfg.Swap || cfg.Map != "" || cfg.IgnoreWhitespace || cfg.Regex || cfg.IgnoreCase || cfg.Word || cfg.LineMode || cfg.Anchor != AnchorNone ||
+		cfg.Nth > 0 || cfg.Occurrences != "" || cfg.MaxTotal > 0 || cfg.TUI || cfg.Encoding != "" {
+		return configError("--emit-script 只支持普通的字符串替换，不能与空白转换、--trim-trailing、--swap、--map、--ignore-whitespace、--regex、--ignore-case、--word、--line-mode、--anchor、--nth、--occurrences、--max-total、--tui 或 --encoding 一起使用")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSedPattern(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"foo", "foo"},
+		{"a/b", `a\/b`},
+		{`C:\dir`, `C:\\dir`},
+		{"a.b*c", `a\.b\*c`},
+		{"[x]^$", `\[x\]\^\$`},
+		{"a&b", "a&b"},     // & is only special in the replacement
+		{"a+b?", "a+b?"},   // literal in a basic regular expression
+		{"it's", "it's"},   // quoting is left to shellQuote
+		{"größe", "größe"}, // bytes under LC_ALL=C
+	}
+	for _, tt := range tests {
+		if got := sedPattern(tt.in); got != tt.want {
+			t.Errorf("sedPattern(%q) = %q，应为 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSedReplacement(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"bar", "bar"},
+		{"a/b", `a\/b`},
+		{"a&b", `a\&b`},
+		{`C:\dir`, `C:\\dir`},
+		{`\1`, `\\1`},
+		{"one\ntwo", "one\\\ntwo"},
+		{"a.b*c[x]", "a.b*c[x]"}, // not special in the replacement
+		{"it's", "it's"},
+	}
+	for _, tt := range tests {
+		if got := sedReplacement(tt.in); got != tt.want {
+			t.Errorf("sedReplacement(%q) = %q，应为 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "'plain'"},
+		{"", "''"},
+		{"it's", `'it'\''s'`},
+		{"$HOME `id` \\", "'$HOME `id` \\'"},
+		{"a\nb", "'a\nb'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q，应为 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 生成的 sed 脚本在 sh 和 sed 下执行的结果与字面替换相同
+func TestSedScriptRuns(t *testing.T) {
+	if _, err := exec.LookPath("sed"); err != nil {
+		t.Skip("没有 sed")
+	}
+	tests := []struct{ from, to string }{
+		{"foo", "bar"},
+		{"/usr/local", "/opt"},
+		{"a&b", "x&y"},
+		{`C:\dir`, `D:\new\1`},
+		{"a.*b", "[c]"},
+		{"it's", `"it's"`},
+		{"$HOME", "`id`"},
+		{"end", "line one\nline two"},
+		{"größe", "größer"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		content := "x " + tt.from + " y " + tt.from + "\nno match\n" + tt.from + "\n"
+		path := writeTestFile(t, dir, "it's a file.txt", content, 0o644)
+
+		var sb strings.Builder
+		writeSedScript(&sb, tt.from, tt.to, []string{path})
+		script := writeTestFile(t, dir, "replace.sh", sb.String(), 0o755)
+		if out, err := exec.Command("sh", script).CombinedOutput(); err != nil {
+			t.Fatalf("%q -> %q: %v\n%s", tt.from, tt.to, err, out)
+		}
+		if got, want := readTestFile(t, path), strings.ReplaceAll(content, tt.from, tt.to); got != want {
+			t.Errorf("%q -> %q: 脚本的结果 %q，应为 %q", tt.from, tt.to, got, want)
+		}
+	}
+}
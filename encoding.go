@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// textEncodings 是 --encoding 支持的编码
+var textEncodings = map[string]encoding.Encoding{
+	"gbk":       simplifiedchinese.GBK,
+	"gb18030":   simplifiedchinese.GB18030,
+	"big5":      traditionalchinese.Big5,
+	"shift-jis": japanese.ShiftJIS,
+	"latin-1":   charmap.ISO8859_1,
+}
+
+// encodingNames 返回支持的编码名称，如 "big5|gb18030|gbk|latin-1|shift-jis"
+func encodingNames() string {
+	names := make([]string, 0, len(textEncodings))
+	for name := range textEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// checkEncodable 确认命令行给出的字符串能用文件的编码表示，
+// 否则替换结果无法写回
+func checkEncodable(enc encoding.Encoding, name string, values ...string) error {
+	for _, s := range values {
+		if _, err := enc.NewEncoder().String(s); err != nil {
+			return configError("'%s' 含有 %s 编码无法表示的字符", s, name)
+		}
+	}
+	return nil
+}
+
+// contentFS 返回读写文件内容时使用的文件系统：指定 --encoding 时
+// 内容以 UTF-8 交给匹配器，写回时再编码；备份和撤销日志仍使用 config.FS，
+// 保存原始字节。
+func contentFS(config *Config) FileSystem {
+	if config.encoding == nil {
+		return config.FS
+	}
+	return encodingFS{FileSystem: config.FS, enc: config.encoding, name: config.Encoding}
+}
+
+// encodingFS 在打开文件时把内容从 enc 解码为 UTF-8，在临时文件中编码回 enc
+type encodingFS struct {
+	FileSystem
+	enc  encoding.Encoding
+	name string
+}
+
+func (f encodingFS) Open(name string) (io.ReadCloser, error) {
+	file, err := f.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	decoder := transform.Chain(f.enc.NewDecoder(), rejectReplacement{name: f.name})
+	return readCloser{Reader: transform.NewReader(file, decoder), Closer: file}, nil
+}
+
+func (f encodingFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	file, err := f.FileSystem.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedTemp{TempFile: file, enc: f.enc, w: transform.NewWriter(file, f.enc.NewEncoder())}, nil
+}
+
+// Chmod、Lchown 和 Chtimes 转发给底层文件系统，替换时照常保留属性
+func (f encodingFS) Chmod(name string, mode fs.FileMode) error {
+	if afs, ok := f.FileSystem.(attrFS); ok {
+		return afs.Chmod(name, mode)
+	}
+	return nil
+}
+
+func (f encodingFS) Lchown(name string, uid, gid int) error {
+	if afs, ok := f.FileSystem.(attrFS); ok {
+		return afs.Lchown(name, uid, gid)
+	}
+	return nil
+}
+
+func (f encodingFS) Chtimes(name string, atime, mtime time.Time) error {
+	if afs, ok := f.FileSystem.(attrFS); ok {
+		return afs.Chtimes(name, atime, mtime)
+	}
+	return nil
+}
+
+// readCloser 组合解码后的 Reader 和原文件的 Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// encodedTemp 把写入的 UTF-8 编码后写进临时文件。
+// 编码器遇到目标编码无法表示的字符时写入失败，文件按错误处理。
+type encodedTemp struct {
+	TempFile
+	enc encoding.Encoding
+	w   *transform.Writer
+}
+
+func (t *encodedTemp) Write(p []byte) (int, error) {
+	return t.w.Write(p)
+}
+
+// Sync 先写出编码器中缓冲的内容（例如被两次写入拆开的多字节字符）再同步，
+// 校验和提交时同步到磁盘的是完整的文件
+func (t *encodedTemp) Sync() error {
+	if err := t.w.Close(); err != nil {
+		return err
+	}
+	// Later writes start from a fresh encoder state
+	t.w = transform.NewWriter(t.TempFile, t.enc.NewEncoder())
+	if syncer, ok := t.TempFile.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// abort 丢弃底层的临时文件，编码器中剩余的内容不再写出
+func (t *encodedTemp) abort(fsys FileSystem) {
+	discardTemp(fsys, t.TempFile)
+}
+
+func (t *encodedTemp) Close() error {
+	err := t.w.Close()
+	if closeErr := t.TempFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// replacementChar 是解码器代替无效字节输出的 U+FFFD
+var replacementChar = []byte(string(utf8.RuneError))
+
+// rejectReplacement 在解码结果中出现 U+FFFD 时报错：文件不是所声明的编码，
+// 继续替换会把无效字节永久改写为替换字符。GB18030 中本身编码为 U+FFFD
+// 的字符同样被拒绝，这种字符在实际文件中几乎不会出现。
+type rejectReplacement struct {
+	transform.NopResetter
+	name string
+}
+
+func (t rejectReplacement) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if bytes.Contains(src, replacementChar) {
+		return 0, 0, fmt.Errorf("内容不是有效的 %s 编码（--encoding 是否正确？）", t.name)
+	}
+
+	// A U+FFFD may be split across calls; hold back its first bytes
+	n := len(src)
+	if !atEOF {
+		for k := len(replacementChar) - 1; k > 0; k-- {
+			if bytes.HasSuffix(src, replacementChar[:k]) {
+				n -= k
+				err = transform.ErrShortSrc
+				break
+			}
+		}
+	}
+	if len(dst) < n {
+		n = len(dst)
+		err = transform.ErrShortDst
+	}
+	copy(dst, src[:n])
+	return n, n, err
+}
+
+// detectEncoded 按指定编码检测文件类型：null 字节在原始内容中检查，
+// 可打印字符比例按解码后的字符计算，汉字等非 ASCII 字符也算作可打印。
+// 开头的内容不是有效的编码时仍视为文本，由替换时的读取报告错误。
+func detectEncoded(fsys encodingFS, filePath string, stats *IOStats) (FileType, error) {
+	file, err := stats.open(fsys.FileSystem, filePath)
+	if err != nil {
+		return Unknown, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 4096) // 4KB
+	n, err := io.ReadFull(file, buffer)
+	stats.addRead(int64(n))
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Unknown, err
+	}
+	if n == 0 {
+		return TextFile, nil
+	}
+	if bytes.IndexByte(buffer[:n], 0) >= 0 {
+		return BinaryFile, nil
+	}
+
+	// A character cut off by the 4KB limit is left out
+	decoded := make([]byte, 4*n)
+	nDst, _, err := fsys.enc.NewDecoder().Transform(decoded, buffer[:n], n < len(buffer))
+	if err != nil && err != transform.ErrShortSrc {
+		return Unknown, err
+	}
+
+	text := decoded[:nDst]
+	if bytes.Contains(text, replacementChar) {
+		return TextFile, nil
+	}
+	if printableRuneRatio(text) > 0.85 {
+		return TextFile, nil
+	}
+	return BinaryFile, nil
+}
+
+// printableRuneRatio 计算 UTF-8 文本中可打印字符（含制表符和换行符）的比例
+func printableRuneRatio(text []byte) float64 {
+	total, printable := 0, 0
+	for _, r := range string(text) {
+		total++
+		if unicode.IsPrint(r) || r == '\t' || r == '\n' || r == '\r' {
+			printable++
+		}
+	}
+	if total == 0 {
+		return 1.0
+	}
+	return float64(printable) / float64(total)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// 每种 --encoding 编码的文件替换后仍是同一编码，未匹配的字节保持不变；
+// --verify-write 在同步后重新读取临时文件，同样通过
+func TestEncodingRoundTrip(t *testing.T) {
+	texts := map[string]string{
+		"gbk":       "中文注释 foo\n第二行\n",
+		"gb18030":   "中文注释 foo €\n第二行\n",
+		"big5":      "中文註解 foo\n第二行\n",
+		"shift-jis": "日本語のコメント foo\n二行目\n",
+		"latin-1":   "café foo\nnaïve\n",
+	}
+	for name, enc := range textEncodings {
+		for _, verify := range []bool{false, true} {
+			dir := t.TempDir()
+			content, err := enc.NewEncoder().String(texts[name])
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			path := writeTestFile(t, dir, "a.txt", content, 0o644)
+
+			result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar",
+				Encoding: name, encoding: enc, VerifyWrite: verify})
+			if result.Errors != 0 || result.FilesMatches != 1 {
+				t.Errorf("%s（verify=%v）: %d 个错误、%d 个匹配的文件，应为 0 和 1", name, verify, result.Errors, result.FilesMatches)
+			}
+			want, _ := enc.NewEncoder().String(strings.Replace(texts[name], "foo", "bar", 1))
+			if got := readTestFile(t, path); got != want {
+				t.Errorf("%s（verify=%v）: 替换后的内容为 %q，应为 %q", name, verify, got, want)
+			}
+			assertNoTempFiles(t, dir)
+		}
+	}
+}
+
+// 文件不是所声明的编码时报告错误，原文件保持不变
+func TestEncodingMisdeclared(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared string
+		content  string
+	}{
+		// 0xE9 followed by a newline is not a valid GBK pair
+		{"Latin-1 声明为 GBK", "gbk", "caf\xe9 foo\n"},
+		{"Latin-1 声明为 Shift-JIS", "shift-jis", "foo \xa0\xfd\n"},
+		{"截断的 Big5", "big5", "foo \xa4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestFile(t, dir, "a.txt", tt.content, 0o644)
+			result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar",
+				Encoding: tt.declared, encoding: textEncodings[tt.declared]})
+			if result.Errors != 1 {
+				t.Errorf("报告 %d 个错误，应为 1", result.Errors)
+			}
+			if got := readTestFile(t, path); got != tt.content {
+				t.Errorf("原文件被修改为 %q", got)
+			}
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+// namedTempFS 创建有名字的临时文件，关闭前也能从路径读取其内容
+type namedTempFS struct{ osFS }
+
+func (namedTempFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+// Sync 先写出编码器缓冲的内容：ISO-2022-JP 在结尾才写出切回 ASCII 的转义序列，
+// 同步时文件已经完整，Close 不再写入
+func TestEncodedTempSyncFlushes(t *testing.T) {
+	dir := t.TempDir()
+	fsys := encodingFS{FileSystem: namedTempFS{}, enc: japanese.ISO2022JP, name: "iso-2022-jp"}
+	f, err := fsys.CreateTemp(dir, "encoded-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	text := "日本語"
+	if _, err := f.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.(interface{ Sync() error }).Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := japanese.ISO2022JP.NewEncoder().String(text)
+	if got := readTestFile(t, f.Name()); got != want {
+		t.Errorf("同步后的内容为 %q，应为 %q", got, want)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, f.Name()); got != want {
+		t.Errorf("关闭后的内容为 %q，应为 %q", got, want)
+	}
+}
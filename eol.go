@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// 换行符转换的目标风格
+const (
+	EOLNone = ""
+	EOLLF   = "lf"
+	EOLCRLF = "crlf"
+)
+
+// eolTerminator 返回目标风格对应的行结束符
+func eolTerminator(style string) []byte {
+	if style == EOLCRLF {
+		return []byte("\r\n")
+	}
+	return []byte("\n")
+}
+
+// wholeResult 描述整个读入后改写的文件（换行符转换、跨行替换）的结果
+type wholeResult struct {
+	Changes     int  // 改变的行结束符数或跨行匹配数
+	Unchanged   bool // 有匹配，但替换后的内容与原来相同
+	BytesBefore int64
+	BytesAfter  int64
+	OwnerErr    error // 未能保留原文件所有者的原因，文件已照常替换
+}
+
+// convertLineEndings 把内容中的 LF、CRLF 和单独的 CR 统一为目标行结束符。
+// 最后一行没有行结束符时保持原样。
+func convertLineEndings(data []byte, style string) ([]byte, int) {
+	want := eolTerminator(style)
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+	lines := 0
+	for i := 0; i < len(data); i++ {
+		var got []byte
+		switch {
+		case data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			got = data[i : i+2]
+			i++
+		case data[i] == '\r' || data[i] == '\n':
+			got = data[i : i+1]
+		default:
+			out.WriteByte(data[i])
+			continue
+		}
+
+		if !bytes.Equal(got, want) {
+			lines++
+		}
+		out.Write(want)
+	}
+	return out.Bytes(), lines
+}
+
+// rewriteWholeFile 读入整个文件，用 convert 改写后写回；convert 返回改写后的内容和改变的处数。
+// 没有需要改变的内容时不写文件，避免无谓地改动修改时间；write 为 false 时只统计。
+func rewriteWholeFile(fsys FileSystem, filePath, tempDir string, convert func([]byte) ([]byte, int), write bool, opts writeOptions, stats *IOStats) (result wholeResult, err error) {
+	file, err := stats.open(fsys, filePath)
+	if err != nil {
+		return result, err
+	}
+	data, err := io.ReadAll(stats.reader(file))
+	file.Close()
+	if err != nil {
+		return result, err
+	}
+
+	converted, changes := convert(data)
+	result = wholeResult{Changes: changes, BytesBefore: int64(len(data)), BytesAfter: int64(len(converted))}
+	result.Unchanged = changes > 0 && bytes.Equal(converted, data)
+	if changes == 0 || result.Unchanged || !write {
+		return result, nil
+	}
+
+	outputFile, err := createTempFile(fsys, filePath, tempDir)
+	if err != nil {
+		return result, err
+	}
+	stats.addOpen()
+	tempFile := outputFile.Name()
+
+	// 失败时不留下临时文件
+	defer func() {
+		if err != nil {
+			discardTemp(fsys, outputFile)
+		}
+	}()
+
+	verifier := newWriteVerifier(opts.verify)
+	writer := bufio.NewWriter(verifier.wrap(outputFile))
+	n, err := writer.Write(converted)
+	stats.addWritten(int64(n))
+	if err != nil {
+		return result, err
+	}
+	if err = writer.Flush(); err != nil {
+		return result, err
+	}
+	if err = verifier.finish(fsys, outputFile, int64(n), stats); err != nil {
+		return result, err
+	}
+
+	result.OwnerErr, err = commitTempFile(fsys, tempFile, filePath, opts)
+	return result, err
+}
+
+// processLineEndings 在换行符转换模式下处理单个文件
+func processLineEndings(config *Config, result *Result, filePath string, opts writeOptions) error {
+	convert := func(data []byte) ([]byte, int) { return convertLineEndings(data, config.EOL) }
+	return processWholeFile(config, result, config.FS, filePath, convert, "转换 %s 文件的换行符", opts)
+}
+
+// processWholeFile 处理一个整个读入后改写的文件：计数、备份、写回和报告。
+// action 是带一个 %s（文件路径）的操作描述，用于错误信息。
+func processWholeFile(config *Config, result *Result, fsys FileSystem, filePath string, convert func([]byte) ([]byte, int), action string, opts writeOptions) error {
+	if config.unwritable[filePath] {
+		countSkip(result, SkipUnwritable)
+		config.Reporter.FileSkipped(filePath, false, SkipUnwritable)
+		return nil
+	}
+
+	if !config.Trial {
+		size := int64(0)
+		if info, err := config.FS.Lstat(filePath); err == nil {
+			size = info.Size()
+		}
+		release, ok := reserveTempSpace(config, result, filePath, size)
+		if !ok {
+			countSkip(result, SkipNoSpace)
+			config.Reporter.FileSkipped(filePath, false, SkipNoSpace)
+			return nil
+		}
+		defer release()
+	}
+
+	// With a journal or backups the original is saved first, so count
+	// before writing
+	write := !config.Trial && config.journal == nil && config.Backup == ""
+	rewrite, err := rewriteWholeFile(fsys, filePath, config.TempDir, convert, write, opts, &result.IO)
+	if err != nil && isNoSpace(err) {
+		countSkip(result, SkipNoSpace)
+		config.Reporter.FileSkipped(filePath, false, SkipNoSpace)
+		return nil
+	}
+	if skipVanished(config, result, filePath, err) {
+		return nil
+	}
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		return fmt.Errorf(action+"时发生错误: %w", filePath, err)
+	}
+
+	if rewrite.Changes == 0 {
+		return nil
+	}
+
+	// Every match already reads as its replacement
+	if rewrite.Unchanged && !config.searchOnly {
+		countSkip(result, SkipUnchanged)
+		config.Reporter.FileSkipped(filePath, false, SkipUnchanged)
+		return nil
+	}
+
+	if !write && !config.Trial {
+		backup, err := config.journal.save(config.FS, filePath)
+		if skipVanished(config, result, filePath, err) {
+			return nil
+		}
+		if err != nil {
+			atomic.AddInt32(&result.Errors, 1)
+			return fmt.Errorf("记录 %s 的原始内容时发生错误: %w", filePath, err)
+		}
+		backupPath, err := writeBackup(config, filePath)
+		if skipVanished(config, result, filePath, err) {
+			return nil
+		}
+		if err != nil {
+			atomic.AddInt32(&result.Errors, 1)
+			return fmt.Errorf("备份 %s 时发生错误: %w", filePath, err)
+		}
+		rewrite, err = rewriteWholeFile(fsys, filePath, config.TempDir, convert, true, opts, &result.IO)
+		if err != nil {
+			discardBackup(config, backupPath)
+		} else if backupPath != "" {
+			atomic.AddInt32(&result.Backups, 1)
+		}
+		if skipVanished(config, result, filePath, err) {
+			return nil
+		}
+		if err != nil {
+			atomic.AddInt32(&result.Errors, 1)
+			return fmt.Errorf(action+"时发生错误: %w", filePath, err)
+		}
+		if err := config.journal.record(config.FS, filePath, backup); err != nil {
+			atomic.AddInt32(&result.Errors, 1)
+			config.Reporter.Error(filePath, fmt.Errorf("登记撤销日志 %s 时发生错误: %w", filePath, err))
+		}
+	}
+
+	if rewrite.OwnerErr != nil {
+		config.Reporter.Notice(filePath, fmt.Sprintf("无法保留原文件的所有者（%v）", rewrite.OwnerErr))
+	}
+
+	delta := rewrite.BytesAfter - rewrite.BytesBefore
+	atomic.AddInt32(&result.Matches, int32(rewrite.Changes))
+	atomic.AddInt32(&result.FilesMatches, 1)
+	atomic.AddInt64(&result.SizeDelta, delta)
+
+	event := FileEvent{Path: filePath, Matches: rewrite.Changes, Delta: delta}
+	if config.Trial {
+		config.Reporter.FileMatched(event)
+		return nil
+	}
+
+	event.BytesBefore = rewrite.BytesBefore
+	event.BytesAfter = rewrite.BytesAfter
+	config.Reporter.FileReplaced(event)
+	return nil
+}
+
+// formatEOLStyle 返回便于显示的换行符风格名称
+func formatEOLStyle(style string) string {
+	return strings.ToUpper(style)
+}
+
+// EOLStyle 是文件换行符风格的分类
+type EOLStyle int
+
+const (
+	EOLStyleNone  EOLStyle = iota // 文件中没有行结束符
+	EOLStyleLF
+	EOLStyleCRLF
+	EOLStyleCR
+	EOLStyleMixed
+	eolStyleCount
+)
+
+var eolStyleKeys = [eolStyleCount]string{"none", "lf", "crlf", "cr", "mixed"}
+var eolStyleNames = [eolStyleCount]string{"无换行", "LF", "CRLF", "CR", "混合"}
+
+// Key 返回用于 JSON 等机器可读输出的稳定名称
+func (s EOLStyle) Key() string {
+	return eolStyleKeys[s]
+}
+
+func (s EOLStyle) String() string {
+	return eolStyleNames[s]
+}
+
+// EOLInfo 是单个文件的换行符统计
+type EOLInfo struct {
+	Style        EOLStyle
+	LF           int
+	CRLF         int
+	CR           int
+	FinalNewline bool // 最后一行以行结束符结尾（空文件视为是）
+}
+
+// classifyLineEndings 统计内容中各种行结束符的数量并归类
+func classifyLineEndings(data []byte) EOLInfo {
+	var info EOLInfo
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			info.CRLF++
+			i++
+		case data[i] == '\r':
+			info.CR++
+		case data[i] == '\n':
+			info.LF++
+		}
+	}
+
+	info.FinalNewline = len(data) == 0 || data[len(data)-1] == '\n' || data[len(data)-1] == '\r'
+
+	kinds := 0
+	for style, n := range map[EOLStyle]int{EOLStyleLF: info.LF, EOLStyleCRLF: info.CRLF, EOLStyleCR: info.CR} {
+		if n > 0 {
+			info.Style = style
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		info.Style = EOLStyleMixed
+	}
+	return info
+}
+
+// surveyLineEndings 在换行符报告模式下统计单个文件，不做任何修改
+func surveyLineEndings(config *Config, result *Result, filePath string) error {
+	file, err := result.IO.open(config.FS, filePath)
+	if skipVanished(config, result, filePath, err) {
+		return nil
+	}
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		return fmt.Errorf("检查文件 %s 时发生错误: %w", filePath, err)
+	}
+	data, err := io.ReadAll(result.IO.reader(file))
+	file.Close()
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		return fmt.Errorf("检查文件 %s 时发生错误: %w", filePath, err)
+	}
+
+	info := classifyLineEndings(data)
+	atomic.AddInt32(&result.EOLStyles[info.Style], 1)
+	if !info.FinalNewline {
+		atomic.AddInt32(&result.NoFinalNewline, 1)
+	}
+
+	config.Reporter.FileLineEndings(filePath, info)
+	return nil
+}
+
+// formatEOLStyles 生成换行符风格分布，如 "LF 12, CRLF 3"
+func formatEOLStyles(result *Result) string {
+	var parts []string
+	for style := EOLStyle(0); style < eolStyleCount; style++ {
+		if n := atomic.LoadInt32(&result.EOLStyles[style]); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", style, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 错误类别：返回的错误用 errors.Is 与这些值比较，错误信息本身保留完整的细节
+var (
+	ErrInvalidConfig = errors.New("参数无效")
+	ErrRefused       = errors.New("拒绝运行")   // 安全检查未通过：根目录、未提交的改动、锁被占用
+	ErrCanceled      = errors.New("已取消")    // 用户取消或预检未通过，未修改任何文件
+	ErrSetupFailed   = errors.New("准备运行失败") // 无法创建撤销日志、报告、审阅界面等
+	ErrWalkFailed    = errors.New("遍历目录失败")
+	ErrGitFailed     = errors.New("git 操作失败")
+	ErrNetwork       = errors.New("网络错误") // 网络共享的故障，重试后仍然失败
+)
+
+// 运行结果：运行完成但需要以非零退出码结束，由 main 映射为退出码
+var (
+	ErrCapReached      = errors.New("已达到替换总数上限")
+	ErrMaxFilesReached = errors.New("已达到文件数上限")
+	ErrHookFailed      = errors.New("完成钩子失败")
+	ErrFailFast        = errors.New("出错后已按 --fail-fast 中止")
+	ErrStillPresent    = errors.New("源字符串仍然存在")
+	ErrNoMatches       = errors.New("没有找到匹配")
+	ErrFindFailed      = errors.New("查找出错且没有找到匹配")
+	ErrInterrupted     = errors.New("运行被中断")
+)
+
+// kindError 给错误加上类别，Error() 只返回原来的错误信息
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// withKind 把 err 归入 kind 类别，err 为 nil 时返回 nil
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// kindErrorf 按格式生成属于 kind 类别的错误，支持 %w
+func kindErrorf(kind error, format string, args ...any) error {
+	return withKind(kind, fmt.Errorf(format, args...))
+}
+
+// configError 生成参数无效的错误
+func configError(format string, args ...any) error {
+	return kindErrorf(ErrInvalidConfig, format, args...)
+}
+
+// exitStatus 把运行结果映射为退出码；quiet 的结果已经体现在输出中，不再打印
+var exitStatus = []struct {
+	err   error
+	code  int
+	quiet bool
+}{
+	{ErrStillPresent, ExitStillPresent, true},
+	{ErrNoMatches, ExitNoMatches, true},
+	{ErrFindFailed, ExitFindError, true},
+	{ErrNotReversible, ExitNotReversible, true},
+	{ErrCapReached, ExitCapReached, true},
+	{ErrMaxFilesReached, ExitMaxFilesReached, true},
+	{ErrHookFailed, ExitHookFailed, true},
+	{ErrFailFast, ExitFailFast, false},
+	{ErrInterrupted, ExitInterrupted, true},
+}
+
+// exitCode 返回 err 对应的退出码，以及是否需要打印错误信息
+func exitCode(err error) (code int, print bool) {
+	for _, s := range exitStatus {
+		if errors.Is(err, s.err) {
+			return s.code, !s.quiet
+		}
+	}
+	return 1, true
+}
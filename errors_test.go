@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// 类别不改变错误信息，并且类别和原错误都能用 errors.Is 判断
+func TestKindError(t *testing.T) {
+	if withKind(ErrRefused, nil) != nil {
+		t.Error("withKind(nil) 应为 nil")
+	}
+
+	inner := errors.New("磁盘已满")
+	err := kindErrorf(ErrSetupFailed, "创建报告时发生错误: %w", inner)
+	if err.Error() != "创建报告时发生错误: 磁盘已满" {
+		t.Errorf("错误信息 = %q", err.Error())
+	}
+	if !errors.Is(err, ErrSetupFailed) || !errors.Is(err, inner) {
+		t.Error("应同时匹配类别和原错误")
+	}
+	if errors.Is(err, ErrInvalidConfig) {
+		t.Error("不应匹配其他类别")
+	}
+
+	// Wrapping again keeps the kind visible
+	if !errors.Is(fmt.Errorf("运行失败: %w", err), ErrSetupFailed) {
+		t.Error("再次包装后类别丢失")
+	}
+}
+
+// 每种运行结果映射为各自的退出码，其他错误为 1
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err   error
+		code  int
+		print bool
+	}{
+		{ErrStillPresent, ExitStillPresent, false},
+		{ErrNoMatches, ExitNoMatches, false},
+		{ErrFindFailed, ExitFindError, false},
+		{ErrNotReversible, ExitNotReversible, false},
+		{ErrCapReached, ExitCapReached, false},
+		{ErrMaxFilesReached, ExitMaxFilesReached, false},
+		{ErrHookFailed, ExitHookFailed, false},
+		{kindErrorf(ErrFailFast, "--fail-fast: %s", "a.txt"), ExitFailFast, true},
+		{configError("工人数必须大于0"), 1, true},
+		{withKind(ErrRefused, errors.New("锁被占用")), 1, true},
+		{errors.New("其他错误"), 1, true},
+	}
+	for _, tt := range tests {
+		code, print := exitCode(tt.err)
+		if code != tt.code || print != tt.print {
+			t.Errorf("exitCode(%v) = %d, %v，应为 %d, %v", tt.err, code, print, tt.code, tt.print)
+		}
+		if wrapped, _ := exitCode(fmt.Errorf("外层: %w", tt.err)); wrapped != tt.code {
+			t.Errorf("包装后的 %v 退出码为 %d，应为 %d", tt.err, wrapped, tt.code)
+		}
+	}
+}
+
+// 参数校验失败都归入 ErrInvalidConfig
+func TestValidationSentinel(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      func(c *Config)
+		validate func() error
+	}{
+		{"workers", func(c *Config) { c.Workers = 0 }, validateMatchFlags},
+		{"multiline-regex", func(c *Config) { c.SourceString, c.Regex = "a\r\nb", true }, validateMatchFlags},
+		{"multiline-diff", func(c *Config) { c.SourceString, c.Diff = "a\nb", true }, validateMatchFlags},
+		{"trim", func(c *Config) { c.Trim = true }, validateMatchFlags},
+		{"anchor", func(c *Config) { c.Anchor = "middle" }, validateMatchFlags},
+		{"regex", func(c *Config) { c.SourceString, c.Regex = "(", true }, validateMatchFlags},
+		{"encoding", func(c *Config) { c.Encoding = "ebcdic" }, validateMatchFlags},
+		{"occurrences", func(c *Config) { c.Occurrences = "none" }, validateMatchFlags},
+		{"nth-all", func(c *Config) { c.Nth, c.Occurrences = 2, "all" }, validateMatchFlags},
+		{"nth-occurrences", func(c *Config) { c.Nth, c.Occurrences = 2, "3" }, validateMatchFlags},
+		{"detab", func(c *Config) { c.Detab = -1 }, validateReplaceFlags},
+		{"eol", func(c *Config) { c.EOL = "cr" }, validateReplaceFlags},
+		{"from", func(c *Config) { c.SourceString = "" }, validateReplaceFlags},
+		{"to", func(c *Config) { c.TargetString = "" }, validateReplaceFlags},
+		{"swap", func(c *Config) { c.TargetString, c.Swap = "a", true }, validateReplaceFlags},
+		{"max-total", func(c *Config) { c.MaxTotal = -1 }, validateReplaceFlags},
+	}
+	defer func() { cfg = Config{} }()
+
+	// The base config itself is valid, so each case fails for its own reason
+	defaultConfig(t)
+	if err := validateReplaceFlags(); err != nil {
+		t.Fatalf("基础配置: %v", err)
+	}
+	if err := validateMatchFlags(); err != nil {
+		t.Fatalf("基础配置: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultConfig(t)
+			tt.set(&cfg)
+			err := tt.validate()
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("错误 = %v，应属于 ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+// defaultConfig 把 cfg 重置为命令行参数的默认值，再设置 --from a --to b
+func defaultConfig(t *testing.T) {
+	t.Helper()
+	cfg = Config{}
+	reset := func(f *pflag.Flag) {
+		// Slices already default to nil; Set would append to them
+		if strings.HasSuffix(f.Value.Type(), "Slice") || strings.HasSuffix(f.Value.Type(), "Array") {
+			return
+		}
+		if err := f.Value.Set(f.DefValue); err != nil {
+			t.Fatalf("--%s: %v", f.Name, err)
+		}
+	}
+	rootCmd.PersistentFlags().VisitAll(reset)
+	rootCmd.Flags().VisitAll(reset)
+	cfg.NoPrompt = true
+	cfg.SourceString, cfg.TargetString = "a", "b"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ExitFailFast 是 --fail-fast 在第一个错误后中止时的退出码
+const ExitFailFast = 6
+
+// failFast 记录让运行中止的第一个错误
+type failFast struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// failFastReporter 把第一个错误登记到 Result 并让遍历和工人停止领取新文件。
+// 正在处理的文件照常完成：写入失败的文件由原有的错误处理删除临时文件，
+// 目标文件只会通过重命名整体替换，不会留下写了一半的内容。
+type failFastReporter struct {
+	Reporter
+	result *Result
+}
+
+func (r failFastReporter) Error(path string, err error) {
+	abortRun(r.result, path, err)
+	r.Reporter.Error(path, err)
+}
+
+// abortRun 登记第一个错误，之后的错误只照常报告
+func abortRun(result *Result, path string, err error) {
+	result.failFast.once.Do(func() {
+		result.failFast.path, result.failFast.err = path, err
+		atomic.StoreInt32(&result.Aborted, 1)
+	})
+}
+
+// aborted 判断运行是否已因 --fail-fast 中止
+func aborted(result *Result) bool {
+	return atomic.LoadInt32(&result.Aborted) != 0
+}
+
+// FailFastSummary 描述让运行中止的错误
+type FailFastSummary struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// summarizeFailFast 在运行因 --fail-fast 中止时返回中止原因
+func summarizeFailFast(result *Result) *FailFastSummary {
+	if !aborted(result) {
+		return nil
+	}
+	return &FailFastSummary{Path: result.failFast.path, Error: result.failFast.err.Error()}
+}
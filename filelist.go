@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// fileListSource 返回 --files 或 --files0 指定的列表（"-" 为标准输入）及其分隔符
+func fileListSource(config *Config) (string, byte) {
+	if config.Files0 != "" {
+		return config.Files0, 0
+	}
+	return config.Files, '\n'
+}
+
+// queueFileList 读取路径列表，把其中的文件直接交给工人而不遍历目录。
+// 列表中的相对路径相对于当前目录；不存在的路径和目录记为错误，不中止运行。
+// 列表中的文件不算明确指定，隐藏文件、二进制文件等过滤照常适用。
+func queueFileList(config *Config, result *Result, queue *workQueue) error {
+	name, sep := fileListSource(config)
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("打开文件列表 %s 时发生错误: %w", name, err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	seen := make(map[string]bool)
+	reader := bufio.NewReader(in)
+	for {
+		line, readErr := reader.ReadBytes(sep)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("读取文件列表时发生错误: %w", readErr)
+		}
+		line = bytes.TrimSuffix(line, []byte{sep})
+		if sep == '\n' {
+			line = bytes.TrimSuffix(line, []byte{'\r'})
+		}
+
+		if len(line) > 0 {
+			if stopAtCap(result) || aborted(result) || interruptRequested(config) {
+				return nil
+			}
+			if err := considerListed(config, result, string(line), seen, queue); err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil {
+			return nil
+		}
+	}
+}
+
+// considerListed 检查列表中的一个路径，是普通文件时交给 considerFile
+func considerListed(config *Config, result *Result, listed string, seen map[string]bool, queue *workQueue) error {
+	path, err := absPath(listed)
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		config.Reporter.Error(listed, fmt.Errorf("无法获取路径 %s 的绝对路径: %w", listed, err))
+		return nil
+	}
+
+	// The same file twice would have two workers rewrite it at once
+	key := path
+	if isCaseInsensitiveFS() {
+		key = strings.ToLower(path)
+	}
+	if seen[key] {
+		return nil
+	}
+	seen[key] = true
+
+	info, err := config.FS.Lstat(path)
+	switch {
+	case err != nil:
+		atomic.AddInt32(&result.Errors, 1)
+		config.Reporter.Error(path, fmt.Errorf("文件列表中的路径 %s 无法访问: %w", listed, err))
+		return nil
+	case info.IsDir():
+		atomic.AddInt32(&result.Errors, 1)
+		config.Reporter.Error(path, fmt.Errorf("文件列表中的路径 %s 是目录，文件列表只接受文件", listed))
+		return nil
+	case !info.Mode().IsRegular() && info.Mode()&fs.ModeSymlink == 0:
+		atomic.AddInt32(&result.Errors, 1)
+		config.Reporter.Error(path, fmt.Errorf("文件列表中的路径 %s 不是普通文件", listed))
+		return nil
+	}
+	return considerFile(config, result, path, fs.FileInfoToDirEntry(info), false, queue)
+}
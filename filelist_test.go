@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --files 中的文件直接交给工人：不遍历目录，不存在的路径和目录记为错误而不中止，
+// 重复的路径只处理一次，隐藏文件和二进制文件的过滤照常适用
+func TestFileListRun(t *testing.T) {
+	for _, sep := range []string{"\n", "\r\n", "\x00"} {
+		dir := t.TempDir()
+		a := writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+		writeTestFile(t, dir, "b.txt", "foo\n", 0o644) // not listed
+		hidden := writeTestFile(t, dir, ".hidden.txt", "foo\n", 0o644)
+		binary := writeTestFile(t, dir, "data", "foo\x00\n", 0o644)
+		sub := filepath.Join(dir, "sub")
+		if err := os.Mkdir(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		// A relative path is relative to the current directory
+		t.Chdir(dir)
+		entries := []string{a, "a.txt", hidden, binary, filepath.Join(dir, "missing.txt"), sub, ""}
+		list := writeTestFile(t, t.TempDir(), "list", strings.Join(entries, sep)+sep, 0o644)
+
+		config := &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Files: list}
+		if sep == "\x00" {
+			config.Files, config.Files0 = "", list
+		}
+		result := runTest(t, config)
+
+		if result.FilesFound != 1 || result.FilesMatches != 1 {
+			t.Errorf("分隔符 %q: 发现 %d 个、修改 %d 个文件，应均为 1 个", sep, result.FilesFound, result.FilesMatches)
+		}
+		if result.Errors != 2 {
+			t.Errorf("分隔符 %q: 错误 %d 个，应为 2 个（不存在的文件和目录）", sep, result.Errors)
+		}
+		if result.Skipped[SkipHidden] != 1 || result.Skipped[SkipBinary] != 1 {
+			t.Errorf("分隔符 %q: 跳过隐藏 %d 个、二进制 %d 个，应均为 1 个", sep, result.Skipped[SkipHidden], result.Skipped[SkipBinary])
+		}
+		if got := readTestFile(t, a); got != "bar\n" {
+			t.Errorf("分隔符 %q: a.txt 替换后 %q", sep, got)
+		}
+		if got := readTestFile(t, filepath.Join(dir, "b.txt")); got != "foo\n" {
+			t.Errorf("分隔符 %q: 不在列表中的 b.txt 被替换", sep)
+		}
+	}
+}
+
+// --files - 从标准输入读取列表
+func TestFileListStdin(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+	list := writeTestFile(t, t.TempDir(), "list", a+"\n", 0o644)
+
+	stdin, err := os.Open(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	saved := os.Stdin
+	os.Stdin = stdin
+	t.Cleanup(func() { os.Stdin = saved })
+
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Files: "-"})
+	if result.FilesMatches != 1 || readTestFile(t, a) != "bar\n" {
+		t.Errorf("修改 %d 个文件，a.txt 为 %q", result.FilesMatches, readTestFile(t, a))
+	}
+}
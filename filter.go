@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter 是 --include/--exclude 的文件名过滤。模式与相对于源目录、以 / 分隔的
+// 路径比较：不含 / 的模式只匹配最后一级名字（*.go 匹配任意深度的 Go 文件），
+// 含 / 的模式逐级匹配整个路径，其中 ** 匹配任意多级（包括零级）。
+// --exclude 优先于 --include，匹配 --exclude 的目录整个不再进入。
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+// newPathFilter 校验并创建过滤器，两种模式都没有时返回 nil
+func newPathFilter(include, exclude []string) (*pathFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	f := &pathFilter{}
+	for _, list := range []struct {
+		flag     string
+		patterns []string
+		into     *[]string
+	}{{"--include", include, &f.include}, {"--exclude", exclude, &f.exclude}} {
+		for _, p := range list.patterns {
+			p = strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(p), "./"), "/")
+			if p == "" {
+				return nil, configError("%s 的模式不能为空", list.flag)
+			}
+			for _, seg := range strings.Split(p, "/") {
+				if _, err := path.Match(seg, ""); err != nil {
+					return nil, configError("无效的 %s 模式: %s", list.flag, p)
+				}
+			}
+			*list.into = append(*list.into, p)
+		}
+	}
+	return f, nil
+}
+
+// allowsFile 判断文件是否通过过滤，root 为源目录
+func (f *pathFilter) allowsFile(root, filePath string) bool {
+	if f == nil {
+		return true
+	}
+	rel := filterPath(root, filePath)
+	if matchAny(f.exclude, rel) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+// excludesDir 判断目录是否被 --exclude 整个排除
+func (f *pathFilter) excludesDir(root, dir string) bool {
+	return f != nil && matchAny(f.exclude, filterPath(root, dir))
+}
+
+// filterPath 返回用于匹配的路径：源目录下的路径取相对路径，其余取去掉卷名的绝对路径
+func filterPath(root, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(p, filepath.VolumeName(p))), "/")
+}
+
+// matchAny 判断路径是否匹配其中任一模式
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if !strings.Contains(p, "/") {
+			if ok, _ := path.Match(p, path.Base(rel)); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(p, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 逐级匹配，** 匹配任意多级
+func matchSegments(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pattern[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 不含 / 的模式匹配最后一级名字，含 / 的模式逐级匹配，** 匹配任意多级；--exclude 优先
+func TestPathFilter(t *testing.T) {
+	tests := []struct {
+		include []string
+		exclude []string
+		path    string
+		want    bool
+	}{
+		{nil, nil, "a.go", true},
+		{[]string{"*.go"}, nil, "a.go", true},
+		{[]string{"*.go"}, nil, "pkg/sub/a.go", true},
+		{[]string{"*.go"}, nil, "a.md", false},
+		{[]string{"*.go", "*.md"}, nil, "docs/a.md", true},
+		{[]string{"*.go", "*.md"}, nil, "a.txt", false},
+		{[]string{"*.go"}, []string{"*_test.go"}, "a_test.go", false},
+		{[]string{"*.go"}, []string{"*_test.go"}, "a.go", true},
+		{nil, []string{"*.log"}, "logs/a.log", false},
+		{nil, []string{"*.log"}, "a.txt", true},
+		// Patterns with a slash match the whole relative path
+		{[]string{"cmd/*.go"}, nil, "cmd/a.go", true},
+		{[]string{"cmd/*.go"}, nil, "cmd/sub/a.go", false},
+		{[]string{"cmd/*.go"}, nil, "x/cmd/a.go", false},
+		{[]string{"cmd/**/*.go"}, nil, "cmd/a.go", true},
+		{[]string{"cmd/**/*.go"}, nil, "cmd/x/y/a.go", true},
+		{nil, []string{"**/testdata/**"}, "testdata/a.txt", false},
+		{nil, []string{"**/testdata/**"}, "pkg/testdata/deep/a.txt", false},
+		{nil, []string{"**/testdata/**"}, "pkg/testdata.txt", true},
+		{nil, []string{"./vendor/"}, "vendor/a.go", true},
+		{nil, []string{"vendor/**"}, "vendor/a.go", false},
+	}
+	root := filepath.FromSlash("/src")
+	for _, tt := range tests {
+		f, err := newPathFilter(tt.include, tt.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.allowsFile(root, filepath.Join(root, filepath.FromSlash(tt.path))); got != tt.want {
+			t.Errorf("--include %q --exclude %q: %s 通过 = %v，应为 %v", tt.include, tt.exclude, tt.path, got, tt.want)
+		}
+	}
+}
+
+// 匹配 --exclude 的目录整个跳过；--include 不影响目录
+func TestPathFilterExcludesDir(t *testing.T) {
+	tests := []struct {
+		include []string
+		exclude []string
+		dir     string
+		want    bool
+	}{
+		{nil, []string{"vendor"}, "vendor", true},
+		{nil, []string{"vendor"}, "a/vendor", true},
+		{nil, []string{"vendor"}, "vendors", false},
+		{nil, []string{"**/testdata/**"}, "testdata", true},
+		{nil, []string{"**/testdata/**"}, "a/b/testdata", true},
+		{nil, []string{"**/testdata/**"}, "a/testdata-old", false},
+		{nil, []string{"build/out"}, "build/out", true},
+		{nil, []string{"build/out"}, "x/build/out", false},
+		{[]string{"*.go"}, nil, "pkg", false},
+	}
+	root := filepath.FromSlash("/src")
+	for _, tt := range tests {
+		f, err := newPathFilter(tt.include, tt.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.excludesDir(root, filepath.Join(root, filepath.FromSlash(tt.dir))); got != tt.want {
+			t.Errorf("--exclude %q: 目录 %s 排除 = %v，应为 %v", tt.exclude, tt.dir, got, tt.want)
+		}
+	}
+}
+
+// 空模式和无效的模式在运行前报错；都没有给出时不创建过滤器
+func TestNewPathFilter(t *testing.T) {
+	if f, err := newPathFilter(nil, nil); f != nil || err != nil {
+		t.Errorf("没有模式时 = %v, %v，应为 nil", f, err)
+	}
+	for _, tt := range []struct{ include, exclude []string }{
+		{[]string{""}, nil},
+		{nil, []string{"./"}},
+		{[]string{"[a"}, nil},
+		{nil, []string{"dir/[/x"}},
+	} {
+		if _, err := newPathFilter(tt.include, tt.exclude); err == nil {
+			t.Errorf("--include %q --exclude %q 应报错", tt.include, tt.exclude)
+		}
+	}
+}
+
+// 被过滤的文件不计入 FilesFound；被排除的目录只计一次跳过，其中的文件不再检查
+func TestFilterRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"pkg/testdata/deep", "docs"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.go", "a_test.go", "pkg/b.go", "docs/c.md", "notes.txt",
+		"pkg/testdata/d.go", "pkg/testdata/deep/e.go"} {
+		writeTestFile(t, dir, filepath.FromSlash(name), "foo\n", 0o644)
+	}
+	filter, err := newPathFilter([]string{"*.go", "*.md"}, []string{"*_test.go", "**/testdata/**"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", filter: filter})
+	if result.FilesFound != 3 {
+		t.Errorf("找到 %d 个文件，应为 3", result.FilesFound)
+	}
+	// a_test.go, notes.txt and the testdata directory itself
+	if got := result.Skipped[SkipFiltered]; got != 3 {
+		t.Errorf("过滤跳过 %d 项，应为 3", got)
+	}
+	for name, want := range map[string]string{
+		"a.go": "bar\n", "pkg/b.go": "bar\n", "docs/c.md": "bar\n",
+		"a_test.go": "foo\n", "notes.txt": "foo\n", "pkg/testdata/d.go": "foo\n", "pkg/testdata/deep/e.go": "foo\n",
+	} {
+		if got := readTestFile(t, filepath.Join(dir, filepath.FromSlash(name))); got != want {
+			t.Errorf("%s 的内容为 %q，应为 %q", name, got, want)
+		}
+	}
+}
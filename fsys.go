@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem 抽象处理过程中用到的文件系统操作。
+// 默认实现 osFS 直接使用 os 包；测试或嵌入方可以替换为内存实现，
+// 或注入权限错误、磁盘已满、重命名失败等故障。
+type FileSystem interface {
+	// Open 以只读方式打开文件
+	Open(name string) (io.ReadCloser, error)
+	// CreateTemp 在 dir 中创建临时文件，语义同 os.CreateTemp
+	CreateTemp(dir, pattern string) (TempFile, error)
+	// CreateExcl 以 perm 权限创建新文件，文件已存在时返回 fs.ErrExist
+	CreateExcl(name string, perm fs.FileMode) (TempFile, error)
+	// Rename 用 oldpath 原子地替换 newpath
+	Rename(oldpath, newpath string) error
+	// Remove 删除文件
+	Remove(name string) error
+	// Lstat 获取文件信息，不跟随符号链接
+	Lstat(name string) (fs.FileInfo, error)
+	// WalkDir 遍历目录树，语义同 filepath.WalkDir
+	WalkDir(root string, fn fs.WalkDirFunc) error
+}
+
+// TempFile 是替换过程中写入的临时文件
+type TempFile interface {
+	io.Writer
+	io.Closer
+	Name() string
+}
+
+// osFS 基于 os 包的默认文件系统实现
+type osFS struct{}
+
+func (osFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (osFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	return createTemp(dir, pattern)
+}
+
+func (osFS) CreateExcl(name string, perm fs.FileMode) (TempFile, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+}
+
+func (osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (osFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 写入中途失败或重命名失败时，原文件保持不变，临时文件被删除
+func TestReplaceFaults(t *testing.T) {
+	content := strings.Repeat("foo bar baz\n", 4000) // 远大于写缓冲区
+	tests := []struct {
+		name string
+		fsys *faultFS
+	}{
+		{"write", &faultFS{failWriteAfter: 10}},
+		{"write-late", &faultFS{failWriteAfter: len(content) / 2}},
+		{"rename", &faultFS{failRename: errInjected}},
+		{"create", &faultFS{failCreate: "a.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestFile(t, dir, "a.txt", content, 0o644)
+
+			_, err := replaceTest(t, tt.fsys, path, newLiteralMatcher("foo", "qux"), writeOptions{})
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("错误 = %v，应为注入的故障", err)
+			}
+			if got := readTestFile(t, path); got != content {
+				t.Error("原文件被改动")
+			}
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+// 完整运行中，一个文件的故障只计为一个错误，不影响其他文件
+func TestRunRenameFault(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+	fsys := &faultFS{failRename: errInjected}
+
+	result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", FS: fsys})
+	if result.Errors != 1 || result.Matches != 0 {
+		t.Errorf("错误 %d 个、替换 %d 处，应为 1 和 0", result.Errors, result.Matches)
+	}
+	if fsys.renames == 0 {
+		t.Error("没有尝试重命名")
+	}
+	if got := readTestFile(t, path); got != "foo\n" {
+		t.Errorf("原文件被改动: %q", got)
+	}
+	assertNoTempFiles(t, dir)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitTopLevel 返回 dir 所在 git 工作树的根目录
+func gitTopLevel(dir string) (string, error) {
+	out, err := gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(strings.TrimSpace(out)), nil
+}
+
+// gitOutput 在 dir 中运行 git，stdin 不为 nil 时作为标准输入
+func gitOutput(dir string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// dirtyFiles 返回 root 所在 git 工作树中 root 之下已修改或已暂存、尚未提交的
+// 已跟踪文件。root 不在 git 仓库中（或没有安装 git）时返回 nil。
+func dirtyFiles(root string) []string {
+	dir := root
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		dir = filepath.Dir(root)
+	}
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return nil
+	}
+
+	out, err := gitOutput(top, nil, "status", "--porcelain", "--untracked-files=no", "--", root)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) > 3 {
+			files = append(files, line[3:])
+		}
+	}
+	return files
+}
+
+// checkCleanTree 拒绝修改有未提交改动的 git 工作树，列出前几个改动的文件
+func checkCleanTree(roots []string) error {
+	for _, root := range roots {
+		files := dirtyFiles(root)
+		if len(files) == 0 {
+			continue
+		}
+
+		shown := files
+		if len(shown) > 5 {
+			shown = shown[:5]
+		}
+		more := ""
+		if len(files) > len(shown) {
+			more = fmt.Sprintf(" 等 %d 个文件", len(files))
+		}
+		return fmt.Errorf("%s 所在的 git 工作树有未提交的改动（%s%s）；请先提交或 git stash，"+
+			"或使用 --allow-dirty 或 --force", root, strings.Join(shown, ", "), more)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// changeCollector 记录本次运行实际修改的文件，供 --git-commit 暂存
+type changeCollector struct {
+	silentReporter
+	mu    sync.Mutex
+	paths []string
+}
+
+func (c *changeCollector) FileReplaced(ev FileEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths = append(c.paths, ev.Path)
+}
+
+// gitCommitMessage 生成描述规则和数量的提交消息
+func gitCommitMessage(config *Config, result *Result, files int) string {
+	rules := ruleNames(config)
+	if rules == nil {
+		rules = []string{baseRuleName(config)}
+	}
+	if config.EOL != EOLNone {
+		rules = []string{"换行符 → " + formatEOLStyle(config.EOL)}
+	}
+	return fmt.Sprintf("reStr: %s\n\n修改 %d 个文件，共 %d %s.\n", strings.Join(rules, ", "), files, atomic.LoadInt32(&result.Matches), matchUnit(config))
+}
+
+// commitChanges 暂存本次运行修改的文件（不包括工作树中的其他改动），
+// 并只用这些文件创建提交
+func commitChanges(config *Config, result *Result, top string, changed *changeCollector) error {
+	if atomic.LoadInt32(&result.Errors) > 0 && !config.GitCommitForce {
+		fmt.Fprintln(os.Stderr, "运行中有错误，未创建 git 提交（可使用 --git-commit-force）")
+		return nil
+	}
+
+	var pathspec bytes.Buffer
+	files := 0
+	for _, path := range changed.paths {
+		rel, err := filepath.Rel(top, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			fmt.Fprintf(os.Stderr, "%s 不在 git 工作树 %s 中，未加入提交\n", escapeControl(path), top)
+			continue
+		}
+		pathspec.WriteString(filepath.ToSlash(rel))
+		pathspec.WriteByte(0)
+		files++
+	}
+	if files == 0 {
+		fmt.Fprintln(os.Stderr, "没有修改任何文件，未创建 git 提交")
+		return nil
+	}
+
+	message := config.GitCommitMessage
+	if message == "" {
+		message = gitCommitMessage(config, result, files)
+	}
+
+	spec := pathspec.Bytes()
+	if _, err := gitOutput(top, spec, "add", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+		return kindErrorf(ErrGitFailed, "暂存修改的文件时发生错误: %w", err)
+	}
+	if _, err := gitOutput(top, spec, "commit", "--quiet", "-m", message, "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+		return kindErrorf(ErrGitFailed, "创建 git 提交时发生错误: %w", err)
+	}
+
+	head, _ := gitOutput(top, nil, "rev-parse", "--short", "HEAD")
+	fmt.Fprintf(os.Stderr, "已创建 git 提交 %s（%d 个文件）\n", strings.TrimSpace(head), files)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// --git-commit 用生成的消息提交修改的文件；--git-commit-message 隐含 --git-commit，
+// 消息原样使用，"auto" 也不例外
+func TestGitCommitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(c *Config)
+		subject string
+	}{
+		{"生成的消息", func(c *Config) { c.GitCommit = true }, "reStr: a→b"},
+		{"指定的消息", func(c *Config) { c.GitCommitMessage = "Rename a" }, "Rename a"},
+		{"消息为 auto", func(c *Config) { c.GitCommitMessage = "auto" }, "auto"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { cfg = Config{} }()
+			dir := t.TempDir()
+			writeTestFile(t, dir, "a.txt", "a\n", 0o644)
+			writeTestFile(t, dir, "other.txt", "x\n", 0o644)
+			initGitRepo(t, dir)
+			// Unrelated edits stay out of the commit
+			writeTestFile(t, dir, "other.txt", "y\n", 0o644)
+
+			defaultConfig(t)
+			cfg.SourceDir = dir
+			cfg.AllowDirty = true
+			cfg.NoLock = true
+			cfg.NoHistory = true
+			cfg.Reporter = silentReporter{}
+			tt.set(&cfg)
+			if err := validateMatchFlags(); err != nil {
+				t.Fatal(err)
+			}
+			if err := runApp(nil); err != nil {
+				t.Fatal(err)
+			}
+
+			subject, err := gitOutput(dir, nil, "log", "-1", "--format=%s")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(subject); got != tt.subject {
+				t.Errorf("提交标题为 %q，应为 %q", got, tt.subject)
+			}
+			files, err := gitOutput(dir, nil, "show", "--name-only", "--format=", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(files); got != "a.txt" {
+				t.Errorf("提交的文件为 %q，应只有 a.txt", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirGroup 汇总一个子目录（相对于源目录）下的处理结果，Children 按 --group-depth 逐级展开
+type DirGroup struct {
+	Path         string           `json:"path"`
+	Files        int32            `json:"files"`
+	FilesMatched int32            `json:"filesMatched"`
+	Matches      int32            `json:"matches"`
+	Errors       int32            `json:"errors"`
+	Skipped      map[string]int32 `json:"skipped,omitempty"`
+	Children     []*DirGroup      `json:"children,omitempty"`
+}
+
+// dirGroups 在运行中按子目录累计计数，未启用时为 nil
+type dirGroups struct {
+	root  string
+	depth int
+	mu    sync.Mutex
+	nodes map[string]*DirGroup // 按相对路径索引的各级目录
+	top   []*DirGroup          // 第一级目录
+}
+
+// newDirGroups 创建按 depth 级子目录分组的统计，depth 为 0 时返回 nil
+func newDirGroups(root string, depth int) *dirGroups {
+	if depth <= 0 {
+		return nil
+	}
+	if abs, err := absPath(root); err == nil {
+		root = abs
+	}
+	return &dirGroups{root: root, depth: depth, nodes: make(map[string]*DirGroup)}
+}
+
+// keys 返回 path 所属的各级目录，第一级在前；直接位于源目录下的文件归入 "."
+func (g *dirGroups) keys(path string, isDir bool) []string {
+	rel := path
+	if abs, err := absPath(path); err == nil {
+		if r, err := filepath.Rel(g.root, abs); err == nil {
+			rel = r
+		}
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if !isDir {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 0 || parts[0] == "." {
+		return []string{"."}
+	}
+
+	keys := make([]string, 0, min(len(parts), g.depth))
+	for i := 1; i <= len(parts) && i <= g.depth; i++ {
+		keys = append(keys, strings.Join(parts[:i], "/"))
+	}
+	return keys
+}
+
+// update 对 path 所属的每一级目录调用 fn
+func (g *dirGroups) update(path string, isDir bool, fn func(*DirGroup)) {
+	if g == nil {
+		return
+	}
+	keys := g.keys(path, isDir)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var parent *DirGroup
+	for _, key := range keys {
+		node := g.nodes[key]
+		if node == nil {
+			node = &DirGroup{Path: key}
+			g.nodes[key] = node
+			if parent == nil {
+				g.top = append(g.top, node)
+			} else {
+				parent.Children = append(parent.Children, node)
+			}
+		}
+		fn(node)
+		parent = node
+	}
+}
+
+// summary 返回按匹配数从多到少排列的分组树
+func (g *dirGroups) summary() []*DirGroup {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sortGroups(g.top)
+	return g.top
+}
+
+func sortGroups(groups []*DirGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Matches != groups[j].Matches {
+			return groups[i].Matches > groups[j].Matches
+		}
+		return groups[i].Path < groups[j].Path
+	})
+	for _, g := range groups {
+		sortGroups(g.Children)
+	}
+}
+
+// groupReporter 把文件事件计入所属目录的分组统计，再交给原来的 Reporter
+type groupReporter struct {
+	Reporter
+	groups *dirGroups
+}
+
+func (r groupReporter) FileMatched(ev FileEvent) {
+	r.addMatched(ev)
+	r.Reporter.FileMatched(ev)
+}
+
+func (r groupReporter) FileReplaced(ev FileEvent) {
+	r.addMatched(ev)
+	r.Reporter.FileReplaced(ev)
+}
+
+func (r groupReporter) addMatched(ev FileEvent) {
+	r.groups.update(ev.Path, false, func(g *DirGroup) {
+		g.FilesMatched++
+		g.Matches += int32(ev.Matches)
+	})
+}
+
+func (r groupReporter) FileSkipped(path string, isDir bool, reason SkipReason) {
+	r.groups.update(path, isDir, func(g *DirGroup) {
+		if g.Skipped == nil {
+			g.Skipped = make(map[string]int32)
+		}
+		g.Skipped[reason.Key()]++
+	})
+	r.Reporter.FileSkipped(path, isDir, reason)
+}
+
+func (r groupReporter) FileScanned(path string, size int64, elapsed time.Duration) {
+	r.groups.update(path, false, func(g *DirGroup) { g.Files++ })
+	r.Reporter.FileScanned(path, size, elapsed)
+}
+
+func (r groupReporter) Error(path string, err error) {
+	r.groups.update(path, false, func(g *DirGroup) { g.Errors++ })
+	r.Reporter.Error(path, err)
+}
+
+// maxGroupsShown 是控制台汇总中最多列出的第一级目录数
+const maxGroupsShown = 10
+
+// formatGroups 输出第一级目录的汇总，只列出有匹配或错误的目录，按匹配数从多到少
+func formatGroups(groups []*DirGroup) string {
+	var shown []*DirGroup
+	for _, g := range groups {
+		if g.FilesMatched > 0 || g.Errors > 0 {
+			shown = append(shown, g)
+		}
+	}
+	// A lone "." group adds nothing to the totals above it
+	if len(shown) == 0 || len(shown) == 1 && shown[0].Path == "." {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n按目录:\n")
+	for i, g := range shown {
+		if i == maxGroupsShown {
+			fmt.Fprintf(&sb, "  … 另有 %d 个目录\n", len(shown)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "  %-24s 匹配文件 %d / %d, 匹配 %d, 错误 %d\n", escapeControl(g.Path), g.FilesMatched, g.Files, g.Matches, g.Errors)
+	}
+	return sb.String()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile 在 dir 中写入一个测试文件并返回其路径
+func writeTestFile(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readTestFile 读取文件内容
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// assertNoTempFiles 确认目录中没有残留的 reStr 临时文件
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if isTempFileName(e.Name()) {
+			t.Errorf("临时文件未清理: %s", e.Name())
+		}
+	}
+}
+
+// runTest 以静默输出、单个工人执行一次完整的运行
+func runTest(t *testing.T, config *Config) *Result {
+	t.Helper()
+	if config.Workers == 0 {
+		config.Workers = 1
+	}
+	config.NoLock = true
+	config.Reporter = silentReporter{}
+	result, err := Run(config)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return result
+}
+
+// replaceTest 用 matcher 替换 path，返回替换结果
+func replaceTest(t *testing.T, fsys FileSystem, path string, matcher Matcher, opts writeOptions) (rewriteResult, error) {
+	t.Helper()
+	return replaceInFile(fsys, path, "", matcher, 0, opts, &IOStats{})
+}
+
+// countAndReplace 在 content 上分别执行计数和替换，返回两次的匹配数和替换后的内容
+func countAndReplace(t *testing.T, content string, matcher Matcher) (counted, replaced int, out string) {
+	t.Helper()
+	path := writeTestFile(t, t.TempDir(), "input.txt", content, 0o644)
+
+	scan, err := fileContainsString(osFS{}, path, matcher, 0, 0, 0, &IOStats{})
+	if err != nil {
+		t.Fatalf("计数: %v", err)
+	}
+	rewrite, err := replaceTest(t, osFS{}, path, matcher, writeOptions{})
+	if err != nil && !errors.Is(err, errUnchanged) {
+		t.Fatalf("替换: %v", err)
+	}
+	return scan.Matches, rewrite.Replaced, readTestFile(t, path)
+}
+
+// faultFS 在指定文件上注入故障，其余操作交给 osFS
+type faultFS struct {
+	osFS
+	failCreate     string // 为名字含有它的文件创建临时文件时失败
+	failRename     error  // 非 nil 时 Rename 返回这个错误
+	failWriteAfter int    // 大于 0 时临时文件写入这么多字节后失败
+	renames        int    // Rename 被调用的次数
+}
+
+var errInjected = errors.New("注入的故障")
+
+func (f *faultFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	if f.failCreate != "" && strings.Contains(pattern, f.failCreate) {
+		return nil, errInjected
+	}
+	file, err := f.osFS.CreateTemp(dir, pattern)
+	if err != nil || f.failWriteAfter <= 0 {
+		return file, err
+	}
+	return &faultTemp{TempFile: file, left: f.failWriteAfter}, nil
+}
+
+func (f *faultFS) Rename(oldpath, newpath string) error {
+	f.renames++
+	if f.failRename != nil {
+		return f.failRename
+	}
+	return f.osFS.Rename(oldpath, newpath)
+}
+
+// faultTemp 写入 left 个字节后返回错误
+type faultTemp struct {
+	TempFile
+	left int
+}
+
+func (t *faultTemp) Write(p []byte) (int, error) {
+	if len(p) > t.left {
+		n, _ := t.TempFile.Write(p[:t.left])
+		t.left = 0
+		return n, errInjected
+	}
+	t.left -= len(p)
+	return t.TempFile.Write(p)
+}
+
+// copyFixtures 把 testdata/name 下的文件复制到临时目录并返回该目录，
+// 运行可以改写副本而不影响夹具本身
+func copyFixtures(t *testing.T, name string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(filepath.Join("testdata", name))); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// initGitRepo 把 dir 初始化为 git 仓库并提交其中的所有文件；没有 git 时跳过测试
+func initGitRepo(t *testing.T, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("没有 git")
+	}
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"commit", "-q", "-m", "init"}} {
+		if _, err := gitOutput(dir, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
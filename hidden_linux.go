@@ -3,11 +3,17 @@
 package main
 
 import (
-	"os"
+	"io/fs"
 	"strings"
 )
 // isHiddenUnix checks hidden attribute on Unix-like systems
-func isHiddenDir(path string, info os.FileInfo) (bool, error) {
+func isHiddenDir(path string, d fs.DirEntry) (bool, error) {
 	// On Unix, files starting with . are considered hidden
-	return strings.HasPrefix(info.Name(), "."), nil
+	return strings.HasPrefix(d.Name(), "."), nil
+}
+
+// isSystem reports whether an entry carries a system attribute; Unix-like
+// systems have no such attribute
+func isSystem(path string, d fs.DirEntry) (bool, error) {
+	return false, nil
 }
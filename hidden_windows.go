@@ -4,23 +4,46 @@ package main
 
 // 为Windows系统添加必要的导入
 import (
-	"os"
+	"io/fs"
 	"syscall"
 )
 
 // isHiddenWindows checks hidden attribute on Windows
-func isHiddenDir(path string, info os.FileInfo) (bool, error) {
+func isHiddenDir(path string, d fs.DirEntry) (bool, error) {
 	// On Windows, we need to check the FILE_ATTRIBUTE_HIDDEN flag
-	// This requires using syscall and the Windows API
-	pointer, err := syscall.UTF16PtrFromString(path)
+	attributes, err := fileAttributes(path, d)
 	if err != nil {
 		return false, err
 	}
 	
-	attributes, err := syscall.GetFileAttributes(pointer)
+	return attributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0, nil
+}
+
+// isSystem checks the FILE_ATTRIBUTE_SYSTEM flag (desktop.ini and friends)
+func isSystem(path string, d fs.DirEntry) (bool, error) {
+	attributes, err := fileAttributes(path, d)
 	if err != nil {
 		return false, err
 	}
 	
-	return attributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0, nil
-}
\ No newline at end of file
+	return attributes&syscall.FILE_ATTRIBUTE_SYSTEM != 0, nil
+}
+
+// fileAttributes returns the Windows file attributes of an entry
+func fileAttributes(path string, d fs.DirEntry) (uint32, error) {
+	// WalkDir already fetched the attributes while reading the directory,
+	// so reuse them when available instead of asking the Windows API again.
+	if info, err := d.Info(); err == nil {
+		if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+			return data.FileAttributes, nil
+		}
+	}
+
+	// This requires using syscall and the Windows API
+	pointer, err := syscall.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return 0, err
+	}
+	
+	return syscall.GetFileAttributes(pointer)
+}
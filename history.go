@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 运行历史保存在 .reStr/history/<运行时间>.json，每次替换运行一个文件
+const historyDirName = "history"
+
+// historyRecord 是一次运行的完整记录
+type historyRecord struct {
+	ID      string     `json:"id"`
+	Start   time.Time  `json:"start"`
+	End     time.Time  `json:"end"`
+	Status  string     `json:"status"`
+	Journal string     `json:"journal,omitempty"` // 撤销日志目录，没有修改任何文件时为空
+	Config  *Config    `json:"config"`
+	Summary RunSummary `json:"summary"`
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history [运行ID]",
+	Short: "列出之前的运行记录，或显示某次运行的详情",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHistory(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyDir 返回源目录下运行历史的目录
+func historyDir(sourceDir string) string {
+	return filepath.Join(sourceDir, stateDirName, historyDirName)
+}
+
+// writeHistory 记录一次运行。失败只给出警告，不影响运行结果。
+func writeHistory(config *Config, result *Result, start, end time.Time, status string) {
+	record := historyRecord{
+		ID:      start.Format(journalTimeFmt),
+		Start:   start,
+		End:     end,
+		Status:  status,
+		Config:  config,
+		Summary: summarize(config, result),
+	}
+	if config.journal != nil {
+		// The journal directory is removed again when nothing was changed
+		if _, err := os.Stat(config.journal.dir); err == nil {
+			record.Journal = config.journal.dir
+		}
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err == nil {
+		dir := historyDir(config.SourceDir)
+		if err = os.MkdirAll(dir, 0o755); err == nil {
+			err = writeFileAtomic(osFS{}, filepath.Join(dir, record.ID+".json"), append(data, '\n'))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "记录运行历史时发生错误: %v\n", err)
+	}
+}
+
+// readHistory 读取源目录下的全部运行记录，按时间排序
+func readHistory(sourceDir string) ([]historyRecord, error) {
+	entries, err := os.ReadDir(historyDir(sourceDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var records []historyRecord
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(historyDir(sourceDir), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var record historyRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			fmt.Fprintf(os.Stderr, "跳过无法解析的运行记录 %s: %v\n", entry.Name(), err)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// runHistory 列出运行记录；指定运行 ID 时输出该次运行的完整记录
+func runHistory(args []string) error {
+	absSourceDir, err := absPath(cfg.SourceDir)
+	if err != nil {
+		return fmt.Errorf("无法获取源目录的绝对路径: %w", err)
+	}
+
+	records, err := readHistory(absSourceDir)
+	if err != nil {
+		return fmt.Errorf("读取运行历史时发生错误: %w", err)
+	}
+
+	if len(args) == 1 {
+		for _, record := range records {
+			if record.ID == args[0] {
+				data, _ := json.MarshalIndent(record, "", "  ")
+				fmt.Println(string(data))
+				return nil
+			}
+		}
+		return fmt.Errorf("没有找到运行记录: %s", args[0])
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("%s 下没有运行记录\n", absSourceDir)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "运行ID\t开始时间\t模式\t匹配文件\t替换数\t错误\t状态\t替换")
+	for _, r := range records {
+		mode := "替换"
+		if r.Summary.Trial {
+			mode = "试验"
+		}
+		rule := ""
+		if r.Config != nil {
+			rule = escapeControl(r.Config.SourceString + "→" + r.Config.TargetString)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n", r.ID, r.Start.Format("2006-01-02 15:04:05"), mode,
+			r.Summary.FilesMatches, r.Summary.Matches, r.Summary.Errors, r.Status, rule)
+	}
+	w.Flush()
+	return nil
+}
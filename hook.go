@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+)
+
+// 完成钩子传递给命令的运行状态（RESTR_STATUS）
+const (
+	StatusSuccess     = "success"     // 正常完成且没有错误
+	StatusErrors      = "errors"      // 完成，但有文件处理出错
+	StatusCapReached  = "cap"         // 达到 --max-total 上限
+	StatusMaxFiles    = "maxfiles"    // 达到 --max-files 上限
+	StatusFailed      = "failed"      // 运行中止（取消、预检失败、遍历出错或 --fail-fast）
+	StatusInterrupted = "interrupted" // 被 Ctrl+C 或 SIGTERM 中断
+)
+
+// ExitHookFailed 在 --on-complete-strict 下完成钩子失败时的退出码
+const ExitHookFailed = 5
+
+// runStatus 根据运行结果确定状态
+func runStatus(result *Result) string {
+	switch {
+	case aborted(result):
+		return StatusFailed
+	case capReached(result):
+		return StatusCapReached
+	case maxFilesReached(result):
+		return StatusMaxFiles
+	case atomic.LoadInt32(&result.Errors) > 0:
+		return StatusErrors
+	}
+	return StatusSuccess
+}
+
+// interruptRequested 判断运行是否收到了中断信号
+func interruptRequested(config *Config) bool {
+	return config.interrupted.Load()
+}
+
+// interruptedRun 以 interrupted 状态运行完成钩子并返回 ErrInterrupted。
+// result 为 nil 表示还没有开始处理文件。
+func interruptedRun(config *Config, result *Result) error {
+	runHook(config, result, StatusInterrupted)
+	return ErrInterrupted
+}
+
+// runHook 运行 --on-complete 命令：运行结果通过 RESTR_* 环境变量传递，
+// JSON 格式的汇总从标准输入传入。返回钩子的退出码，未配置钩子时返回 0。
+// 钩子的输出写到标准错误，不会混入 JSON 等机器可读输出。
+func runHook(config *Config, result *Result, status string) int {
+	if config.OnComplete == "" {
+		return 0
+	}
+
+	code := 0
+	config.hookOnce.Do(func() {
+		if result == nil {
+			result = &Result{RuleMatches: make([]int32, len(ruleNames(config)))}
+		}
+		s := summarize(config, result)
+		summary, _ := json.Marshal(s)
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", config.OnComplete)
+		} else {
+			cmd = exec.Command("sh", "-c", config.OnComplete)
+		}
+		cmd.Stdin = bytes.NewReader(append(summary, '\n'))
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"RESTR_FILES_MATCHED="+strconv.Itoa(int(s.FilesMatches)),
+			"RESTR_MATCHES="+strconv.Itoa(int(s.Matches)),
+			"RESTR_ERRORS="+strconv.Itoa(int(s.Errors)),
+			"RESTR_STATUS="+status,
+		)
+
+		err := cmd.Run()
+		switch exitErr, ok := err.(*exec.ExitError); {
+		case err == nil:
+		case ok:
+			code = exitErr.ExitCode()
+			fmt.Fprintf(os.Stderr, "完成钩子退出码: %d\n", code)
+		default:
+			code = -1
+			fmt.Fprintf(os.Stderr, "运行完成钩子时发生错误: %v\n", err)
+		}
+	})
+	return code
+}
+
+// failRun 在运行中止时以 failed 状态运行完成钩子，并原样返回 err。
+// result 为 nil 表示还没有开始处理文件。
+func failRun(config *Config, result *Result, err error) error {
+	runHook(config, result, StatusFailed)
+	return err
+}
+
+// ExitInterrupted 是被 Ctrl+C 或 SIGTERM 中断时的退出码（128 + SIGINT）
+const ExitInterrupted = 130
+
+// watchInterrupt 在配置了完成钩子或持有锁时捕获中断信号：遍历和工人停止领取
+// 新文件，正在处理的文件照常完成，Run 以 interrupted 状态运行钩子、释放锁并返回
+// ErrInterrupted。第二次中断不再捕获，直接结束进程。返回的函数停止捕获。
+func watchInterrupt(config *Config) (stop func()) {
+	if config.OnComplete == "" && config.lock == nil {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			fmt.Fprintln(os.Stderr, "\n已中断，等待正在处理的文件完成（再次中断立即退出）")
+			config.interrupted.Store(true)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// interruptReporter 在第一个文件替换后向自身发送 SIGINT，并等待中断被登记
+type interruptReporter struct {
+	silentReporter
+	t      *testing.T
+	config *Config
+	sent   bool
+}
+
+func (r *interruptReporter) FileReplaced(FileEvent) {
+	if r.sent {
+		return
+	}
+	r.sent = true
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		r.t.Error(err)
+		return
+	}
+	for deadline := time.Now().Add(5 * time.Second); !interruptRequested(r.config); {
+		if time.Now().After(deadline) {
+			r.t.Error("没有登记中断信号")
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 中断信号不会结束进程：已开始的文件完成替换，其余文件不再处理，
+// Run 释放锁并返回映射为退出码 130 的 ErrInterrupted
+func TestInterruptReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 5)
+	for i := range paths {
+		paths[i] = writeTestFile(t, dir, fmt.Sprintf("f%d.txt", i), "foo\n", 0o644)
+	}
+
+	// Holding the lock makes Run watch for the signal
+	config := &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Workers: 1, Sequential: true}
+	config.Reporter = &interruptReporter{t: t, config: config}
+	result, err := Run(config)
+
+	if !errors.Is(err, ErrInterrupted) || result != nil {
+		t.Fatalf("Run = %v, %v，应返回 ErrInterrupted", result, err)
+	}
+	if code, _ := exitCode(err); code != ExitInterrupted {
+		t.Errorf("退出码 %d，应为 %d", code, ExitInterrupted)
+	}
+	replaced := 0
+	for _, path := range paths {
+		if readTestFile(t, path) == "bar\n" {
+			replaced++
+		}
+	}
+	if replaced != 1 {
+		t.Errorf("替换了 %d 个文件，应只有中断前的 1 个", replaced)
+	}
+	lock, err := acquireLock(config)
+	if err != nil {
+		t.Errorf("锁没有释放: %v", err)
+	}
+	lock.release()
+	assertNoTempFiles(t, dir)
+}
+
+// hookLog 返回把 RESTR_STATUS 逐行追加到日志文件的钩子命令和该日志文件
+func hookLog(t *testing.T) (command, log string) {
+	t.Helper()
+	log = filepath.Join(t.TempDir(), "hook.log")
+	return `echo "$RESTR_STATUS" >> '` + log + `'`, log
+}
+
+// 同一进程中的每次运行都运行一次完成钩子
+func TestHookRunsEveryRun(t *testing.T) {
+	defer func() { cfg = Config{} }()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "a\n", 0o644)
+	command, log := hookLog(t)
+
+	for range 2 {
+		defaultConfig(t)
+		cfg.SourceDir = dir
+		cfg.Trial = true
+		cfg.NoLock = true
+		cfg.OnComplete = command
+		cfg.Reporter = silentReporter{}
+		if err := runApp(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readTestFile(t, log); got != "success\nsuccess\n" {
+		t.Errorf("钩子记录为 %q，应运行两次", got)
+	}
+}
+
+// 因工作树有未提交的改动而拒绝运行时，钩子以 failed 状态运行
+func TestHookDirtyTreeFailed(t *testing.T) {
+	defer func() { cfg = Config{} }()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "a\n", 0o644)
+	initGitRepo(t, dir)
+	writeTestFile(t, dir, "a.txt", "a a\n", 0o644)
+	command, log := hookLog(t)
+
+	defaultConfig(t)
+	cfg.SourceDir = dir
+	cfg.NoLock = true
+	cfg.OnComplete = command
+	cfg.Reporter = silentReporter{}
+	if err := runApp(nil); !errors.Is(err, ErrRefused) {
+		t.Fatalf("runApp = %v，应返回 ErrRefused", err)
+	}
+	if got := readTestFile(t, log); got != "failed\n" {
+		t.Errorf("钩子记录为 %q，应为 failed", got)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "a.txt")); got != "a a\n" {
+		t.Errorf("a.txt 被修改为 %q", got)
+	}
+}
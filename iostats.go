@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// IOStats 汇总本次运行的 I/O 统计，所有字段都以原子方式更新。
+// 方法允许在 nil 上调用，此时不做任何统计。
+type IOStats struct {
+	BytesRead    int64
+	BytesWritten int64
+	FileOpens    int64
+}
+
+// open 打开文件并计入打开次数
+func (s *IOStats) open(fsys FileSystem, path string) (io.ReadCloser, error) {
+	f, err := fsys.Open(path)
+	if err == nil {
+		s.addOpen()
+	}
+	return f, err
+}
+
+// addOpen 记录一次文件打开
+func (s *IOStats) addOpen() {
+	if s != nil {
+		atomic.AddInt64(&s.FileOpens, 1)
+	}
+}
+
+// addRead 记录读取的字节数
+func (s *IOStats) addRead(n int64) {
+	if s != nil && n > 0 {
+		atomic.AddInt64(&s.BytesRead, n)
+	}
+}
+
+// addWritten 记录写入的字节数
+func (s *IOStats) addWritten(n int64) {
+	if s != nil && n > 0 {
+		atomic.AddInt64(&s.BytesWritten, n)
+	}
+}
+
+// reader 返回一个在读取时累计字节数的 Reader
+func (s *IOStats) reader(r io.Reader) io.Reader {
+	if s == nil {
+		return r
+	}
+	return &countingReader{r: r, stats: s}
+}
+
+// countingReader 统计经过的读取字节数
+type countingReader struct {
+	r     io.Reader
+	stats *IOStats
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.stats.addRead(int64(n))
+	return n, err
+}
+
+// formatIOStats 生成 I/O 统计摘要
+func formatIOStats(s *IOStats, elapsed time.Duration) string {
+	read := atomic.LoadInt64(&s.BytesRead)
+	written := atomic.LoadInt64(&s.BytesWritten)
+	return fmt.Sprintf("读取 %s (%s), 写入 %s (%s), 打开文件 %d 次",
+		formatBytes(read), formatRate(read, elapsed),
+		formatBytes(written), formatRate(written, elapsed),
+		atomic.LoadInt64(&s.FileOpens))
+}
+
+// formatBytes 以易读的单位显示字节数
+func formatBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.2f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1024*1024))
+	}
+}
+
+// formatRate 计算平均吞吐量
+func formatRate(n int64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "- MB/s"
+	}
+	return fmt.Sprintf("%.2f MB/s", float64(n)/(1024*1024)/elapsed.Seconds())
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reStr 在源目录下保存自身状态的目录，遍历时总是排除
+const stateDirName = ".reStr"
+
+// 撤销日志保存在 .reStr/undo/<运行时间>/ 下：
+// 每个被修改文件的原始内容存为编号文件，manifest.jsonl 每行记录一个文件。
+const (
+	undoDirName      = "undo"
+	manifestFileName = "manifest.jsonl"
+	journalTimeFmt   = "20060102-150405.000000"
+)
+
+// journalEntry 是撤销日志中的一条记录
+type journalEntry struct {
+	Path    string    `json:"path"`
+	Backup  string    `json:"backup"`  // 原始内容在日志目录中的文件名
+	Size    int64     `json:"size"`    // 替换后的大小，撤销时用于发现之后的修改
+	ModTime time.Time `json:"modTime"` // 替换后的修改时间
+}
+
+// journal 记录一次运行中被修改文件的原始内容，供 reStr undo 恢复。
+// 方法对 nil 接收者安全，未启用日志时直接跳过。
+type journal struct {
+	dir      string
+	mu       sync.Mutex
+	manifest *os.File
+	seq      int
+}
+
+// undoDir 返回源目录下撤销日志的根目录
+func undoDir(sourceDir string) string {
+	return filepath.Join(sourceDir, stateDirName, undoDirName)
+}
+
+// newJournal 为本次运行创建新的日志目录
+func newJournal(sourceDir string) (*journal, error) {
+	dir := filepath.Join(undoDir(sourceDir), time.Now().Format(journalTimeFmt))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	manifest, err := os.Create(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	return &journal{dir: dir, manifest: manifest}, nil
+}
+
+// save 在文件被替换之前复制其原始内容，返回备份文件名
+func (j *journal) save(fsys FileSystem, path string) (string, error) {
+	if j == nil {
+		return "", nil
+	}
+
+	j.mu.Lock()
+	j.seq++
+	backup := fmt.Sprintf("%06d", j.seq)
+	j.mu.Unlock()
+
+	src, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(filepath.Join(j.dir, backup))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", err
+	}
+	return backup, dst.Close()
+}
+
+// record 在替换成功后把文件登记到清单
+func (j *journal) record(fsys FileSystem, path, backup string) error {
+	if j == nil {
+		return nil
+	}
+
+	info, err := fsys.Lstat(path)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(journalEntry{Path: path, Backup: backup, Size: info.Size(), ModTime: info.ModTime()})
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.manifest.Write(append(line, '\n'))
+	return err
+}
+
+// close 关闭清单；没有修改任何文件时删除整个日志目录
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+
+	err := j.manifest.Close()
+	if j.seq == 0 {
+		return os.RemoveAll(j.dir)
+	}
+	return err
+}
+
+// latestJournal 返回源目录下最近一次运行的日志目录
+func latestJournal(sourceDir string) (string, error) {
+	entries, err := os.ReadDir(undoDir(sourceDir))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var runs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			runs = append(runs, entry.Name())
+		}
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("%s 下没有可撤销的运行记录", sourceDir)
+	}
+
+	sort.Strings(runs)
+	return filepath.Join(undoDir(sourceDir), runs[len(runs)-1]), nil
+}
+
+// readJournal 读取日志目录中的清单
+func readJournal(dir string) ([]journalEntry, error) {
+	file, err := os.Open(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("清单格式错误: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// restoreEntry 用备份内容替换文件。文件在替换之后又被修改过时拒绝恢复，除非 force。
+func restoreEntry(fsys FileSystem, dir string, entry journalEntry, force bool) (err error) {
+	info, err := fsys.Lstat(entry.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !force && (err != nil || info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime)) {
+		return fmt.Errorf("文件在替换之后已被修改或删除，如仍需恢复请使用 --force")
+	}
+
+	src, err := fsys.Open(filepath.Join(dir, entry.Backup))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := createTempFile(fsys, entry.Path, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			discardTemp(fsys, tmp)
+		}
+	}()
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	// The restored file keeps the mode and owner the file has now; the
+	// owner is best effort as during the replacement
+	if info != nil {
+		if _, err = copyAttrs(fsys, tmp.Name(), info, writeOptions{}); err != nil {
+			return err
+		}
+	}
+	return fsys.Rename(tmp.Name(), entry.Path)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// 退出码：达到限制时以不同的退出码结束，便于脚本判断
+const (
+	ExitCapReached      = 3 // 达到 --max-total 替换总数上限
+	ExitMaxFilesReached = 4 // 达到 --max-files 文件数上限
+)
+
+// errMaxFilesReached 由遍历回调返回，用于在达到 --max-files 后立即停止遍历
+var errMaxFilesReached = errors.New("已达到文件数上限")
+
+// reserveMatches 从 --max-total 额度中为一个文件预留最多 n 处替换，返回实际获得的数量。
+// 额度通过 CAS 在所有工人之间原子地分配，因此总数永远不会超过上限。
+func reserveMatches(config *Config, result *Result, n int) int {
+	limit := int32(config.MaxTotal)
+	for {
+		used := atomic.LoadInt32(&result.reserved)
+		if used >= limit {
+			atomic.StoreInt32(&result.CapReached, 1)
+			return 0
+		}
+
+		granted := int32(n)
+		if remaining := limit - used; granted >= remaining {
+			granted = remaining
+		}
+
+		if atomic.CompareAndSwapInt32(&result.reserved, used, used+granted) {
+			if granted > 0 {
+				atomic.AddInt32(&result.unsettled, 1)
+			}
+			if used+granted >= limit {
+				atomic.StoreInt32(&result.CapReached, 1)
+			}
+			return int(granted)
+		}
+	}
+}
+
+// capReached 判断替换总数是否已达上限
+func capReached(result *Result) bool {
+	return atomic.LoadInt32(&result.CapReached) != 0
+}
+
+// settleMatches 在文件处理结束时结算 reserveMatches 预留的额度。没有用掉的部分
+// （替换失败、内容不变、跳过或文件在两次扫描之间改变）归还给之后的文件。
+func settleMatches(config *Config, result *Result, granted, used int) {
+	if unused := granted - used; unused > 0 {
+		if atomic.AddInt32(&result.reserved, -int32(unused)) < int32(config.MaxTotal) {
+			atomic.StoreInt32(&result.CapReached, 0)
+		}
+	}
+	atomic.AddInt32(&result.unsettled, -1)
+}
+
+// stopAtCap 判断遍历是否应因 --max-total 停止。额度虽已分完、但仍有文件
+// 在使用预留的额度时先等它们结束：它们可能归还额度，遍历应继续。
+func stopAtCap(result *Result) bool {
+	for capReached(result) && atomic.LoadInt32(&result.unsettled) > 0 {
+		time.Sleep(capSettleInterval)
+	}
+	return capReached(result)
+}
+
+// capSettleInterval 是 stopAtCap 等待时轮询的间隔
+const capSettleInterval = 5 * time.Millisecond
+
+// limitMatcher 限制单个文件内的匹配数量，超出额度的匹配保持原样
+type limitMatcher struct {
+	inner     Matcher
+	remaining int
+}
+
+// FindAll 按行的先后顺序消耗额度
+func (m *limitMatcher) FindAll(line string) []Match {
+	if m.remaining <= 0 {
+		return nil
+	}
+
+	matches := m.inner.FindAll(line)
+	if len(matches) > m.remaining {
+		matches = matches[:m.remaining]
+	}
+	m.remaining -= len(matches)
+	return matches
+}
+
+// trimPreview 使预览行与受限后的匹配数量一致
+func trimPreview(preview []lineMatch, limit int) []lineMatch {
+	var trimmed []lineMatch
+	for _, lm := range preview {
+		if limit <= 0 {
+			break
+		}
+		if len(lm.Matches) > limit {
+			lm.Matches = lm.Matches[:limit]
+		}
+		limit -= len(lm.Matches)
+		trimmed = append(trimmed, lm)
+	}
+	return trimmed
+}
+
+// maxFilesReached 判断是否因 --max-files 停止了遍历
+func maxFilesReached(result *Result) bool {
+	return atomic.LoadInt32(&result.MaxFilesReached) != 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+)
+
+// 替换失败的文件归还 --max-total 额度，之后的文件仍能在上限之内替换
+func TestMaxTotalReturnsUnusedQuota(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "foo foo\n", 0o644)
+	b := writeTestFile(t, dir, "b.txt", "foo\n", 0o644)
+
+	result := runTest(t, &Config{
+		SourceDir:    dir,
+		SourceString: "foo",
+		TargetString: "bar",
+		MaxTotal:     2,
+		Sequential:   true,
+		FS:           &faultFS{failCreate: "a.txt"},
+	})
+
+	if result.Matches != 1 || result.Errors != 1 {
+		t.Errorf("替换 %d 处、错误 %d 个，应为 1 和 1", result.Matches, result.Errors)
+	}
+	if got := readTestFile(t, b); got != "bar\n" {
+		t.Errorf("b.txt = %q，没有用上 a.txt 归还的额度", got)
+	}
+	if capReached(result) {
+		t.Error("额度没有用完，不应报告达到上限")
+	}
+}
+
+// 用完额度后停止，试验模式与实际运行一致
+func TestMaxTotalCap(t *testing.T) {
+	for _, trial := range []bool{true, false} {
+		dir := t.TempDir()
+		writeTestFile(t, dir, "a.txt", "foo foo\n", 0o644)
+		writeTestFile(t, dir, "b.txt", "foo foo\n", 0o644)
+
+		result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", MaxTotal: 3, Sequential: true, Trial: trial})
+		if result.Matches != 3 || !capReached(result) {
+			t.Errorf("trial=%v: 替换 %d 处，达到上限 %v，应为 3 和 true", trial, result.Matches, capReached(result))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"unsafe"
+)
+
+// lineString 返回与 b 共享内存的字符串，按行扫描时不必为每一行分配新字符串。
+// b 被下一次读取覆盖后返回值随之失效；需要保留时用 strings.Clone 复制。
+func lineString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// lineReader 逐行读取任意长度的行。计数、--diff 和替换都通过它读取，
+// 三者看到的行和行结束符完全相同。
+type lineReader struct {
+	r    *bufio.Reader
+	long []byte // 超出缓冲区的长行
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r)}
+}
+
+// next 返回下一行：raw 含行结束符，body 是去掉行结束符后 raw 的前缀。
+// 没有行结束符的最后一行照常返回，之后的调用返回 io.EOF。
+// 行在缓冲区内时直接返回缓冲区的切片，否则拼接到 long 中；
+// 返回的切片在下一次调用前有效。
+func (l *lineReader) next() (raw, body []byte, err error) {
+	raw, err = l.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		l.long = append(l.long[:0], raw...)
+		for err == bufio.ErrBufferFull {
+			raw, err = l.r.ReadSlice('\n')
+			l.long = append(l.long, raw...)
+		}
+		raw = l.long
+	}
+	if err == io.EOF && len(raw) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, trimLineEnd(raw), nil
+}
+
+// trimLineEnd 去掉行结束符：结尾的 '\n' 和它之前的一个 '\r'，
+// 与 bufio.ScanLines 相同
+func trimLineEnd(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// next 保留原始的行结束符，body 与 bufio.ScanLines 的行相同
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("x", 3*4096+7)
+	tests := []struct {
+		in   string
+		raws []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\r\nb\nc", []string{"a\r\n", "b\n", "c"}},
+		{"\n\n", []string{"\n", "\n"}},
+		{"a\rb\r\r\n", []string{"a\rb\r\r\n"}},
+		{long + "\r\n" + long, []string{long + "\r\n", long}},
+		{"a\n" + long + "\nb\n", []string{"a\n", long + "\n", "b\n"}},
+	}
+	for _, tt := range tests {
+		// One byte at a time as well, so every line ends mid-buffer
+		for _, r := range []io.Reader{strings.NewReader(tt.in), iotest.OneByteReader(strings.NewReader(tt.in))} {
+			lines := newLineReader(r)
+			var raws []string
+			for {
+				raw, body, err := lines.next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := strings.TrimSuffix(strings.TrimSuffix(string(raw), "\n"), "\r"); string(body) != want {
+					t.Errorf("行 %.20q 的内容 %.20q，应为 %.20q", raw, body, want)
+				}
+				raws = append(raws, string(raw))
+			}
+			if strings.Join(raws, "|") != strings.Join(tt.raws, "|") {
+				t.Errorf("%.40q 读出 %d 行，应为 %d 行", tt.in, len(raws), len(tt.raws))
+			}
+		}
+	}
+}
+
+// 10MB 的单行文件中跨越读缓冲区边界的匹配，计数、替换和 --diff 结果一致
+func TestLongSingleLine(t *testing.T) {
+	const size = 10 << 20
+	var sb strings.Builder
+	sb.Grow(size + 4096)
+	for i := 0; sb.Len() < size; i++ {
+		// Matches straddle each 4KB boundary at a different offset
+		pad := 4096 - sb.Len()%4096 - 1 - i%3
+		if pad < 0 {
+			pad += 4096
+		}
+		sb.WriteString(strings.Repeat("a", pad))
+		sb.WriteString("foo")
+	}
+	for _, term := range []string{"", "\n", "\r\n"} {
+		content := sb.String() + term
+		want := strings.ReplaceAll(content, "foo", "quux")
+		matches := strings.Count(content, "foo")
+
+		counted, replaced, out := countAndReplace(t, content, newLiteralMatcher("foo", "quux"))
+		if counted != matches || replaced != matches {
+			t.Errorf("行结束符 %q: 计数 %d、替换 %d，应为 %d", term, counted, replaced, matches)
+		}
+		if out != want {
+			t.Errorf("行结束符 %q: 替换后的内容不正确（长度 %d，应为 %d）", term, len(out), len(want))
+		}
+	}
+
+	path := writeTestFile(t, t.TempDir(), "long.txt", sb.String(), 0o644)
+	diff, err := fileDiff(osFS{}, path, newLiteralMatcher("foo", "quux"), false, &IOStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+"+strings.ReplaceAll(sb.String(), "foo", "quux")) {
+		t.Error("--diff 中没有完整的替换后行")
+	}
+}
+
+// quietFile 创建含有 lines 行不匹配内容和一处 needle 的文件
+func quietFile(tb testing.TB, lines int) string {
+	tb.Helper()
+	var sb strings.Builder
+	for i := range lines {
+		fmt.Fprintf(&sb, "line %d: nothing to see here, just ordinary text\n", i)
+	}
+	sb.WriteString("needle\n")
+	path := filepath.Join(tb.TempDir(), "quiet.txt")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// lineMatchers 是分配测试覆盖的匹配器
+func lineMatchers() map[string]Matcher {
+	return map[string]Matcher{
+		"literal": newLiteralMatcher("needle", "pin"),
+		"regex":   &regexMatcher{pattern: regexp.MustCompile(`need(le)`), replace: "pin"},
+		"word":    &wordMatcher{inner: newLiteralMatcher("needle", "pin")},
+	}
+}
+
+// scanAndReplace 对 path 计数一次、替换一次，然后还原文件
+func scanAndReplace(tb testing.TB, path string, matcher Matcher, content []byte) {
+	// A preview keeps the scan on the line-by-line path
+	if _, err := fileContainsString(osFS{}, path, matcher, 0, 1, 0, &IOStats{}); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := replaceInFile(osFS{}, path, "", matcher, 0, writeOptions{}, &IOStats{}); err != nil && !errors.Is(err, errUnchanged) {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// 不匹配的行不分配内存：行数翻倍时计数和替换的分配次数几乎不变
+func TestAllocsPerQuietLine(t *testing.T) {
+	if raceEnabled {
+		t.Skip("竞态检测器会额外分配内存")
+	}
+	for name, matcher := range lineMatchers() {
+		t.Run(name, func(t *testing.T) {
+			allocs := func(lines int) float64 {
+				path := quietFile(t, lines)
+				content, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return testing.AllocsPerRun(5, func() { scanAndReplace(t, path, matcher, content) })
+			}
+			small, large := allocs(5000), allocs(10000)
+			// Writing the original back allocates as well, but not per line
+			if perLine := (large - small) / 5000; perLine > 0.01 {
+				t.Errorf("每个不匹配的行分配 %.3f 次（%v → %v），应接近 0", perLine, small, large)
+			}
+		})
+	}
+}
+
+// 逐行计数和替换 10000 个不匹配的行；allocs/op 应与行数无关
+func BenchmarkQuietLines(b *testing.B) {
+	const lines = 10000
+	path := quietFile(b, lines)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for name, matcher := range lineMatchers() {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for b.Loop() {
+				scanAndReplace(b, path, matcher, content)
+			}
+		})
+	}
+}
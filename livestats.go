@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// liveStats 发布运行中的状态，供信号（SIGUSR1/SIGQUIT）或 --stats-interval 触发时输出
+type liveStats struct {
+	start time.Time
+	queue *workQueue
+
+	mu      sync.Mutex
+	current []activeFile // 每个工人正在处理的文件
+}
+
+// activeFile 是工人正在处理的文件，path 为空表示空闲
+type activeFile struct {
+	path  string
+	since time.Time
+}
+
+// newLiveStats 为 workers 个工人创建运行状态
+func newLiveStats(workers int, queue *workQueue) *liveStats {
+	return &liveStats{start: time.Now(), queue: queue, current: make([]activeFile, workers)}
+}
+
+// begin 记录工人开始处理 path
+func (s *liveStats) begin(worker int, path string) {
+	s.mu.Lock()
+	s.current[worker] = activeFile{path: path, since: time.Now()}
+	s.mu.Unlock()
+}
+
+// end 记录工人处理完当前文件
+func (s *liveStats) end(worker int) {
+	s.mu.Lock()
+	s.current[worker] = activeFile{}
+	s.mu.Unlock()
+}
+
+// workers 返回各工人当前状态的副本
+func (s *liveStats) workers() []activeFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]activeFile(nil), s.current...)
+}
+
+// writeLiveStats 把当前计数、队列长度、各工人的文件和速度写入 w
+func writeLiveStats(w io.Writer, config *Config, result *Result, s *liveStats) {
+	now := time.Now()
+	elapsed := now.Sub(s.start)
+	processed := atomic.LoadInt32(&result.FilesProcessed)
+	var skipped int32
+	for i := range result.Skipped {
+		skipped += atomic.LoadInt32(&result.Skipped[i])
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n--- 运行状态（已运行 %v）---\n", elapsed.Round(time.Second))
+	fmt.Fprintf(&sb, "处理文件: %d, 发现: %d, 匹配文件: %d, 匹配: %d, 跳过: %d, 错误: %d\n",
+		processed, atomic.LoadInt32(&result.FilesFound), atomic.LoadInt32(&result.FilesMatches),
+		atomic.LoadInt32(&result.Matches), skipped, atomic.LoadInt32(&result.Errors))
+	fmt.Fprintf(&sb, "队列中: %d, 速度: %.1f 个文件/秒, %s\n",
+		s.queue.len(), float64(processed)/max(elapsed.Seconds(), 1e-3), formatIOStats(&result.IO, elapsed))
+	for i, f := range s.workers() {
+		if f.path == "" {
+			fmt.Fprintf(&sb, "工人 %d: 空闲\n", i)
+			continue
+		}
+		fmt.Fprintf(&sb, "工人 %d: %s（%v）\n", i, escapeControl(config.display.show(f.path)), now.Sub(f.since).Round(time.Millisecond))
+	}
+	io.WriteString(w, sb.String())
+}
+
+// watchStats 在收到 statsSignals 中的信号或每隔 --stats-interval 时把运行状态
+// 输出到标准错误，不中断运行。返回的函数停止输出。
+func watchStats(config *Config, result *Result, s *liveStats) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	if len(statsSignals) > 0 {
+		signal.Notify(signals, statsSignals...)
+	}
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if config.StatsInterval > 0 {
+		ticker = time.NewTicker(config.StatsInterval)
+		tick = ticker.C
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+			case <-tick:
+			case <-done:
+				return
+			}
+			writeLiveStats(os.Stderr, config, result, s)
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		if tick != nil {
+			ticker.Stop()
+		}
+		close(done)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statsSignals 触发输出运行状态的信号；捕获 SIGQUIT 后不再退出
+var statsSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGQUIT}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// statsSignals 在 Windows 上为空，用 --stats-interval 定期输出运行状态
+var statsSignals []os.Signal
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 锁文件位于状态目录中：<源目录>/.reStr/lock
+const lockFileName = "lock"
+
+// lockMaxAge 超过此时长的锁视为残留。其他主机上的进程无法检查是否仍在运行，
+// 只能按时间判断。
+const lockMaxAge = 24 * time.Hour
+
+// lockInfo 记录持有锁的运行，用于在拒绝运行时指明持有者
+type lockInfo struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Start time.Time `json:"start"`
+}
+
+// runLock 防止两个 reStr 同时修改同一个目录
+type runLock struct {
+	path   string
+	ownDir bool // 锁文件所在的状态目录由本次运行创建
+	once   sync.Once
+}
+
+// lockPath 返回锁文件的路径
+func lockPath(config *Config) string {
+	if config.LockPath != "" {
+		return config.LockPath
+	}
+	return filepath.Join(config.SourceDir, stateDirName, lockFileName)
+}
+
+// acquireLock 创建锁文件。已有未过期的锁时返回指明持有者的错误；
+// 持有者已退出（同一主机上进程不存在）或锁已超过 lockMaxAge 时接管该锁。
+func acquireLock(config *Config) (*runLock, error) {
+	path := lockPath(config)
+	dir := filepath.Dir(path)
+	_, statErr := os.Stat(dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock := &runLock{path: path, ownDir: errors.Is(statErr, fs.ErrNotExist) && config.LockPath == ""}
+
+	host, _ := os.Hostname()
+	info, _ := json.Marshal(lockInfo{PID: os.Getpid(), Host: host, Start: time.Now()})
+
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = f.Write(append(info, '\n'))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return lock, nil
+		}
+		if !errors.Is(err, fs.ErrExist) || attempt > 0 {
+			return nil, err
+		}
+
+		holder, stale := readLock(path, host)
+		if !stale {
+			return nil, fmt.Errorf("目录正被另一个 reStr 运行修改（pid %d，主机 %s，开始于 %s）；"+
+				"如确认该运行已结束，请删除 %s 或使用 --no-lock",
+				holder.PID, holder.Host, holder.Start.Format("2006-01-02 15:04:05"), path)
+		}
+		fmt.Fprintf(os.Stderr, "接管残留的锁文件 %s（pid %d，主机 %s）\n", path, holder.PID, holder.Host)
+		os.Remove(path)
+	}
+}
+
+// readLock 读取锁文件并判断是否残留。无法解析的锁按文件修改时间判断。
+func readLock(path, host string) (lockInfo, bool) {
+	var holder lockInfo
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &holder)
+	}
+	if err != nil {
+		fi, statErr := os.Stat(path)
+		return holder, statErr != nil || time.Since(fi.ModTime()) > lockMaxAge
+	}
+
+	if holder.Host == host && !processAlive(holder.PID) {
+		return holder, true
+	}
+	return holder, time.Since(holder.Start) > lockMaxAge
+}
+
+// release 删除锁文件，可以重复调用；lock 为 nil 时什么都不做
+func (l *runLock) release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		os.Remove(l.path)
+		if l.ownDir {
+			// Only succeeds when nothing else (undo journals) lives there
+			os.Remove(filepath.Dir(l.path))
+		}
+	})
+}
@@ -0,0 +1,682 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match 描述一行内的一处匹配
+type Match struct {
+	Start       int    // 匹配起始字节位置
+	End         int    // 匹配结束字节位置（不含）
+	Replacement string // 替换后的文本
+	Rule        int    // 产生该匹配的规则序号，用于分规则统计
+	Err         error  // 无法生成替换文本的原因（--template-replace），非 nil 时整个文件失败
+}
+
+// Matcher 在单行内容（不含换行符）中查找所有互不重叠的匹配。
+// 计数、试验输出和实际替换都基于同一个 Matcher，保证三者结果一致。
+//
+// 行内容是文件的原始字节，可能含有无效的 UTF-8。匹配以字节偏移描述，
+// 替换时匹配之外的字节原样写出；需要解码内容（大小写折叠、规范化等）的
+// 匹配器必须把位置换算回原始字节的偏移，不能返回解码后的文本。
+//
+// line 通常直接引用读取缓冲区，只在调用期间有效：匹配器不能保存它，
+// Replacement 也不能是 line 的子串。
+type Matcher interface {
+	FindAll(line string) []Match
+}
+
+// literalMatcher 普通字符串匹配
+type literalMatcher struct {
+	search  string
+	replace string
+}
+
+// newLiteralMatcher 创建普通字符串匹配器
+func newLiteralMatcher(search, replace string) *literalMatcher {
+	return &literalMatcher{search: search, replace: replace}
+}
+
+// FindAll 从左到右查找所有不重叠的匹配
+func (m *literalMatcher) FindAll(line string) []Match {
+	// An empty search would match at every position without advancing
+	if m.search == "" {
+		return nil
+	}
+	var matches []Match
+	offset := 0
+	for {
+		i := strings.Index(line[offset:], m.search)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		end := start + len(m.search)
+		matches = append(matches, Match{Start: start, End: end, Replacement: m.replace})
+		offset = end
+	}
+	return matches
+}
+
+// lineMatcher 整行匹配：整行等于源字符串时才匹配，并用目标字符串替换整行
+type lineMatcher struct {
+	search  string
+	replace string
+	trim    bool // 比较前忽略行首尾的空白
+}
+
+// FindAll 整行匹配时返回覆盖整行的单个匹配
+func (m *lineMatcher) FindAll(line string) []Match {
+	content := line
+	if m.trim {
+		content = strings.Trim(line, " \t")
+	}
+
+	if content != m.search {
+		return nil
+	}
+	return []Match{{Start: 0, End: len(line), Replacement: m.replace}}
+}
+
+// 锚定方式
+const (
+	AnchorNone  = ""
+	AnchorStart = "start"
+	AnchorEnd   = "end"
+	AnchorBoth  = "both"
+)
+
+// anchoredMatcher 锚定匹配：只接受从行首开始和/或在行尾结束的匹配
+type anchoredMatcher struct {
+	search      string
+	replace     string
+	start       bool // 必须从行首开始
+	end         bool // 必须在行尾结束
+	allowIndent bool // 行首锚定时允许前导空白
+}
+
+// FindAll 每行最多只有一处锚定匹配
+func (m *anchoredMatcher) FindAll(line string) []Match {
+	begin := 0
+	if m.allowIndent {
+		begin = len(line) - len(strings.TrimLeft(line, " \t"))
+	}
+
+	var start int
+	switch {
+	case m.start && m.end:
+		if line[begin:] != m.search {
+			return nil
+		}
+		start = begin
+	case m.start:
+		if !strings.HasPrefix(line[begin:], m.search) {
+			return nil
+		}
+		start = begin
+	default:
+		if !strings.HasSuffix(line, m.search) {
+			return nil
+		}
+		start = len(line) - len(m.search)
+	}
+
+	return []Match{{Start: start, End: start + len(m.search), Replacement: m.replace}}
+}
+
+// regexMatcher 正则表达式匹配（--regex），也用于 --ignore-whitespace 和 --ignore-case
+// 编译出的模式。
+// expand 为 true 时替换文本中的 $1、${name} 按 regexp.Expand 的规则展开，
+// \U、\L 等按 caseTemplate 转换大小写，否则原样插入。空匹配（如 x*）不计为匹配。
+type regexMatcher struct {
+	pattern *regexp.Regexp
+	replace string
+	expand  bool
+	cases   caseTemplate // 替换文本中有大小写转义时不为 nil
+}
+
+// newRegexMatcher 创建正则表达式匹配器，expand 时预先拆分替换文本中的大小写转义
+func newRegexMatcher(pattern *regexp.Regexp, replace string, expand bool) *regexMatcher {
+	m := &regexMatcher{pattern: pattern, replace: replace, expand: expand}
+	if expand {
+		m.cases = parseCaseTemplate(replace)
+	}
+	return m
+}
+
+// spacePattern 生成 --ignore-whitespace 使用的正则表达式：源字符串中的每段空格和
+// 制表符匹配内容中任意长度的一段空格和制表符；与标点或行首尾相邻的一段也可以
+// 不出现，两个单词字符之间的一段至少要有一个空白字符。其余部分按字面匹配。
+func spacePattern(search string) string {
+	isSpace := func(r rune) bool { return r == ' ' || r == '\t' }
+
+	runes := []rune(search)
+	var sb strings.Builder
+	for i := 0; i < len(runes); {
+		if !isSpace(runes[i]) {
+			j := i
+			for j < len(runes) && !isSpace(runes[j]) {
+				j++
+			}
+			sb.WriteString(regexp.QuoteMeta(string(runes[i:j])))
+			i = j
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isSpace(runes[j]) {
+			j++
+		}
+		// A run at either end of the pattern or between two words is required
+		if i == 0 || j == len(runes) || (isWordRune(runes[i-1]) && isWordRune(runes[j])) {
+			sb.WriteString(`[ \t]+`)
+		} else {
+			sb.WriteString(`[ \t]*`)
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+// FindAll 从左到右查找所有不重叠的匹配
+func (m *regexMatcher) FindAll(line string) []Match {
+	var matches []Match
+	for _, loc := range m.pattern.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		replacement := m.replace
+		switch {
+		case m.cases != nil:
+			replacement = m.cases.expand(m.pattern, line, loc)
+		case m.expand:
+			replacement = string(m.pattern.ExpandString(nil, m.replace, line, loc))
+		}
+		matches = append(matches, Match{Start: loc[0], End: loc[1], Replacement: replacement})
+	}
+	return matches
+}
+
+// compilePattern 编译 --regex、--ignore-whitespace 或 --ignore-case 的模式，
+// 都未指定时返回 nil。忽略大小写使用 RE2 的 Unicode 简单大小写折叠。
+func compilePattern(config *Config) (*regexp.Regexp, error) {
+	var expr string
+	switch {
+	case config.Regex:
+		expr = config.SourceString
+	case config.IgnoreWhitespace:
+		expr = spacePattern(config.SourceString)
+	case config.IgnoreCase:
+		expr = regexp.QuoteMeta(config.SourceString)
+	default:
+		return nil, nil
+	}
+	if config.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// checkGroupRefs 按 regexp.Expand 的规则检查替换模板中的分组引用，引用不存在的
+// 分组编号或名称时返回错误，而不是在替换时静默地替换为空。$$ 是字面的 $；
+// $name 取尽可能长的字母、数字和下划线序列，后面紧跟单词字符时要写成 ${name}；
+// 之后不是名称的 $ 按原样输出。
+func checkGroupRefs(pattern *regexp.Regexp, template string) error {
+	names := pattern.SubexpNames()
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' {
+			continue
+		}
+		if strings.HasPrefix(template[i+1:], "$") {
+			i++
+			continue
+		}
+		name, num, n := groupRef(template[i+1:])
+		if n == 0 {
+			continue
+		}
+		ref := template[i : i+1+n]
+		i += n
+		if num >= 0 {
+			if num > pattern.NumSubexp() {
+				return fmt.Errorf("%s 引用了第 %d 组，但模式只有 %d 个分组", ref, num, pattern.NumSubexp())
+			}
+			continue
+		}
+		if slices.Contains(names[1:], name) {
+			continue
+		}
+		// $major_x reads as the group major_x; point at ${major}_x
+		for j := len(name) - 1; j > 0; j-- {
+			if prefix := name[:j]; slices.Contains(names[1:], prefix) || isGroupNumber(prefix, pattern.NumSubexp()) {
+				return fmt.Errorf("%s 引用了不存在的分组 %s；如果指的是分组 %s，请写成 ${%s}%s", ref, name, prefix, prefix, name[j:])
+			}
+		}
+		return fmt.Errorf("%s 引用了不存在的分组 %s", ref, name)
+	}
+	return nil
+}
+
+// groupRef 解析 $ 之后的分组引用，返回名称、编号（不是纯数字时为 -1）
+// 和引用占用的字节数；不是引用时字节数为 0。规则与 regexp.Expand 相同。
+func groupRef(s string) (name string, num, n int) {
+	braced := strings.HasPrefix(s, "{")
+	rest := s
+	if braced {
+		rest = s[1:]
+	}
+	i := 0
+	for i < len(rest) {
+		r, size := utf8.DecodeRuneInString(rest[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return "", -1, 0
+	}
+	name, n = rest[:i], i
+	if braced {
+		if i >= len(rest) || rest[i] != '}' {
+			return "", -1, 0
+		}
+		n += 2
+	}
+
+	// Purely numeric without leading zeros, as Expand parses it
+	num = 0
+	for k := 0; k < len(name); k++ {
+		if name[k] < '0' || name[k] > '9' || num >= 1e8 {
+			num = -1
+			break
+		}
+		num = num*10 + int(name[k]-'0')
+	}
+	if name[0] == '0' && len(name) > 1 {
+		num = -1
+	}
+	return name, num, n
+}
+
+// isGroupNumber 判断 s 是否是不超过 groups 的分组编号
+func isGroupNumber(s string, groups int) bool {
+	_, num, n := groupRef(s)
+	return n == len(s) && num >= 0 && num <= groups
+}
+
+// isWordRune 判断字符是否是单词字符：字母（包括非 ASCII 字母）、数字和下划线
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordBounded 判断 line[start:end] 前后是否都不是单词字符（或是行首尾）
+func wordBounded(line string, start, end int) bool {
+	if r, size := utf8.DecodeLastRuneInString(line[:start]); size > 0 && isWordRune(r) {
+		return false
+	}
+	if r, size := utf8.DecodeRuneInString(line[end:]); size > 0 && isWordRune(r) {
+		return false
+	}
+	return true
+}
+
+// wordMatcher 整词匹配（--word）：只接受前后都不是单词字符的匹配，同 grep -w。
+// 字面匹配在不满足的位置之后继续查找；其他匹配器的匹配只做过滤。
+type wordMatcher struct {
+	inner Matcher
+}
+
+// FindAll 返回整词匹配
+func (m *wordMatcher) FindAll(line string) []Match {
+	if lm, ok := m.inner.(*literalMatcher); ok {
+		return lm.findWords(line)
+	}
+	var matches []Match
+	for _, match := range m.inner.FindAll(line) {
+		if wordBounded(line, match.Start, match.End) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// findWords 从左到右查找所有不重叠的整词匹配
+func (m *literalMatcher) findWords(line string) []Match {
+	if m.search == "" {
+		return nil
+	}
+	var matches []Match
+	offset := 0
+	for offset <= len(line) {
+		i := strings.Index(line[offset:], m.search)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		end := start + len(m.search)
+		if !wordBounded(line, start, end) {
+			offset = start + 1
+			continue
+		}
+		matches = append(matches, Match{Start: start, End: end, Replacement: m.replace})
+		offset = end
+	}
+	return matches
+}
+
+// countChunkSize 是 countLiteral 每次读取的字节数
+const countChunkSize = 64 << 10
+
+// countLiteral 按固定大小的块读取并统计 search 不重叠出现的次数，不切分行，
+// 也不受行长度限制。search 不含换行符（由参数校验保证），匹配不会跨行，
+// 因此结果与逐行调用 literalMatcher 完全相同。
+// 块之间保留上一块末尾未被匹配消耗、且不足 len(search) 的字节，
+// 跨越块边界的匹配不会遗漏，也不会被重复计数。
+func countLiteral(r io.Reader, search []byte) (int, error) {
+	buf := make([]byte, len(search)-1+countChunkSize)
+	count, carry := 0, 0
+	for {
+		n, err := io.ReadFull(r, buf[carry:])
+		data := buf[:carry+n]
+
+		consumed := 0
+		for {
+			i := bytes.Index(data[consumed:], search)
+			if i < 0 {
+				break
+			}
+			count++
+			consumed += i + len(search)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		// Keep the tail that a match starting in this chunk could still use
+		keep := len(data) - consumed
+		if keep > len(search)-1 {
+			keep = len(search) - 1
+		}
+		carry = copy(buf, data[len(data)-keep:])
+	}
+}
+
+// nthMatcher 只保留每行的第 N 处匹配（从 1 开始计数），其余匹配保持原样
+type nthMatcher struct {
+	inner Matcher
+	n     int
+}
+
+// FindAll 行内匹配少于 N 处时该行不做替换
+func (m *nthMatcher) FindAll(line string) []Match {
+	matches := m.inner.FindAll(line)
+	if len(matches) < m.n {
+		return nil
+	}
+	return matches[m.n-1 : m.n]
+}
+
+// firstMatcher 只保留每行从左数起的前 N 处匹配（--occurrences N）
+type firstMatcher struct {
+	inner Matcher
+	n     int
+}
+
+// FindAll 行内匹配不足 N 处时全部保留
+func (m *firstMatcher) FindAll(line string) []Match {
+	matches := m.inner.FindAll(line)
+	if len(matches) > m.n {
+		matches = matches[:m.n]
+	}
+	return matches
+}
+
+// parseOccurrences 解析 --occurrences：all（或空）为 0，first 为 1，否则为正整数
+func parseOccurrences(s string) (int, error) {
+	switch s {
+	case "", "all":
+		return 0, nil
+	case "first":
+		return 1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的 --occurrences: %s（可选 all|first|正整数）", s)
+	}
+	return n, nil
+}
+
+// buildMatcher 根据配置创建匹配器
+func buildMatcher(config *Config) Matcher {
+	return buildTrailingMatcher(config, false)
+}
+
+// buildTrailingMatcher 在基础匹配器外包装按行生效的选项；
+// keepBreaks 用于 Markdown 文件，清理行尾空白时保留两个空格的换行
+func buildTrailingMatcher(config *Config, keepBreaks bool) Matcher {
+	var matcher Matcher
+	if !trimOnly(config) {
+		matcher = buildBaseMatcher(config)
+
+		// Each --map rule checks its own word boundaries in buildBaseMatcher
+		if config.Word && config.Map == "" {
+			matcher = &wordMatcher{inner: matcher}
+		}
+
+		if config.Nth > 0 {
+			matcher = &nthMatcher{inner: matcher, n: config.Nth}
+		} else if config.occurrences > 0 {
+			matcher = &firstMatcher{inner: matcher, n: config.occurrences}
+		}
+	}
+
+	if config.TrimTrailing {
+		rule := 0
+		if matcher != nil {
+			rule = len(ruleNames(config)) - 1
+		}
+		matcher = &trailingMatcher{inner: matcher, rule: rule, keepBreaks: keepBreaks}
+	}
+
+	return matcher
+}
+
+// ruleNames 返回需要分别统计的规则名称，单一规则时返回 nil。
+// 同时清理行尾空白时，清理作为最后一条规则单独统计。
+func ruleNames(config *Config) []string {
+	if !config.TrimTrailing || trimOnly(config) {
+		return baseRuleNames(config)
+	}
+
+	rules := baseRuleNames(config)
+	if rules == nil {
+		rules = []string{baseRuleName(config)}
+	}
+	return append(rules, "行尾空白")
+}
+
+// baseRuleName 返回单一规则的名称
+func baseRuleName(config *Config) string {
+	switch {
+	case config.Detab > 0:
+		return "缩进→空格"
+	case config.Retab > 0:
+		return "缩进→制表符"
+	case config.Map != "":
+		return fmt.Sprintf("%d 条替换规则", len(config.mapRules))
+	}
+	return config.SourceString + "→" + config.TargetString
+}
+
+// baseRuleNames 返回基础匹配器的规则名称，单一规则时返回 nil
+func baseRuleNames(config *Config) []string {
+	if config.Map != "" {
+		return mapRuleNames(config.mapRules)
+	}
+	if config.Swap {
+		return []string{
+			config.SourceString + "→" + config.TargetString,
+			config.TargetString + "→" + config.SourceString,
+		}
+	}
+	return nil
+}
+
+// buildBaseMatcher 根据匹配方式创建基础匹配器
+func buildBaseMatcher(config *Config) Matcher {
+	if config.Detab > 0 {
+		return &indentMatcher{tabWidth: config.Detab}
+	}
+
+	if config.Retab > 0 {
+		return &indentMatcher{tabWidth: config.Retab, toTabs: true}
+	}
+
+	if config.Swap {
+		return newSwapMatcher(config.SourceString, config.TargetString)
+	}
+
+	if config.Map != "" {
+		chain := &chainMatcher{}
+		for _, r := range config.mapRules {
+			var rule Matcher = newLiteralMatcher(r.From, r.To)
+			if r.pattern != nil {
+				rule = newRegexMatcher(r.pattern, r.To, r.Regex)
+			}
+			if r.Word {
+				rule = &wordMatcher{inner: rule}
+			}
+			chain.rules = append(chain.rules, rule)
+		}
+		return chain
+	}
+
+	if config.LineMode {
+		return &lineMatcher{search: config.SourceString, replace: config.TargetString, trim: config.Trim}
+	}
+
+	if config.Anchor != AnchorNone {
+		return &anchoredMatcher{
+			search:      config.SourceString,
+			replace:     config.TargetString,
+			start:       config.Anchor == AnchorStart || config.Anchor == AnchorBoth,
+			end:         config.Anchor == AnchorEnd || config.Anchor == AnchorBoth,
+			allowIndent: config.AllowIndent,
+		}
+	}
+
+	if config.template != nil {
+		return &templateMatcher{pattern: config.pattern, tmpl: config.template}
+	}
+
+	if config.pattern != nil {
+		return newRegexMatcher(config.pattern, config.TargetString, config.Regex)
+	}
+
+	return newLiteralMatcher(config.SourceString, config.TargetString)
+}
+
+// newSwapMatcher 创建互换匹配器：a 替换为 b（规则 0），b 替换为 a（规则 1）。
+// 替换结果不会被再次匹配；两者重叠时（一个包含另一个）优先匹配较长者。
+func newSwapMatcher(a, b string) *multiMatcher {
+	return newMultiMatcher([]string{a, b}, []string{b, a}, []int{0, 1})
+}
+
+// checkMatches 确认每处匹配都生成了替换文本，且按顺序排列、互不重叠、都在行内，
+// 防止有缺陷的匹配器让匹配之外的字节被改写
+func checkMatches(line string, matches []Match) error {
+	last := 0
+	for _, m := range matches {
+		if m.Err != nil {
+			return m.Err
+		}
+		if m.Start < last || m.End < m.Start || m.End > len(line) {
+			return fmt.Errorf("内部错误: 无效的匹配位置 [%d, %d)（行长 %d 字节）", m.Start, m.End, len(line))
+		}
+		last = m.End
+	}
+	return nil
+}
+
+// writeMatches 把替换后的行写入 w：匹配之间的原始字节直接从 line 写出，
+// 不生成中间字符串。line 可以带有行结束符，它位于所有匹配之后，原样写出。
+func writeMatches(w *bufio.Writer, line []byte, matches []Match) (int, error) {
+	written := 0
+	last := 0
+	for _, m := range matches {
+		n, err := w.Write(line[last:m.Start])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		n, err = w.WriteString(m.Replacement)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		last = m.End
+	}
+	n, err := w.Write(line[last:])
+	return written + n, err
+}
+
+// applyMatches 按匹配结果生成替换后的行
+func applyMatches(line string, matches []Match) string {
+	if len(matches) == 0 {
+		return line
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(line[last:m.Start])
+		sb.WriteString(m.Replacement)
+		last = m.End
+	}
+	sb.WriteString(line[last:])
+	return sb.String()
+}
+
+// lineMatch 记录某一行的匹配，用于预览输出
+type lineMatch struct {
+	LineNo  int
+	Line    string
+	Matches []Match
+}
+
+// formatHighlight 将行内匹配标记为 [原文]→[替换]，便于确认匹配位置
+func formatHighlight(line string, matches []Match) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(line[last:m.Start])
+		fmt.Fprintf(&sb, "[%s]→[%s]", line[m.Start:m.End], m.Replacement)
+		last = m.End
+	}
+	sb.WriteString(line[last:])
+	return sb.String()
+}
+
+// sameReplacements 判断每处匹配的替换都与原文相同，即替换后该行不变
+func sameReplacements(line string, matches []Match) bool {
+	for _, m := range matches {
+		if m.Replacement != line[m.Start:m.End] {
+			return false
+		}
+	}
+	return true
+}
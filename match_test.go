@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// 行尾锚定在 CRLF 文件中同样生效，计数与替换一致
+func TestAnchorCRLF(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher *anchoredMatcher
+		in      string
+		want    string
+		matches int
+	}{
+		{"end", &anchoredMatcher{search: "foo", replace: "bar", end: true}, "a foo\r\nfoo b\r\nfoo", "a bar\r\nfoo b\r\nbar", 2},
+		{"both", &anchoredMatcher{search: "foo", replace: "bar", start: true, end: true}, "foo\r\nfoo foo\r\n", "bar\r\nfoo foo\r\n", 1},
+		{"start", &anchoredMatcher{search: "foo", replace: "bar", start: true}, "foo x\r\n x foo\r\n", "bar x\r\n x foo\r\n", 1},
+		{"mixed", &anchoredMatcher{search: "foo", replace: "bar", end: true}, "foo\nfoo\r\nfoo\r", "bar\nbar\r\nbar\r", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted, replaced, out := countAndReplace(t, tt.in, tt.matcher)
+			if counted != tt.matches || replaced != tt.matches {
+				t.Errorf("计数 %d、替换 %d，应为 %d", counted, replaced, tt.matches)
+			}
+			if out != tt.want {
+				t.Errorf("替换后 %q，应为 %q", out, tt.want)
+			}
+		})
+	}
+}
+
+// countLiteral 的块边界：第一次读取 countChunkSize+len(search)-1 字节，
+// 之后每块在保留的尾部之后读取
+func TestCountLiteralChunkBoundary(t *testing.T) {
+	tests := []struct {
+		search string
+		insert string // 放在块边界附近的内容
+	}{
+		{"needle", "needle"},
+		{"x", "x"},
+		{"aaa", strings.Repeat("a", 7)}, // 重叠的前缀：不重叠计数为 2
+		{"abab", "ababababab"},          // 自身重叠的模式
+		{"aab", "aaab"},                 // 前缀的前缀落在上一块末尾
+		{"needle", "needl"},             // 不完整的匹配不能被计数
+		{"needle", "needleneedle"},      // 两处紧邻的匹配
+	}
+	for _, tt := range tests {
+		// The first read ends at countChunkSize+len(search)-1 and keeps
+		// len(search)-1 bytes of filler, so the second ends one chunk later
+		first := countChunkSize + len(tt.search) - 1
+		for _, boundary := range []int{first, first + countChunkSize} {
+			for offset := -len(tt.insert) - 1; offset <= 1; offset++ {
+				data := []byte(strings.Repeat(".", boundary+offset) + tt.insert + strings.Repeat(".", 100))
+				want := bytes.Count(data, []byte(tt.search))
+
+				got, err := countLiteral(bytes.NewReader(data), []byte(tt.search))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("%q 在 %d%+d 处: 计数 %d，应为 %d", tt.insert, boundary, offset, got, want)
+				}
+			}
+		}
+	}
+}
+
+// 短读取（每次一个字节）和多个块不影响计数
+func TestCountLiteralShortReads(t *testing.T) {
+	data := []byte(strings.Repeat("xx needle ne", 3*countChunkSize/12))
+	want := bytes.Count(data, []byte("needle"))
+	got, err := countLiteral(iotest.OneByteReader(bytes.NewReader(data)), []byte("needle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("计数 %d，应为 %d", got, want)
+	}
+}
+
+// --nth 只替换每行的第 N 处匹配；不足 N 处的行不变，也不计数
+func TestNthMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		in      string
+		want    string
+		matches int
+	}{
+		{"second", 2, "a,b,c,d\n", "a,b;c,d\n", 1},
+		{"fewer", 3, "a,b\nc\n", "a,b\nc\n", 0},
+		{"exactly", 2, "a,b,c\na,b\n", "a,b;c\na,b\n", 1},
+		{"mixed", 2, "a,b,c\nx,y\n,,\n", "a,b;c\nx,y\n,;\n", 2},
+		{"first", 1, "a,b\r\n,", "a;b\r\n;", 2},
+		{"none", 1, "abc", "abc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := &nthMatcher{inner: newLiteralMatcher(",", ";"), n: tt.n}
+			counted, replaced, out := countAndReplace(t, tt.in, matcher)
+			if counted != tt.matches || replaced != tt.matches {
+				t.Errorf("计数 %d、替换 %d，应为 %d", counted, replaced, tt.matches)
+			}
+			if out != tt.want {
+				t.Errorf("替换后 %q，应为 %q", out, tt.want)
+			}
+		})
+	}
+}
+
+// --occurrences N 替换每行的前 N 处；不足 N 处的行全部替换
+func TestFirstMatcher(t *testing.T) {
+	tests := []struct {
+		n       int
+		in      string
+		want    string
+		matches int
+	}{
+		{1, "a,b,c\n", "a;b,c\n", 1},
+		{2, "a,b,c,d\n", "a;b;c,d\n", 2},
+		{3, "a,b\nc\n", "a;b\nc\n", 1},
+		{2, "a,b,c\n,\n", "a;b;c\n;\n", 3},
+	}
+	for _, tt := range tests {
+		matcher := &firstMatcher{inner: newLiteralMatcher(",", ";"), n: tt.n}
+		counted, replaced, out := countAndReplace(t, tt.in, matcher)
+		if counted != tt.matches || replaced != tt.matches {
+			t.Errorf("%d, %q: 计数 %d、替换 %d，应为 %d", tt.n, tt.in, counted, replaced, tt.matches)
+		}
+		if out != tt.want {
+			t.Errorf("%d, %q: 替换后 %q，应为 %q", tt.n, tt.in, out, tt.want)
+		}
+	}
+}
+
+func TestParseOccurrences(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"all", 0, false},
+		{"first", 1, false},
+		{"1", 1, false},
+		{"3", 3, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"some", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseOccurrences(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseOccurrences(%q) = %d, %v，应为 %d（错误: %v）", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// --swap 互换两个字符串：替换结果不再被匹配；两者重叠时起点靠左者优先，
+// 起点相同时较长者优先，并按方向分别计数
+func TestSwapMatcher(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		line   string
+		want   string
+		counts [2]int
+	}{
+		{"primary", "secondary", "primary secondary", "secondary primary", [2]int{1, 1}},
+		{"ab", "abc", "abc ab", "ab abc", [2]int{1, 1}},
+		{"ab", "abc", "abcab", "ababc", [2]int{1, 1}},
+		{"abc", "ab", "ab abc abd", "abc ab abcd", [2]int{1, 2}},
+		{"a", "ab", "aab", "aba", [2]int{1, 1}},
+		{"ab", "bc", "abc", "bcc", [2]int{1, 0}},
+		{"ab", "bc", "bcab", "abbc", [2]int{1, 1}},
+		{"x", "y", "xyyx", "yxxy", [2]int{2, 2}},
+		{"foo", "bar", "none here", "none here", [2]int{0, 0}},
+	}
+	for _, tt := range tests {
+		matches := newSwapMatcher(tt.a, tt.b).FindAll(tt.line)
+		if got := applyMatches(tt.line, matches); got != tt.want {
+			t.Errorf("互换 %q/%q 作用于 %q = %q，应为 %q", tt.a, tt.b, tt.line, got, tt.want)
+		}
+		var counts [2]int
+		for _, m := range matches {
+			counts[m.Rule]++
+		}
+		if counts != tt.counts {
+			t.Errorf("互换 %q/%q 作用于 %q: 按方向计数 %v，应为 %v", tt.a, tt.b, tt.line, counts, tt.counts)
+		}
+	}
+}
+
+// --swap A B 从位置参数取第二个字符串，其余参数仍是路径
+func TestTakeSwapArgs(t *testing.T) {
+	defer func() { cfg = Config{} }()
+
+	cfg = Config{swapFrom: "primary", swapSet: true}
+	rest, err := takeSwapArgs([]string{"secondary", "src", "docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Swap || cfg.SourceString != "primary" || cfg.TargetString != "secondary" || strings.Join(rest, " ") != "src docs" {
+		t.Errorf("互换 %v: %q→%q，路径 %q", cfg.Swap, cfg.SourceString, cfg.TargetString, rest)
+	}
+
+	tests := []struct {
+		name string
+		set  func(c *Config)
+		args []string
+	}{
+		{"missing", func(c *Config) {}, nil},
+		{"from", func(c *Config) { c.SourceString = "x" }, []string{"secondary"}},
+		{"to", func(c *Config) { c.targetSet = true }, []string{"secondary"}},
+	}
+	for _, tt := range tests {
+		cfg = Config{swapFrom: "primary", swapSet: true}
+		tt.set(&cfg)
+		if _, err := takeSwapArgs(tt.args); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: 错误 = %v，应属于 ErrInvalidConfig", tt.name, err)
+		}
+	}
+}
+
+// 替换模板中的分组引用按 regexp.Expand 的规则解析，引用不存在的分组在启动时报错
+func TestCheckGroupRefs(t *testing.T) {
+	pattern := regexp.MustCompile(`(?P<major>\d+)\.(\d+)(?P<pre>-\w+)?`)
+	tests := []struct {
+		template string
+		wantErr  string // 错误信息中应含有的内容，为空时不应出错
+	}{
+		{"${major}.${2}.99", ""},
+		{"$major.$2.99", ""},
+		{"${major}_x", ""},
+		{"$major_x", "${major}_x"},
+		{"${1}x", ""},
+		{"$1x", "${1}x"},
+		{"$3$pre", ""},
+		{"$4", "第 4 组"},
+		{"${minor}", "不存在的分组 minor"},
+		{"$$minor", ""},
+		{"$$$minor", "不存在的分组 minor"},
+		{"cost: $", ""},
+		{"$-1", ""},
+		{"${major", ""},
+		{"${}", ""},
+		{"$01", "不存在的分组 01"},
+		{"$0", ""},
+	}
+	for _, tt := range tests {
+		err := checkGroupRefs(pattern, tt.template)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: %v", tt.template, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: 错误 = %v，应含有 %q", tt.template, err, tt.wantErr)
+		}
+	}
+}
+
+// 命名分组和编号分组混用时替换结果与 regexp.Expand 一致
+func TestNamedGroupReplace(t *testing.T) {
+	pattern := regexp.MustCompile(`(?P<major>\d+)\.(?P<minor>\d+)|v(\d+)`)
+	tests := []struct {
+		template string
+		line     string
+		want     string
+	}{
+		{"${major}.${minor}.99", "go 1.22 and 3.4", "go 1.22.99 and 3.4.99"},
+		{"$2.$1", "1.22", "22.1"},
+		{"${major}_$minor", "1.22", "1_22"},
+		{"[$3]$$", "v7 v8", "[7]$ [8]$"},
+		{"${major}x$3", "1.2 v3", "1x x3"},
+	}
+	for _, tt := range tests {
+		if err := checkGroupRefs(pattern, tt.template); err != nil {
+			t.Errorf("%q: %v", tt.template, err)
+			continue
+		}
+		m := newRegexMatcher(pattern, tt.template, true)
+		if got := applyMatches(tt.line, m.FindAll(tt.line)); got != tt.want {
+			t.Errorf("%q 作用于 %q = %q，应为 %q", tt.template, tt.line, got, tt.want)
+		}
+	}
+}
+
+// configMatcher 按 validateMatchFlags 的方式编译模式并创建匹配器
+func configMatcher(t *testing.T, config *Config) Matcher {
+	t.Helper()
+	pattern, err := compilePattern(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.pattern = pattern
+	return buildMatcher(config)
+}
+
+// matcherCase 是 --ignore-whitespace、--ignore-case 和 --word 的表格测试用例
+type matcherCase struct {
+	from, to string
+	in       string
+	want     string
+	matches  int
+}
+
+// runMatcherCases 对每个用例分别计数和替换，两者的匹配数都应与预期相同
+func runMatcherCases(t *testing.T, tests []matcherCase, set func(c *Config)) {
+	t.Helper()
+	for _, tt := range tests {
+		config := &Config{SourceString: tt.from, TargetString: tt.to}
+		set(config)
+		counted, replaced, out := countAndReplace(t, tt.in, configMatcher(t, config))
+		if counted != tt.matches || replaced != tt.matches {
+			t.Errorf("%q 在 %q 中: 计数 %d、替换 %d，应为 %d", tt.from, tt.in, counted, replaced, tt.matches)
+		}
+		if out != tt.want {
+			t.Errorf("%q 在 %q 中: 替换后 %q，应为 %q", tt.from, tt.in, out, tt.want)
+		}
+	}
+}
+
+// --ignore-whitespace 把空格和制表符组成的一段视为等价，替换文本原样写入
+func TestIgnoreWhitespace(t *testing.T) {
+	runMatcherCases(t, []matcherCase{
+		{"foo( a, b )", "bar", "foo( a, b )\n", "bar\n", 1},
+		{"foo( a, b )", "bar", "foo(a,b) foo(  a,\tb )\n", "bar bar\n", 2},
+		{"foo(a,b)", "bar", "foo( a , b )\n", "foo( a , b )\n", 0},
+		// A run between two words must not disappear entirely
+		{"int  x", "y", "int x int\t\tx intx\n", "y y intx\n", 2},
+		{"a b", "c", "a b\na  b\n", "c\nc\n", 2},
+		// Leading and trailing runs are required
+		{" = ", ":", "a = b a=b a\t=  b\n", "a:b a=b a:b\n", 2},
+		// Everything else is literal, regex metacharacters included
+		{"a.b (c)", "x", "a.b (c) axb (c) a.b(c)\n", "x axb (c) x\n", 2},
+		{"f( x )", "$1 ${x}", "f(x)\n", "$1 ${x}\n", 1},
+		{"a b", "c", "a b\r\n", "c\r\n", 1},
+	}, func(c *Config) { c.IgnoreWhitespace = true })
+
+	runMatcherCases(t, []matcherCase{
+		{"Foo( A )", "bar", "foo(a) FOO( A )\n", "bar bar\n", 2},
+	}, func(c *Config) { c.IgnoreWhitespace, c.IgnoreCase = true, true })
+}
+
+// --ignore-case 匹配任意大小写的组合，替换为 --to 的原文
+func TestIgnoreCase(t *testing.T) {
+	runMatcherCases(t, []matcherCase{
+		{"Copyright", "(C)", "Copyright copyright COPYRIGHT cOpYrIgHt\n", "(C) (C) (C) (C)\n", 4},
+		{"copyright", "Copyright", "Copyright\n", "Copyright\n", 1},
+		// Matches do not overlap, whatever their case
+		{"aa", "b", "aAa AAAA\n", "ba bb\n", 3},
+		{"a.b", "x", "A.B axb\n", "x axb\n", 1},
+		{"x", "$0", "X\n", "$0\n", 1},
+		{"ärger", "Ärger", "ÄRGER ärger\n", "Ärger Ärger\n", 2},
+		// Simple case folding: ß does not match SS, but K matches the Kelvin sign
+		{"straße", "x", "STRASSE STRAẞE\n", "STRASSE x\n", 1},
+		{"k", "x", "K k K\n", "x x x\n", 3},
+		{"foo", "bar", "no match\n", "no match\n", 0},
+	}, func(c *Config) { c.IgnoreCase = true })
+}
+
+// --word 只替换前后都不是单词字符的匹配；字母、数字、下划线和 Unicode 字母都算单词字符
+func TestWordMatch(t *testing.T) {
+	runMatcherCases(t, []matcherCase{
+		{"id", "identifier", "id uuid valid width id\n", "identifier uuid valid width identifier\n", 2},
+		{"id", "identifier", "(id) id, id_x id2 x-id\n", "(identifier) identifier, id_x id2 x-identifier\n", 3},
+		{"id", "ID", "idé éid id\n", "idé éid ID\n", 1},
+		// A rejected position does not hide a later word
+		{"id", "ID", "idid id\n", "idid ID\n", 1},
+		{"id", "ID", "id\tid\r\n", "ID\tID\r\n", 2},
+		{"a b", "c", "a b xa b a bx\n", "c xa b a bx\n", 1},
+		{"-", "+", "a-b - x\n", "a-b + x\n", 1},
+	}, func(c *Config) { c.Word = true })
+
+	runMatcherCases(t, []matcherCase{
+		{"Id", "key", "ID uuid id\n", "key uuid key\n", 2},
+	}, func(c *Config) { c.Word, c.IgnoreCase = true, true })
+	runMatcherCases(t, []matcherCase{
+		{`i\w`, "x", "id in uuid\n", "x x uuid\n", 2},
+	}, func(c *Config) { c.Word, c.Regex = true, true })
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// minifiedRule 是 --skip-minified 判定压缩代码的阈值
+type minifiedRule struct {
+	prefix     int // 检查开头的字节数
+	lineLength int // 平均行长超过该值视为压缩代码
+}
+
+// minifiedRuleFor 从配置中取出阈值
+func minifiedRuleFor(config *Config) minifiedRule {
+	return minifiedRule{prefix: config.MinifiedPrefix << 10, lineLength: config.MinifiedLineLength}
+}
+
+// isMinified 按开头的内容判断文件是否是压缩过的 JS/CSS、source map 等：
+// 开头 prefix 字节内没有换行符，或者其中的平均行长超过 lineLength
+func isMinified(fsys FileSystem, path string, rule minifiedRule, stats *IOStats) (bool, error) {
+	file, err := stats.open(fsys, path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, rule.prefix)
+	n, err := io.ReadFull(file, buf)
+	stats.addRead(int64(n))
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return looksMinified(buf[:n], n == len(buf), rule), nil
+}
+
+// looksMinified 判断文件开头的 data 是否像压缩代码；full 表示 data 没有读到文件末尾
+func looksMinified(data []byte, full bool, rule minifiedRule) bool {
+	newlines := bytes.Count(data, []byte{'\n'})
+	if newlines == 0 {
+		// A short single-line file is just a short file
+		return full
+	}
+
+	// An unterminated last line still counts as a line
+	lines := newlines
+	if data[len(data)-1] != '\n' {
+		lines++
+	}
+	return len(data)/lines > rule.lineLength
+}
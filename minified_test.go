@@ -0,0 +1,75 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/minified 中的夹具按开头的内容判断，而不是按文件名
+func TestIsMinified(t *testing.T) {
+	defaults := minifiedRule{prefix: 16 << 10, lineLength: 500}
+	tests := []struct {
+		name string
+		rule minifiedRule
+		want bool
+	}{
+		{"app.min.js", defaults, true},     // a banner line, then lines of ~2.5KB
+		{"app.min.js.map", defaults, true}, // one long line
+		{"app.js", defaults, false},
+		{"tiny.min.js", defaults, false},   // a short single line is just a short file
+		{"style.min.css", defaults, false}, // one line, but shorter than the prefix
+		{"style.min.css", minifiedRule{prefix: 1 << 10, lineLength: 500}, true},
+		{"app.min.js", minifiedRule{prefix: 16 << 10, lineLength: 4096}, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join("testdata", "minified", tt.name)
+		got, err := isMinified(osFS{}, path, tt.rule, &IOStats{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s（开头 %d 字节、行长 %d）判定为 %v，应为 %v", tt.name, tt.rule.prefix, tt.rule.lineLength, got, tt.want)
+		}
+	}
+}
+
+// --skip-minified 跳过压缩代码，只替换可读的源文件和比检查范围短的单行文件；
+// 不加时所有文件都替换
+func TestSkipMinifiedRun(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		dir := copyFixtures(t, "minified")
+		before := map[string]string{}
+		for _, name := range []string{"app.min.js", "app.min.js.map"} {
+			before[name] = readTestFile(t, filepath.Join(dir, name))
+		}
+
+		result := runTest(t, &Config{
+			SourceDir: dir, SourceString: "apiUrl", TargetString: "baseUrl",
+			SkipMinified: skip, MinifiedPrefix: 16, MinifiedLineLength: 500,
+		})
+
+		for name, content := range before {
+			got := readTestFile(t, filepath.Join(dir, name))
+			if skip && got != content {
+				t.Errorf("--skip-minified 仍然改写了 %s", name)
+			}
+			if !skip && strings.Contains(got, "apiUrl") {
+				t.Errorf("不加 --skip-minified 时 %s 没有被替换", name)
+			}
+		}
+		for _, name := range []string{"app.js", "tiny.min.js", "style.min.css"} {
+			if got := readTestFile(t, filepath.Join(dir, name)); strings.Contains(got, "apiUrl") {
+				t.Errorf("skip=%v: %s 没有被替换", skip, name)
+			}
+		}
+
+		skipped := 0
+		if skip {
+			skipped = 2
+		}
+		if got := result.Skipped[SkipMinified]; int(got) != skipped {
+			t.Errorf("skip=%v: 作为压缩代码跳过 %d 个文件，应为 %d 个", skip, got, skipped)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// 含有换行符的 --from 无法逐行匹配，改为读入整个文件按字面查找。
+// --normalize-eol-in-pattern（默认开启）时，模式中的 \r\n、\n 和 \r 可以匹配
+// 文件中任意一种行结束符，--to 中的换行符写成匹配处原有的行结束符：
+// 从 Windows 文件复制的模式同样能匹配 LF 文件，替换也不会混入另一种换行符。
+// 关闭时模式按字节精确匹配。
+
+// lineBreaks 匹配任意一种行结束符
+var lineBreaks = regexp.MustCompile(`\r\n|\n|\r`)
+
+// multilinePattern 判断 --from 是否跨行
+func multilinePattern(search string) bool {
+	return strings.ContainsAny(search, "\r\n")
+}
+
+// multilineMatcher 在整个文件内容上查找跨行的字面模式
+type multilineMatcher struct {
+	search  []byte
+	replace string
+	pattern *regexp.Regexp // 归一化换行符时使用，否则为 nil
+}
+
+// newMultilineMatcher 创建跨行匹配器；normalize 为 true 时模式中的换行符匹配任意行结束符
+func newMultilineMatcher(search, replace string, normalize bool) *multilineMatcher {
+	m := &multilineMatcher{search: []byte(search), replace: replace}
+	if normalize {
+		pieces := lineBreaks.Split(search, -1)
+		for i, piece := range pieces {
+			pieces[i] = regexp.QuoteMeta(piece)
+		}
+		m.pattern = regexp.MustCompile(strings.Join(pieces, `(?:\r\n|\n|\r)`))
+	}
+	return m
+}
+
+// replaceAll 替换 data 中的所有匹配，返回替换后的内容和匹配数
+func (m *multilineMatcher) replaceAll(data []byte) ([]byte, int) {
+	if m.pattern == nil {
+		n := bytes.Count(data, m.search)
+		if n == 0 {
+			return data, 0
+		}
+		return bytes.ReplaceAll(data, m.search, []byte(m.replace)), n
+	}
+
+	locs := m.pattern.FindAllIndex(data, -1)
+	if len(locs) == 0 {
+		return data, 0
+	}
+	var out bytes.Buffer
+	out.Grow(len(data))
+	last := 0
+	for _, loc := range locs {
+		out.Write(data[last:loc[0]])
+		// The pattern has a line break, so every match contains one
+		eol := lineBreaks.Find(data[loc[0]:loc[1]])
+		out.WriteString(lineBreaks.ReplaceAllLiteralString(m.replace, string(eol)))
+		last = loc[1]
+	}
+	out.Write(data[last:])
+	return out.Bytes(), len(locs)
+}
+
+// processMultiline 以跨行模式处理单个文件
+func processMultiline(config *Config, result *Result, filePath string, opts writeOptions) error {
+	return processWholeFile(config, result, contentFS(config), filePath, config.multiline.replaceAll, "替换 %s 文件", opts)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// 归一化时模式中的任意换行符匹配文件中的任意行结束符，替换文本使用匹配处的行结束符
+func TestMultilineMatcher(t *testing.T) {
+	tests := []struct {
+		name      string
+		search    string
+		replace   string
+		normalize bool
+		in        string
+		want      string
+		count     int
+	}{
+		{"crlf pattern, lf file", "a\r\nb", "x\r\ny", true, "a\nb\n", "x\ny\n", 1},
+		{"lf pattern, crlf file", "a\nb", "x\ny", true, "a\r\nb\r\n", "x\r\ny\r\n", 1},
+		{"cr file", "a\nb", "x\ny", true, "a\rb\r", "x\ry\r", 1},
+		{"mixed file", "a\nb", "x\ny", true, "a\r\nb\na\nb\r\n", "x\r\ny\nx\ny\r\n", 2},
+		{"pattern ends with break", "a\n", "b\n", true, "a\r\na\n", "b\r\nb\n", 2},
+		{"extra lines in replacement", "a\nb", "x\ny\nz", true, "a\r\nb", "x\r\ny\r\nz", 1},
+		{"replacement without break", "a\nb", "ab", true, "a\r\nb", "ab", 1},
+		{"metacharacters", "a.*\n(b)", "c", true, "a.*\r\n(b)\naX\nb", "c\naX\nb", 1},
+		{"break is not space", "a\nb", "c", true, "a b\n", "a b\n", 0},
+		{"exact crlf", "a\r\nb", "c", false, "a\nb\r\na\r\nb", "a\nb\r\nc", 1},
+		{"exact lf", "a\nb", "c", false, "a\r\nb", "a\r\nb", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMultilineMatcher(tt.search, tt.replace, tt.normalize)
+			out, n := m.replaceAll([]byte(tt.in))
+			if string(out) != tt.want || n != tt.count {
+				t.Errorf("结果 %q（%d 处），应为 %q（%d 处）", out, n, tt.want, tt.count)
+			}
+		})
+	}
+}
+
+// 跨行替换走完整的运行流程：试验运行不写文件，正式运行按文件自己的换行符替换
+func TestMultilineRun(t *testing.T) {
+	dir := t.TempDir()
+	crlf := writeTestFile(t, dir, "crlf.txt", "head\r\nfoo\r\nbar\r\ntail\r\n", 0o644)
+	lf := writeTestFile(t, dir, "lf.txt", "foo\nbar", 0o644)
+	same := writeTestFile(t, dir, "same.txt", "baz\nqux\n", 0o644)
+	writeTestFile(t, dir, "none.txt", "foo bar\n", 0o644)
+
+	config := &Config{SourceDir: dir, SourceString: "foo\r\nbar", TargetString: "baz\r\nqux", NormalizeEOLInPattern: true, Trial: true}
+	result := runTest(t, config)
+	if result.Matches != 2 || result.FilesMatches != 2 {
+		t.Errorf("试验运行: %d 个文件 %d 处匹配，应为 2 和 2", result.FilesMatches, result.Matches)
+	}
+	if got := readTestFile(t, crlf); got != "head\r\nfoo\r\nbar\r\ntail\r\n" {
+		t.Errorf("试验运行修改了文件: %q", got)
+	}
+
+	config = &Config{SourceDir: dir, SourceString: "foo\r\nbar", TargetString: "baz\r\nqux", NormalizeEOLInPattern: true}
+	result = runTest(t, config)
+	if result.Matches != 2 || result.FilesMatches != 2 {
+		t.Errorf("替换: %d 个文件 %d 处匹配，应为 2 和 2", result.FilesMatches, result.Matches)
+	}
+	for path, want := range map[string]string{
+		crlf: "head\r\nbaz\r\nqux\r\ntail\r\n",
+		lf:   "baz\nqux",
+		same: "baz\nqux\n",
+	} {
+		if got := readTestFile(t, path); got != want {
+			t.Errorf("%s: %q，应为 %q", filepath.Base(path), got, want)
+		}
+	}
+	assertNoTempFiles(t, dir)
+}
@@ -0,0 +1,140 @@
+package main
+
+// multiMatcher 用 Aho–Corasick 自动机在一次扫描中查找多个字符串，
+// 耗时与规则数量无关。匹配按从左到右的顺序选取，互不重叠：
+// 起点最靠左的匹配优先，起点相同时优先较长者，替换结果不会被再次匹配。
+type multiMatcher struct {
+	nodes    []acNode
+	lens     []int
+	replaces []string
+	rules    []int
+}
+
+// acNode 是自动机中的一个状态，对应某个字符串的前缀
+type acNode struct {
+	next  map[byte]int32
+	fail  int32 // 最长的、同时也是某个前缀的真后缀
+	depth int32 // 前缀长度
+	out   int32 // 在此结束的最长字符串序号，没有为 -1
+}
+
+// newMultiMatcher 创建多字符串匹配器，patterns[i] 替换为 replaces[i]，
+// 计入规则 rules[i]。重复的字符串以第一个为准，空字符串被忽略。
+func newMultiMatcher(patterns, replaces []string, rules []int) *multiMatcher {
+	m := &multiMatcher{
+		nodes:    []acNode{{out: -1}},
+		lens:     make([]int, len(patterns)),
+		replaces: replaces,
+		rules:    rules,
+	}
+
+	for i, p := range patterns {
+		m.lens[i] = len(p)
+		if p == "" {
+			continue
+		}
+		state := int32(0)
+		for k := 0; k < len(p); k++ {
+			next, ok := m.nodes[state].next[p[k]]
+			if !ok {
+				next = int32(len(m.nodes))
+				m.nodes = append(m.nodes, acNode{depth: m.nodes[state].depth + 1, out: -1})
+				if m.nodes[state].next == nil {
+					m.nodes[state].next = make(map[byte]int32)
+				}
+				m.nodes[state].next[p[k]] = next
+			}
+			state = next
+		}
+		if m.nodes[state].out < 0 {
+			m.nodes[state].out = int32(i)
+		}
+	}
+
+	// Breadth-first, so every fail target is complete before it is used.
+	// A state without its own pattern inherits the longest one ending
+	// at its fail state.
+	queue := []int32{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for b, child := range m.nodes[state].next {
+			fail := m.nodes[state].fail
+			for {
+				if next, ok := m.nodes[fail].next[b]; ok {
+					m.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if m.nodes[child].out < 0 {
+				m.nodes[child].out = m.nodes[m.nodes[child].fail].out
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	return m
+}
+
+// step 从 state 读入一个字节后的状态
+func (m *multiMatcher) step(state int32, b byte) int32 {
+	for {
+		if next, ok := m.nodes[state].next[b]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = m.nodes[state].fail
+	}
+}
+
+// FindAll 从左到右查找所有互不重叠的匹配。
+//
+// The automaton reports the longest pattern ending at each position,
+// which is also the leftmost one ending there. A candidate is kept until
+// no pattern still in progress can start at or before it: the current
+// state's depth bounds how far back a future match may begin. The scan
+// then resumes behind the accepted match.
+func (m *multiMatcher) FindAll(line string) []Match {
+	var matches []Match
+	for pos := 0; pos < len(line); {
+		best, bestStart, bestEnd := int32(-1), 0, 0
+		state := int32(0)
+		for i := pos; i < len(line); i++ {
+			state = m.step(state, line[i])
+			node := &m.nodes[state]
+			if best >= 0 && i+1-int(node.depth) > bestStart {
+				break
+			}
+			if node.out < 0 {
+				continue
+			}
+
+			end := i + 1
+			start := end - m.lens[node.out]
+			if best < 0 || start < bestStart || (start == bestStart && end > bestEnd) {
+				best, bestStart, bestEnd = node.out, start, end
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		matches = append(matches, Match{
+			Start:       bestStart,
+			End:         bestEnd,
+			Replacement: m.replaces[best],
+			Rule:        m.rules[best],
+		})
+		pos = bestEnd
+	}
+	return matches
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// naiveFindAll 是 multiMatcher 的参照实现：从左到右在每个位置逐一尝试所有字符串，
+// 取最长者（长度相同时取先出现的规则），匹配后从其末尾继续
+func naiveFindAll(patterns, replaces []string, rules []int, line string) []Match {
+	var matches []Match
+	for pos := 0; pos < len(line); {
+		best := -1
+		for i, p := range patterns {
+			if p != "" && strings.HasPrefix(line[pos:], p) && (best < 0 || len(p) > len(patterns[best])) {
+				best = i
+			}
+		}
+		if best < 0 {
+			pos++
+			continue
+		}
+		end := pos + len(patterns[best])
+		matches = append(matches, Match{Start: pos, End: end, Replacement: replaces[best], Rule: rules[best]})
+		pos = end
+	}
+	return matches
+}
+
+// 随机的字符串集合和行上，自动机与逐位置尝试的结果完全相同
+func TestMultiMatcherNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	word := func(maxLen int) string {
+		b := make([]byte, rng.Intn(maxLen+1))
+		for i := range b {
+			b[i] = "abc "[rng.Intn(4)]
+		}
+		return string(b)
+	}
+	for range 20000 {
+		n := 1 + rng.Intn(8)
+		patterns, replaces, rules := make([]string, n), make([]string, n), make([]int, n)
+		for i := range patterns {
+			patterns[i] = word(4)
+			replaces[i] = fmt.Sprint(i)
+			rules[i] = i
+		}
+		line := word(30)
+
+		got := newMultiMatcher(patterns, replaces, rules).FindAll(line)
+		want := naiveFindAll(patterns, replaces, rules, line)
+		if !slices.Equal(got, want) {
+			t.Fatalf("字符串 %q 在 %q 中: %+v，应为 %+v", patterns, line, got, want)
+		}
+	}
+}
+
+// 重叠时起点靠左者优先，起点相同时较长者优先，每处匹配计入自己的规则
+func TestMultiMatcherOverlap(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		line     string
+		want     string
+		rules    []int
+	}{
+		{[]string{"he", "she", "hers"}, "ushers", "u<1>rs", []int{1}},
+		{[]string{"he", "hers"}, "hershe", "<1><0>", []int{1, 0}},
+		{[]string{"ab", "abc", "bcd"}, "abcd", "<1>d", []int{1}},
+		{[]string{"a", "aa", "aaa"}, "aaaaa", "<2><1>", []int{2, 1}},
+		{[]string{"b", "abc"}, "abd", "a<0>d", []int{0}},
+		{[]string{"x", "x"}, "xx", "<0><0>", []int{0, 0}},
+		{[]string{"", "a"}, "ba", "b<1>", []int{1}},
+	}
+	for _, tt := range tests {
+		replaces, rules := make([]string, len(tt.patterns)), make([]int, len(tt.patterns))
+		for i := range tt.patterns {
+			replaces[i] = fmt.Sprintf("<%d>", i)
+			rules[i] = i
+		}
+		matches := newMultiMatcher(tt.patterns, replaces, rules).FindAll(tt.line)
+		var got []int
+		for _, m := range matches {
+			got = append(got, m.Rule)
+		}
+		if out := applyMatches(tt.line, matches); out != tt.want || !slices.Equal(got, tt.rules) {
+			t.Errorf("%q 在 %q 中: %q（规则 %v），应为 %q（规则 %v）", tt.patterns, tt.line, out, got, tt.want, tt.rules)
+		}
+	}
+}
+
+// 150 条规则时一次扫描的自动机、逐位置尝试所有规则，以及 --map 按规则逐条扫描的对比
+func BenchmarkMultiMatcher(b *testing.B) {
+	const rules = 150
+	patterns, replaces, ids := make([]string, rules), make([]string, rules), make([]int, rules)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("identifier%03d", i)
+		replaces[i] = fmt.Sprintf("renamed%03d", i)
+		ids[i] = i
+	}
+	var sb strings.Builder
+	for i := range 20 {
+		fmt.Fprintf(&sb, "call(identifier%03d, other_value, %d); ", i*7%rules, i)
+	}
+	line := sb.String()
+
+	b.Run("aho-corasick", func(b *testing.B) {
+		m := newMultiMatcher(patterns, replaces, ids)
+		b.SetBytes(int64(len(line)))
+		for b.Loop() {
+			m.FindAll(line)
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		b.SetBytes(int64(len(line)))
+		for b.Loop() {
+			naiveFindAll(patterns, replaces, ids, line)
+		}
+	})
+	b.Run("per-rule", func(b *testing.B) {
+		chain := &chainMatcher{}
+		for i := range patterns {
+			chain.rules = append(chain.rules, newLiteralMatcher(patterns[i], replaces[i]))
+		}
+		b.SetBytes(int64(len(line)))
+		for b.Loop() {
+			chain.FindAll(line)
+		}
+	})
+}
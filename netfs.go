@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilesystemInfo 描述一个遍历根目录所在的文件系统
+type FilesystemInfo struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+	Network bool   `json:"network"`
+}
+
+// detectFilesystems 检测每个遍历根目录所在的文件系统；无法检测的根目录不列出
+func detectFilesystems(config *Config) []FilesystemInfo {
+	roots := config.Paths
+	if len(roots) == 0 {
+		roots = []string{config.SourceDir}
+	}
+
+	var infos []FilesystemInfo
+	for _, root := range roots {
+		name, network, err := filesystemType(root)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, FilesystemInfo{Path: root, Type: name, Network: network})
+	}
+	return infos
+}
+
+// formatNetworkWarning 为位于网络文件系统上的根目录生成警告，没有时返回空字符串
+func formatNetworkWarning(infos []FilesystemInfo) string {
+	var sb strings.Builder
+	for _, info := range infos {
+		if info.Network {
+			fmt.Fprintf(&sb, "\n警告：%s 位于网络文件系统 (%s) 上.\n", escapeControl(info.Path), info.Type)
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	sb.WriteString("  网络文件系统上的处理通常比本地磁盘慢一个数量级，每个文件的打开和重命名都要往返服务器；\n")
+	sb.WriteString("  可以增加 --workers 掩盖延迟，条件允许时最好直接在文件服务器上运行.\n")
+	sb.WriteString("  重命名的原子性和其他客户端的缓存取决于服务器，其他客户端可能要过一段时间才能看到修改.\n")
+	return sb.String()
+}
@@ -0,0 +1,96 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// networkErrnos 是 NFS、CIFS 等网络文件系统在连接中断或超时时返回的错误
+var networkErrnos = []syscall.Errno{
+	syscall.ESTALE,
+	syscall.ETIMEDOUT,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.EHOSTDOWN,
+	syscall.EHOSTUNREACH,
+	syscall.ENETDOWN,
+	syscall.ENETUNREACH,
+	syscall.ENETRESET,
+}
+
+// isNetworkError 判断错误是否是网络文件系统的瞬时故障
+func isNetworkError(err error) bool {
+	for _, errno := range networkErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePath 在 Linux 上不需要处理
+func normalizePath(path string) string {
+	return path
+}
+
+// filesystemNames 是常见文件系统的 statfs 魔数
+var filesystemNames = map[int64]string{
+	0xef53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683e: "btrfs",
+	0x2fc12fc1: "zfs",
+	0x01021994: "tmpfs",
+	0x794c7630: "overlay",
+	0x4d44:     "vfat",
+	0x5346544e: "ntfs",
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+	0x00c36400: "ceph",
+	0x5346414f: "afs",
+	0x73757245: "coda",
+	0x9fa0:     "proc",
+	0x62656572: "sysfs",
+	0x1cd1:     "devpts",
+	0x64626720: "debugfs",
+	0x74726163: "tracefs",
+	0x73636673: "securityfs",
+	0x27e0eb:   "cgroup",
+	0x63677270: "cgroup2",
+	0x6165676c: "pstore",
+	0xcafe4a11: "bpf",
+	0x62656570: "configfs",
+	0x42494e4d: "binfmt_misc",
+	0x19800202: "mqueue",
+	0x958458f6: "hugetlbfs",
+	0x65735543: "fusectl",
+	0x0187:     "autofs",
+	0xde5e81e4: "efivarfs",
+	0xf97cff8c: "selinuxfs",
+	0x6e736673: "nsfs",
+}
+
+// networkFilesystems 是按网络文件系统对待的类型；FUSE 多用于 sshfs 等远程挂载
+var networkFilesystems = map[string]bool{
+	"nfs": true, "smb": true, "cifs": true, "smb2": true, "fuse": true,
+	"9p": true, "ceph": true, "afs": true, "coda": true,
+}
+
+// filesystemType 返回 path 所在文件系统的类型，以及它是否是网络文件系统
+func filesystemType(path string) (string, bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", false, err
+	}
+	name, ok := filesystemNames[int64(st.Type)]
+	if !ok {
+		name = fmt.Sprintf("0x%x", st.Type)
+	}
+	return name, networkFilesystems[name], nil
+}
@@ -0,0 +1,91 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// networkErrnos 是访问网络共享时连接中断、超时或服务器不可用时返回的错误
+var networkErrnos = []windows.Errno{
+	windows.ERROR_BAD_NETPATH,
+	windows.ERROR_NETWORK_BUSY,
+	windows.ERROR_DEV_NOT_EXIST,
+	windows.ERROR_UNEXP_NET_ERR,
+	windows.ERROR_NETNAME_DELETED,
+	windows.ERROR_BAD_NET_NAME,
+	windows.ERROR_SEM_TIMEOUT,
+	windows.ERROR_NETWORK_UNREACHABLE,
+	windows.ERROR_HOST_UNREACHABLE,
+	windows.ERROR_CONNECTION_ABORTED,
+	windows.ERROR_CONNECTION_REFUSED,
+}
+
+// isNetworkError 判断错误是否是网络共享的瞬时故障
+func isNetworkError(err error) bool {
+	for _, errno := range networkErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// 长路径前缀
+const (
+	longPathPrefix = `\\?\`
+	longUNCPrefix  = `\\?\UNC\`
+)
+
+// normalizePath 把 \\?\C:\dir 和 \\?\UNC\server\share\dir 形式的参数转换为
+// 普通的 C:\dir 和 \\server\share\dir，以便 filepath 的函数正确处理卷名，
+// 根目录检查和路径比较也只需要面对一种写法
+func normalizePath(path string) string {
+	switch {
+	case strings.HasPrefix(path, longUNCPrefix):
+		return `\\` + path[len(longUNCPrefix):]
+	case strings.HasPrefix(path, longPathPrefix) && len(path) > len(longPathPrefix)+1 && path[len(longPathPrefix)+1] == ':':
+		return path[len(longPathPrefix):]
+	}
+	return path
+}
+
+// longPath 给超过 MAX_PATH 的绝对路径加上 \\?\ 前缀（UNC 路径为 \\?\UNC\），
+// 供直接调用 Windows API 的地方使用；os 包内部已经自动这样处理
+func longPath(path string) string {
+	if len(path) < windows.MAX_PATH-12 || strings.HasPrefix(path, longPathPrefix) || !filepath.IsAbs(path) {
+		return path
+	}
+	if strings.HasPrefix(path, `\\`) {
+		return longUNCPrefix + path[2:]
+	}
+	return longPathPrefix + path
+}
+
+// filesystemType 返回 path 所在卷的文件系统名称（NTFS、ReFS 等），
+// 以及它是否位于网络共享或映射的网络驱动器上
+func filesystemType(path string) (string, bool, error) {
+	p, err := windows.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return "", false, err
+	}
+
+	root := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &root[0], uint32(len(root))); err != nil {
+		return "", false, err
+	}
+	network := windows.GetDriveType(&root[0]) == windows.DRIVE_REMOTE || strings.HasPrefix(path, `\\`)
+
+	name := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(&root[0], nil, 0, nil, nil, nil, &name[0], uint32(len(name))); err != nil {
+		if network {
+			return "smb", true, nil
+		}
+		return "", false, err
+	}
+	return windows.UTF16ToString(name), network, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// networkRetries 是网络错误后的重试次数，第 i 次重试前等待 networkRetryDelay << i
+const networkRetries = 3
+
+var networkRetryDelay = 200 * time.Millisecond
+
+// networkError 标记重试后仍然失败的网络错误，可用 errors.Is(err, ErrNetwork) 判断
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return fmt.Sprintf("网络错误（已重试 %d 次）: %v", networkRetries, e.err)
+}
+
+func (e *networkError) Unwrap() error        { return e.err }
+func (e *networkError) Is(target error) bool { return target == ErrNetwork }
+
+// retryNetwork 执行 op，遇到网络文件系统的瞬时错误时等待后重试
+func retryNetwork(op func() error) error {
+	err := op()
+	for i := 0; i < networkRetries && isNetworkError(err); i++ {
+		time.Sleep(networkRetryDelay << i)
+		err = op()
+	}
+	if isNetworkError(err) {
+		return &networkError{err: err}
+	}
+	return err
+}
+
+// retryFS 在网络共享的瞬时错误上重试单个文件操作。
+// 已经开始的读取和写入不会重试：中断的文件以网络错误报告，目标文件保持原样。
+type retryFS struct {
+	FileSystem
+}
+
+func (f retryFS) Open(name string) (r io.ReadCloser, err error) {
+	err = retryNetwork(func() error {
+		r, err = f.FileSystem.Open(name)
+		return err
+	})
+	return r, err
+}
+
+func (f retryFS) CreateTemp(dir, pattern string) (t TempFile, err error) {
+	err = retryNetwork(func() error {
+		t, err = f.FileSystem.CreateTemp(dir, pattern)
+		return err
+	})
+	return t, err
+}
+
+func (f retryFS) CreateExcl(name string, perm fs.FileMode) (t TempFile, err error) {
+	err = retryNetwork(func() error {
+		t, err = f.FileSystem.CreateExcl(name, perm)
+		return err
+	})
+	return t, err
+}
+
+func (f retryFS) Lstat(name string) (info fs.FileInfo, err error) {
+	err = retryNetwork(func() error {
+		info, err = f.FileSystem.Lstat(name)
+		return err
+	})
+	return info, err
+}
+
+// Rename 的请求可能已经在服务器上完成、只是应答丢失；
+// 重试时临时文件已不存在，说明之前的重命名已经生效
+func (f retryFS) Rename(oldpath, newpath string) error {
+	retried := false
+	return retryNetwork(func() error {
+		err := f.FileSystem.Rename(oldpath, newpath)
+		if retried && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		retried = true
+		return err
+	})
+}
+
+// Remove 同样把重试时的文件不存在视为成功
+func (f retryFS) Remove(name string) error {
+	retried := false
+	return retryNetwork(func() error {
+		err := f.FileSystem.Remove(name)
+		if retried && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		retried = true
+		return err
+	})
+}
+
+// Chmod、Lchown 和 Chtimes 转发给支持属性的底层文件系统，其他实现不保留属性
+func (f retryFS) Chmod(name string, mode fs.FileMode) error {
+	afs, ok := f.FileSystem.(attrFS)
+	if !ok {
+		return nil
+	}
+	return retryNetwork(func() error { return afs.Chmod(name, mode) })
+}
+
+func (f retryFS) Lchown(name string, uid, gid int) error {
+	afs, ok := f.FileSystem.(attrFS)
+	if !ok {
+		return nil
+	}
+	return retryNetwork(func() error { return afs.Lchown(name, uid, gid) })
+}
+
+func (f retryFS) Chtimes(name string, atime, mtime time.Time) error {
+	afs, ok := f.FileSystem.(attrFS)
+	if !ok {
+		return nil
+	}
+	return retryNetwork(func() error { return afs.Chtimes(name, atime, mtime) })
+}
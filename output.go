@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// outputSink 串行化并发工人的输出。
+// 各工人把完整的文本块发送到通道，由唯一的写出 goroutine 依次写出，
+// 保证每一行（以及同一文件的多行输出）都不会与其他工人的输出交错。
+type outputSink struct {
+	w    io.Writer
+	ch   chan sinkItem
+	done chan struct{}
+}
+
+// sinkItem 是待写出的文本块；flushed 非空时表示刷新请求
+type sinkItem struct {
+	text    string
+	flushed chan struct{}
+}
+
+// newOutputSink 创建输出通道并启动写出 goroutine
+func newOutputSink(w io.Writer) *outputSink {
+	o := &outputSink{
+		w:    w,
+		ch:   make(chan sinkItem, 256),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(o.done)
+		for item := range o.ch {
+			if item.flushed != nil {
+				close(item.flushed)
+				continue
+			}
+			io.WriteString(o.w, item.text)
+		}
+	}()
+
+	return o
+}
+
+// Print 输出一个完整的文本块
+func (o *outputSink) Print(s string) {
+	o.ch <- sinkItem{text: s}
+}
+
+// Printf 格式化后输出一个完整的文本块
+func (o *outputSink) Printf(format string, args ...any) {
+	o.ch <- sinkItem{text: fmt.Sprintf(format, args...)}
+}
+
+// Flush 等待此前提交的输出全部写完
+func (o *outputSink) Flush() {
+	flushed := make(chan struct{})
+	o.ch <- sinkItem{flushed: flushed}
+	<-flushed
+}
+
+// Close 写完所有已提交的输出并停止写出 goroutine
+func (o *outputSink) Close() {
+	close(o.ch)
+	<-o.done
+}
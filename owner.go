@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// ownerSet 是 --owner 允许的文件所有者 UID，nil 为不限制
+type ownerSet map[uint32]bool
+
+// parseOwners 解析逗号分隔的用户名或 UID 列表，用户名通过 os/user 解析
+func parseOwners(spec string) (ownerSet, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if err := ownersSupported(); err != nil {
+		return nil, err
+	}
+
+	set := make(ownerSet)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if uid, err := strconv.ParseUint(name, 10, 32); err == nil {
+			set[uint32(uid)] = true
+			continue
+		}
+		uid, err := lookupUID(name)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 --owner: %w", err)
+		}
+		set[uid] = true
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("--owner 没有指定任何用户")
+	}
+	return set, nil
+}
+
+// allows 判断文件的所有者是否在列表中
+func (s ownerSet) allows(path string, d fs.DirEntry) (bool, error) {
+	uid, err := fileOwner(path, d)
+	if err != nil {
+		return false, err
+	}
+	return s[uid], nil
+}
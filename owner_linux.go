@@ -0,0 +1,42 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// ownersSupported 报告 --owner 是否可用
+func ownersSupported() error {
+	return nil
+}
+
+// lookupUID 把用户名解析为 UID
+func lookupUID(name string) (uint32, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("用户 %s 的 UID %q 无效", name, u.Uid)
+	}
+	return uint32(uid), nil
+}
+
+// fileOwner 返回文件所有者的 UID；WalkDir 已经 lstat 过，直接复用
+func fileOwner(path string, d fs.DirEntry) (uint32, error) {
+	info, err := d.Info()
+	if err != nil {
+		return 0, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("无法获取 %s 的所有者", path)
+	}
+	return st.Uid, nil
+}
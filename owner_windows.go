@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// ownersSupported 报告 --owner 是否可用：Windows 文件的所有者是 SID，不是 UID
+func ownersSupported() error {
+	return errors.New("--owner 在 Windows 上不受支持")
+}
+
+func lookupUID(name string) (uint32, error) {
+	return 0, ownersSupported()
+}
+
+func fileOwner(path string, d fs.DirEntry) (uint32, error) {
+	return 0, ownersSupported()
+}
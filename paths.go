@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandPathArgs 把位置参数转换为去重后的绝对路径列表。
+// 字面上存在的路径按原样使用；不存在且含有通配符的参数用 filepath.Glob 展开。
+// Windows 的 cmd.exe 不会展开 *.txt 这类参数，这里代为展开；
+// Unix 上 shell 已经展开过，只有被引号保护、未被展开的模式才会走到这里。
+func expandPathArgs(args []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+
+	add := func(path string) error {
+		abs, err := absPath(path)
+		if err != nil {
+			return fmt.Errorf("无法获取路径 %s 的绝对路径: %w", path, err)
+		}
+		key := abs
+		if isCaseInsensitiveFS() {
+			key = strings.ToLower(abs)
+		}
+		if !seen[key] {
+			seen[key] = true
+			paths = append(paths, abs)
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		if _, err := os.Lstat(arg); err == nil || !hasGlobMeta(arg) {
+			// 不存在的字面路径留给遍历阶段报告错误
+			if err := add(arg); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("无效的通配符模式 %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("没有与 %s 匹配的文件", arg)
+		}
+		for _, match := range matches {
+			if err := add(match); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// absPath 返回规范化的绝对路径；Windows 上先把 \\?\ 形式的长路径转换为普通写法
+func absPath(path string) (string, error) {
+	return filepath.Abs(normalizePath(path))
+}
+
+// hasGlobMeta 判断参数是否含有通配符
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
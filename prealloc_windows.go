@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileAllocationInfo 对应 FILE_ALLOCATION_INFO
+type fileAllocationInfo struct {
+	AllocationSize int64
+}
+
+// preallocate 设置文件的分配大小，为 size 字节预留空间；文件大小不变，
+// 关闭时 NTFS 会释放超出文件末尾的部分
+func preallocate(f *os.File, size int64) error {
+	info := fileAllocationInfo{AllocationSize: size}
+	return windows.SetFileInformationByHandle(windows.Handle(f.Fd()), windows.FileAllocationInfo,
+		(*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+}
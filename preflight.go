@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// 预检模式
+const (
+	PreflightOff    = ""
+	PreflightWarn   = "warn"
+	PreflightStrict = "strict"
+)
+
+// collectReporter 在静默的预扫描中收集有匹配的文件
+type collectReporter struct {
+	silentReporter
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *collectReporter) FileMatched(ev FileEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, ev.Path)
+}
+
+// preflightWritable 在修改任何文件之前扫描一遍，检查每个将被修改的文件
+// 及其所在目录是否可写。不可写的文件登记到 config.unwritable，正式运行时跳过；
+// strict 模式下只要有不可写的文件就返回错误，调用方应中止运行。
+func preflightWritable(config *Config, rules []string) error {
+	fmt.Fprintln(os.Stderr, "权限预检...")
+
+	collector := &collectReporter{}
+	if _, err := prescan(config, rules, collector); err != nil {
+		return kindErrorf(ErrWalkFailed, "预检扫描目录时发生错误: %w", err)
+	}
+
+	sort.Strings(collector.paths)
+	config.unwritable = make(map[string]bool)
+	for _, path := range collector.paths {
+		if err := checkWritable(path); err != nil {
+			config.unwritable[path] = true
+			fmt.Fprintf(os.Stderr, "  不可写: %s (%v)\n", escapeControl(path), err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "预检结果: %d 个文件将被修改，其中 %d 个不可写\n\n", len(collector.paths), len(config.unwritable))
+	if config.Preflight == PreflightStrict && len(config.unwritable) > 0 {
+		return kindErrorf(ErrCanceled, "预检发现不可写的文件，未修改任何文件")
+	}
+	return nil
+}
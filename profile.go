@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileTiming 记录一个文件的处理耗时（--profile-files）
+type fileTiming struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	ElapsedUs int64  `json:"elapsedUs"`
+}
+
+// ProfileSummary 是处理耗时的分布统计
+type ProfileSummary struct {
+	Files   int          `json:"files"`
+	P50Us   int64        `json:"p50Us"`
+	P95Us   int64        `json:"p95Us"`
+	MaxUs   int64        `json:"maxUs"`
+	Timings []fileTiming `json:"timings"` // 按耗时从长到短，最多 top 个
+}
+
+// fileProfile 收集每个文件的处理耗时，未启用时不记录任何内容
+type fileProfile struct {
+	top     int // 汇总中保留的最慢文件数，0 为未启用
+	mu      sync.Mutex
+	timings []fileTiming
+}
+
+// add 记录一个文件的耗时
+func (p *fileProfile) add(path string, size int64, elapsed time.Duration) {
+	if p.top == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timings = append(p.timings, fileTiming{Path: path, Size: size, ElapsedUs: elapsed.Microseconds()})
+}
+
+// summary 按耗时从长到短排序并计算分位数；未启用或没有文件时返回 nil
+func (p *fileProfile) summary() *ProfileSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.top == 0 || len(p.timings) == 0 {
+		return nil
+	}
+
+	sort.Slice(p.timings, func(i, j int) bool { return p.timings[i].ElapsedUs > p.timings[j].ElapsedUs })
+	n := len(p.timings)
+	at := func(q float64) int64 {
+		// Nearest rank on the descending list
+		return p.timings[n-1-int(q*float64(n-1))].ElapsedUs
+	}
+
+	s := &ProfileSummary{Files: n, P50Us: at(0.50), P95Us: at(0.95), MaxUs: p.timings[0].ElapsedUs}
+	s.Timings = p.timings
+	if len(s.Timings) > p.top {
+		s.Timings = s.Timings[:p.top]
+	}
+	return s
+}
+
+// formatProfile 输出最慢的文件和耗时分布
+func formatProfile(s *ProfileSummary) string {
+	var sb strings.Builder
+	us := func(v int64) time.Duration { return time.Duration(v) * time.Microsecond }
+	fmt.Fprintf(&sb, "\n处理耗时（%d 个文件）: p50 %v, p95 %v, 最长 %v\n", s.Files, us(s.P50Us), us(s.P95Us), us(s.MaxUs))
+	fmt.Fprintf(&sb, "最慢的 %d 个文件:\n", len(s.Timings))
+	for _, t := range s.Timings {
+		fmt.Fprintf(&sb, "  %10v %10s  %s\n", us(t.ElapsedUs), formatBytes(t.Size), escapeControl(t.Path))
+	}
+	return sb.String()
+}
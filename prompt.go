@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// canPrompt 判断能否在终端中询问缺少的参数：标准输入和标准输出都必须是终端
+func canPrompt() bool {
+	return !cfg.NoPrompt && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// promptMissing 在交互式终端中询问为空的源字符串和目标字符串（target 为 nil 时
+// 是 find/verify，只询问要查找的字符串），再回显解析后的值，让结尾空格等不可见字符显露出来。
+// 不能询问或输入为空时保持原值，由调用者照常报错。明确给出的 --to "" 不再询问。
+func promptMissing(source, target *string) {
+	if target != nil && cfg.targetSet {
+		target = nil
+	}
+	if !canPrompt() || (*source != "" && (target == nil || *target != "")) {
+		return
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	if *source == "" {
+		prompt := "要替换的源字符串: "
+		if target == nil {
+			prompt = "要查找的字符串: "
+		}
+		*source = promptLine(in, prompt)
+	}
+	if target != nil && *target == "" && *source != "" {
+		*target = promptLine(in, "替换成的目标字符串: ")
+	}
+
+	if *source == "" || (target != nil && *target == "") {
+		return
+	}
+	fmt.Printf("源字符串: %s\n", strconv.Quote(*source))
+	if target != nil {
+		fmt.Printf("目标字符串: %s\n", strconv.Quote(*target))
+	}
+	fmt.Println()
+}
+
+// promptLine 输出提示并读取一行，只去掉行尾的换行符，保留输入中的空白
+func promptLine(in *bufio.Reader, prompt string) string {
+	fmt.Print(prompt)
+	line, err := in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		fmt.Println()
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// workItem 是待处理的候选文件
+type workItem struct {
+	path   string
+	size   int64
+	seq    int           // 加入队列的顺序
+	detect time.Duration // 遍历时二进制检测的耗时
+	atime  time.Time     // --keep-times 时二进制检测之前的访问时间
+}
+
+// queueCapacity 是等待处理的文件数上限。遍历比处理快得多，
+// 队列满时遍历暂停，内存占用不随目录树的大小增长
+const queueCapacity = 1000
+
+// workQueue 按文件大小从大到小分发候选文件。
+// 大文件先开始处理，避免运行快结束时才取到的大文件让单个工人拖长整个运行。
+// 遍历和处理同时进行，因此顺序只在已发现、尚在队列中的文件之间成立。
+// 顺序模式（--seq）下按遍历顺序分发，使磁盘读取保持局部性。
+type workQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // 有文件可取或队列已关闭
+	notFull *sync.Cond // 队列有空位
+	items   itemHeap
+	next    int
+	closed  bool
+}
+
+// newWorkQueue 创建空队列，fifo 为 true 时按加入顺序分发
+func newWorkQueue(fifo bool) *workQueue {
+	q := &workQueue{items: itemHeap{fifo: fifo}}
+	q.cond = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// push 加入一个候选文件；队列已满时等待工人取走文件
+func (q *workQueue) push(item workItem) {
+	q.mu.Lock()
+	for q.items.Len() >= queueCapacity {
+		q.notFull.Wait()
+	}
+	item.seq = q.next
+	q.next++
+	heap.Push(&q.items, item)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop 取出当前最大的文件；队列为空时等待，关闭且取完后返回 false
+func (q *workQueue) pop() (workItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.items.Len() == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.items.Len() == 0 {
+		return workItem{}, false
+	}
+	q.notFull.Signal()
+	return heap.Pop(&q.items).(workItem), true
+}
+
+// close 表示不会再有新的文件加入
+func (q *workQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// itemHeap 默认是以文件大小为键的最大堆，fifo 时按加入顺序排列
+type itemHeap struct {
+	items []workItem
+	fifo  bool
+}
+
+func (h itemHeap) Len() int      { return len(h.items) }
+func (h itemHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *itemHeap) Push(x any)   { h.items = append(h.items, x.(workItem)) }
+
+func (h itemHeap) Less(i, j int) bool {
+	if h.fifo {
+		return h.items[i].seq < h.items[j].seq
+	}
+	return h.items[i].size > h.items[j].size
+}
+
+func (h *itemHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// len 返回等待处理的文件数
+func (q *workQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
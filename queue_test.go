@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 队列满时 push 等待，直到有文件被取走
+func TestWorkQueueBounded(t *testing.T) {
+	q := newWorkQueue(true)
+	for i := range queueCapacity {
+		q.push(workItem{path: fmt.Sprint(i)})
+	}
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push(workItem{path: "extra"})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("队列已满时 push 没有等待")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if item, ok := q.pop(); !ok || item.path != "0" {
+		t.Fatalf("取出 %q, %v，应为 \"0\"", item.path, ok)
+	}
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("取走文件后 push 仍在等待")
+	}
+	if n := q.len(); n != queueCapacity {
+		t.Errorf("队列长度 %d，应为 %d", n, queueCapacity)
+	}
+}
+
+// mixedTree 创建 small 个 256KB 的文件和一个排在遍历最后的 32MB 文件
+func mixedTree(b *testing.B, small int) string {
+	b.Helper()
+	root := b.TempDir()
+	line := strings.Repeat("x", 63) + "\n"
+	chunk := strings.Repeat(line, 256<<10/len(line))
+	for i := range small {
+		name := filepath.Join(root, fmt.Sprintf("a%04d.txt", i))
+		if err := os.WriteFile(name, []byte(chunk), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	big := strings.Repeat(chunk, 128)
+	if err := os.WriteFile(filepath.Join(root, "zz-big.txt"), []byte(big), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return root
+}
+
+// 大小混合的目录树上按遍历顺序分发与大文件优先的对比。
+// 最大的文件最后被遍历到，按遍历顺序分发时它在最后才开始，
+// 整个运行的耗时（尾延迟）由它决定。
+func BenchmarkQueueTailLatency(b *testing.B) {
+	root := mixedTree(b, 200)
+	for _, mode := range []struct {
+		name string
+		fifo bool
+	}{{"fifo", true}, {"largest-first", false}} {
+		b.Run(mode.name, func(b *testing.B) {
+			for b.Loop() {
+				config := &Config{
+					SourceDir:    root,
+					SourceString: "needle",
+					TargetString: "pin",
+					Trial:        true,
+					Workers:      4,
+					Sequential:   mode.fifo, // 只取队列顺序，不把工人数降到 1
+					NoLock:       true,
+					Reporter:     silentReporter{},
+				}
+				if _, err := Run(config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
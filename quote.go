@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// quotePath 以 C 风格转义路径，规则与 git 的 core.quotePath=false 相同：
+// 路径中含有控制字符、双引号、反斜杠、开头或结尾的空格、或无效 UTF-8 时，
+// 整个路径用双引号括起并转义；否则原样返回。下游解析器总能还原出准确的路径。
+func quotePath(path string) string {
+	if !needsQuoting(path) {
+		return path
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(path); {
+		r, size := utf8.DecodeRuneInString(path[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&sb, "\\%03o", path[i])
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			sb.WriteString(escapeByte(byte(r)))
+		default:
+			sb.WriteString(path[i : i+size])
+		}
+		i += size
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// needsQuoting 判断路径是否需要加引号
+func needsQuoting(path string) bool {
+	if path == "" || path[0] == ' ' || path[len(path)-1] == ' ' || !utf8.ValidString(path) {
+		return true
+	}
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c == 0x7f || c == '"' || c == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeByte 返回控制字符的 C 风格转义
+func escapeByte(c byte) string {
+	switch c {
+	case '\a':
+		return `\a`
+	case '\b':
+		return `\b`
+	case '\t':
+		return `\t`
+	case '\n':
+		return `\n`
+	case '\v':
+		return `\v`
+	case '\f':
+		return `\f`
+	case '\r':
+		return `\r`
+	}
+	return fmt.Sprintf("\\%03o", c)
+}
+
+// escapeControl 转义面向人阅读的输出中的控制字符（制表符除外），
+// 防止恶意文件名或文件内容伪造出额外的输出行或终端控制序列
+func escapeControl(s string) string {
+	clean := true
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < 0x20 && c != '\t') || c == 0x7f {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < 0x20 && c != '\t') || c == 0x7f {
+			sb.WriteString(escapeByte(c))
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package main
+
+// raceEnabled 表示测试在竞态检测器下运行，此时分配次数不可靠
+const raceEnabled = true
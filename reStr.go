@@ -2,26 +2,232 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
-	"runtime"
+	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/encoding"
 )
 
 type Config struct {
 	SourceDir     string
+	Paths         []string
 	SourceString  string
 	TargetString  string
 	Workers       int
 	Trial         bool
 	Verbose       bool
+	CleanStale    bool
+	StaleAge      time.Duration
+	LineMode      bool
+	Trim          bool
+	Anchor        string
+	AllowIndent   bool
+	Nth           int
+	Occurrences   string
+	MaxTotal      int
+	MaxFiles      int
+	ConfirmOver   int
+	Yes           bool
+	Swap          bool
+	Force         bool
+	IncludeVCS    bool
+	SkipSystem    bool
+	SkipMinified  bool
+	Since         string
+	Before        string
+	NewerThan     string
+	OlderThan     string
+	Owner         string
+	MinifiedPrefix int
+	MinifiedLineLength int
+	NoRecursive   bool
+	OneFileSystem bool
+	SkipNetworkDirs bool
+	IncludeVirtualFS bool
+	Include       []string
+	Exclude       []string
+	Files         string
+	Files0        string
+	MaxSize       string
+	FollowSymlinks bool
+	SlowThreshold time.Duration
+	StatsInterval time.Duration
+	GroupDepth    int
+	Relative      bool
+	Sequential    bool
+	TempDir       string
+	VerifyWrite   bool
+	Clone         bool
+	Preflight     string
+	Detab         int
+	Retab         int
+	EOL           string
+	TrimTrailing  bool
+	EOLReport     bool
+	Journal       bool
+	Context       int
+	KeepMDBreaks  bool
+	Format        string
+	EmitScript    string
+	CheckReversible bool
+	Backup        string
+	Diff          bool
+	KeepTimes     bool
+	Color         string
+	ReportMD      string
+	ReportHTML    string
+	ReportJUnit   string
+	Sample        float64
+	PreviewLimit  int
+	ProfileFiles  int
+	MaxMatchesShown int
+	TUI           bool
+	FailFast      bool
+	OnComplete    string
+	OnCompleteStrict bool
+	NoLock        bool
+	NoPrompt      bool
+	IgnoreWhitespace bool
+	Regex         bool
+	IgnoreCase    bool
+	Word          bool
+	NormalizeEOLInPattern bool
+	TemplateReplace bool
+	NoHistory     bool
+	GitCommit     bool
+	GitCommitMessage string
+	GitCommitForce bool
+	AllowDirty    bool
+	LockPath      string
+	Seed          int64
+	Map           string
+	Encoding      string
+
+	// matcher is built from the source/target strings in Run and shared
+	// by counting, preview and replacement
+	matcher       Matcher
+	
+	// multiline replaces matcher when the source string spans lines
+	multiline     *multilineMatcher
+	
+	// filter applies --include/--exclude
+	filter        *pathFilter
+	
+	// pattern is the compiled --regex, --ignore-whitespace or --ignore-case pattern
+	pattern       *regexp.Regexp
+	
+	// template is the compiled --to with --template-replace
+	template      *template.Template
+
+	// mdMatcher replaces matcher for Markdown files when trailing
+	// whitespace trimming must keep hard line breaks
+	mdMatcher     Matcher
+
+	// FS performs all file access of the run; defaults to the os package
+	FS            FileSystem `json:"-"`
+
+	// Reporter receives every event of the run; defaults to console output
+	Reporter      Reporter `json:"-"`
+
+	// previewAll collects every matching line instead of the first few;
+	// set by the find subcommand
+	previewAll    bool
+	
+	// display rewrites the paths in the output relative to SourceDir
+	// (--relative); nil keeps them absolute
+	display       *pathDisplay
+	relativeSet   bool // --relative was given explicitly
+	targetSet     bool // --to was given, possibly empty to delete matches
+	
+	// swapFrom is the first string of --swap A B; the second one is the
+	// first positional argument
+	swapFrom      string
+	swapSet       bool
+	
+	// mapRules are the --map replacements, applied in file order
+	mapRules      []mapRule
+	
+	// encoding is the --encoding of the file content; nil for UTF-8
+	encoding      encoding.Encoding
+	
+	// window is the modification time range from --since, --before,
+	// --newer-than and --older-than
+	window        timeWindow
+	
+	// owners are the UIDs allowed by --owner, nil for any
+	owners        ownerSet
+	
+	// occurrences is --occurrences as a count per line, 0 for all
+	occurrences   int
+	
+	// interrupted is set on Ctrl+C or SIGTERM; the walk and the workers
+	// stop taking new files and Run returns ErrInterrupted
+	interrupted   atomic.Bool
+	
+	// hookOnce makes sure --on-complete runs once per run, even when an
+	// interrupt arrives as the run finishes; Run resets it
+	hookOnce      sync.Once
+	
+	// maxSize is --max-size in bytes, 0 for no limit
+	maxSize       int64
+	
+	// links tracks visited directories and files with --follow-symlinks;
+	// nil when symlinks are skipped
+	links         *linkTracker
+	
+	// searchOnly marks find and verify, which replace the string with
+	// itself: every match counts, none is skipped as unchanged
+	searchOnly    bool
+
+	// reportPreview is how many lines per file report files want; they are
+	// collected even when the console shows none
+	reportPreview int
+
+	// unwritable lists the files the permission preflight found
+	// unwritable; they are skipped instead of failing mid-run
+	unwritable    map[string]bool
+
+	// selected lists the files shown in the --tui review, true for the
+	// ones the user kept; when set, no other file is modified
+	selected      map[string]bool
+
+	// color tells the console reporters to use ANSI colors; resolved
+	// from Color and the terminal in prepareRun
+	color         bool
+
+	// journal keeps the original content of rewritten files for undo;
+	// nil unless --journal is set
+	journal       *journal
+
+	// lock keeps other runs from modifying the same directory; nil for
+	// read-only runs and with --no-lock
+	lock          *runLock
+
+	// filesystems describes the filesystem of every walk root, detected
+	// at the start of Run
+	filesystems   []FilesystemInfo
+	
+	// sampler draws the --sample decisions during the walk
+	sampler       *rand.Rand
+
+	// artifacts holds the output files of this run that must never be
+	// treated as input, even when they live inside SourceDir
+	artifacts     artifactSet
 }
 
 type Result struct {
@@ -30,342 +236,1742 @@ type Result struct {
 	FilesMatches   int32
 	Matches        int32
 	Errors         int32
+	StaleTemps     int32
+	StaleRemoved   int32
+	Backups        int32
+	CapReached     int32
+	MaxFilesReached int32
+	Aborted        int32 // set by the first error under --fail-fast
+	RuleMatches    []int32
+	Skipped        [skipReasonCount]int32
+	EOLStyles      [eolStyleCount]int32 // files per line-ending style (--eol-report)
+	NoFinalNewline int32
+	SizeDelta      int64
+	IO             IOStats
+	Elapsed        time.Duration
+
+	// failFast is the error that stopped a --fail-fast run
+	failFast       failFast
+
+	// reserved counts replacements handed out against --max-total;
+	// unsettled counts the files still holding part of it
+	reserved       int32
+	unsettled      int32
+
+	// tempInFlight is the size of the temp files being written right now
+	tempInFlight   int64
+	
+	// slow collects the files that exceeded --slow-threshold
+	slow           slowFiles
+	
+	// groups aggregates the counters per subdirectory (--group-depth)
+	groups         *dirGroups
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "reStr",
+	Use:   "reStr [路径...]",
 	Short: "批量字符串替换工具",
 	Long: `批量字符串替换工具，支持递归处理目录，
-排除隐藏目录及子目录的文件`,
-	Run: func(cmd *cobra.Command, args []string) {
-		runApp()
+排除隐藏目录及子目录的文件
+
+不带子命令时等同于 reStr replace`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runApp(args)
+	},
+	// Once the flags have parsed, errors are printed by main without usage
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceErrors, cmd.SilenceUsage = true, true
+		cfg.relativeSet = cmd.Flags().Changed("relative")
+		cfg.targetSet = cmd.Flags().Changed("to")
+		cfg.swapSet = cmd.Flags().Changed("swap")
 	},
 }
 
 var cfg Config
 
 func init() {
+	// 遍历、过滤和输出相关的选项，所有子命令共用
 	rootCmd.PersistentFlags().StringVarP( &cfg.SourceDir,     "dir",     "d", ".",   "源目录路径")
-	rootCmd.PersistentFlags().StringVarP( &cfg.SourceString,  "from",    "f", "",    "要替换的源字符串")
-	rootCmd.PersistentFlags().StringVarP( &cfg.TargetString,  "to",      "t", "",    "替换成的目标字符串")
-	rootCmd.PersistentFlags().BoolVarP(   &cfg.Trial,         "test",    "T", false, "试验模式（不实际修改）")
 	rootCmd.PersistentFlags().BoolVarP(   &cfg.Verbose,       "verbose", "v", false, "详细输出")
 	rootCmd.PersistentFlags().IntVarP(    &cfg.Workers,       "workers", "w", 4,     "工人数")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.CleanStale,    "clean-stale",   false,     "删除之前运行遗留的临时文件")
+	rootCmd.PersistentFlags().DurationVar(&cfg.StaleAge,      "stale-age",     time.Hour, "临时文件超过该时长视为残留")
+	rootCmd.PersistentFlags().IntVar(     &cfg.MaxFiles,      "max-files",     0,         "最多处理的候选文件数（0 为不限制）")
+	rootCmd.PersistentFlags().IntVar(     &cfg.ProfileFiles,  "profile-files", 0,         "记录每个文件的处理耗时，结束时列出最慢的 N 个文件和耗时分布")
+	rootCmd.PersistentFlags().Lookup("profile-files").NoOptDefVal = "10"
+	rootCmd.PersistentFlags().DurationVar(&cfg.SlowThreshold, "slow-threshold", 0,         "处理耗时超过该时长的文件立即报告，并在汇总中列出（0 为不检查）")
+	rootCmd.PersistentFlags().DurationVar(&cfg.StatsInterval, "stats-interval", 0,         "每隔该时长把运行状态输出到标准错误（0 为不输出；Unix 上也可发送 SIGUSR1）")
+	rootCmd.PersistentFlags().IntVar(     &cfg.GroupDepth,    "group-depth",   1,         "汇总按子目录分组的层数，JSON 和报告中逐级展开，控制台只显示第一级（0 为不分组）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.Relative,      "relative",      false,     "输出中的路径相对于源目录显示（源目录以相对路径给出时默认启用）")
+	rootCmd.PersistentFlags().IntVar(     &cfg.MaxMatchesShown, "max-matches-shown", 20,   "试验或详细模式下每个文件最多输出的匹配行数，其余只汇总为一行（0 为不限制）")
+	rootCmd.PersistentFlags().Float64Var( &cfg.Sample,        "sample",        0,         "只随机处理百分之 P 的候选文件，并在汇总中外推估计总数")
+	rootCmd.PersistentFlags().Int64Var(   &cfg.Seed,          "seed",          0,         "--sample 的随机种子（0 为随机，实际种子显示在开头）")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Format,        "format",        FormatConsole, "输出格式: console|json|porcelain|silent")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Color,         "color",         ColorAuto, "彩色输出: auto|always|never（设置 NO_COLOR 时总是关闭）")
+	rootCmd.PersistentFlags().BoolVarP(   &cfg.NoRecursive,   "no-recursive", "n", false, "只处理源目录下的文件，不进入子目录")
+	rootCmd.PersistentFlags().BoolVarP(   &cfg.OneFileSystem, "one-file-system", "x", false, "不进入挂载在其他文件系统上的目录（同 du -x）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.SkipNetworkDirs, "skip-network-dirs", false, "不进入位于网络文件系统（NFS、SMB、FUSE 等）上的子目录")
+	rootCmd.PersistentFlags().StringArrayVar(&cfg.Include,    "include",       nil,       "只处理匹配该模式的文件（可重复；不含 / 的模式匹配文件名，** 匹配任意多级目录）")
+	rootCmd.PersistentFlags().StringArrayVar(&cfg.Exclude,    "exclude",       nil,       "不处理匹配该模式的文件和目录（可重复，优先于 --include）")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Files,         "files",         "",        "从该文件（- 为标准输入）逐行读取要处理的文件，代替遍历目录")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Files0,        "files0",        "",        "同 --files，但路径以 NUL 分隔（配合 find -print0）")
+	rootCmd.PersistentFlags().BoolVarP(   &cfg.FollowSymlinks, "follow-symlinks", "L", false, "进入指向目录的符号链接，替换时修改链接指向的文件（链接本身保留）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.IncludeVirtualFS, "include-virtual-fs", false, "进入 /proc、/sys、/dev、/run 等虚拟文件系统（默认跳过，仅限 Linux）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.SkipSystem,    "skip-system",   true,      "跳过带系统属性的文件和目录（Windows）")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Since,         "since",         "",        "只处理修改时间不早于该时间的文件（如 2024-01-31 或 RFC 3339 时间）")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Before,        "before",        "",        "只处理修改时间早于该时间的文件")
+	rootCmd.PersistentFlags().StringVar(  &cfg.NewerThan,     "newer-than",    "",        "只处理最近该时长内修改过的文件（如 36h、30d、2w）")
+	rootCmd.PersistentFlags().StringVar(  &cfg.OlderThan,     "older-than",    "",        "只处理超过该时长未修改的文件")
+	rootCmd.PersistentFlags().StringVar(  &cfg.Owner,         "owner",         "",        "只处理属于这些用户的文件（逗号分隔的用户名或 UID，仅限 Unix）")
+	rootCmd.PersistentFlags().StringVar(  &cfg.MaxSize,       "max-size",      "0",       "跳过大于该大小的文件（如 512K、10M、1G；0 为不限制）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.SkipMinified,  "skip-minified", false,     "跳过压缩过的 JS/CSS、source map 等只有很长的行的文件")
+	rootCmd.PersistentFlags().IntVar(     &cfg.MinifiedPrefix, "minified-prefix", 16,     "--skip-minified 检查文件开头的 KB 数，其中没有换行符即视为压缩代码")
+	rootCmd.PersistentFlags().IntVar(     &cfg.MinifiedLineLength, "minified-line-length", 500, "--skip-minified 中平均行长超过该字节数即视为压缩代码")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.IncludeVCS,    "include-vcs",   false,     "处理版本控制目录（.git/.hg/.svn/.bzr）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.Sequential,    "seq",           false,     "顺序模式：单个工人按遍历顺序处理（适合机械硬盘）")
+	rootCmd.PersistentFlags().BoolVar(    &cfg.Force,         "force",         false,     "跳过安全检查（如在根目录或主目录上运行）")
+
+	// 根命令保留替换的全部选项，不带子命令的旧用法保持不变
+	addMatchFlags(rootCmd.Flags())
+	addReplaceFlags(rootCmd.Flags())
+}
+
+// addMatchFlags 注册查找匹配相关的选项
+func addMatchFlags(flags *pflag.FlagSet) {
+	flags.StringVarP( &cfg.SourceString,  "from",    "f", "",    "要替换的源字符串")
+	flags.BoolVar(    &cfg.NoPrompt,      "no-prompt",     false,     "缺少 --from/--to 时直接报错，即使在终端中也不询问")
+	flags.BoolVarP(   &cfg.Regex,         "regex",   "E", false, "把源字符串作为正则表达式（RE2 语法），目标字符串中可用 $1、${name} 引用分组，\\U、\\L、\\u、\\l、\\E 转换大小写")
+	flags.BoolVarP(   &cfg.Word,          "word",    "W", false, "只匹配整词：前后不是字母、数字或下划线（同 grep -w）")
+	flags.BoolVarP(   &cfg.IgnoreCase,    "ignore-case", "i", false, "匹配时忽略大小写，替换时仍写入原样的目标字符串")
+	flags.BoolVar(    &cfg.IgnoreWhitespace, "ignore-whitespace", false, "源字符串中的一段空白匹配任意长度的空格和制表符，与标点相邻时也可以没有")
+	flags.BoolVar(    &cfg.NormalizeEOLInPattern, "normalize-eol-in-pattern", true, "源字符串含有换行符时，其中的 \\r\\n、\\n 和 \\r 匹配文件中任意一种行结束符（=false 时按字节精确匹配）")
+	flags.BoolVar(    &cfg.LineMode,      "line-mode",     false,     "整行匹配模式（整行等于源字符串时替换整行）")
+	flags.BoolVar(    &cfg.Trim,          "trim",          false,     "整行匹配时忽略行首尾空白")
+	flags.StringVar(  &cfg.Anchor,        "anchor",        "",        "锚定匹配: start|end|both")
+	flags.BoolVar(    &cfg.AllowIndent,   "allow-indent",  false,     "行首锚定时允许前导空白")
+	flags.IntVar(     &cfg.Nth,           "nth",           0,         "只替换每行的第 N 处匹配（从1开始）")
+	flags.StringVar(  &cfg.Occurrences,   "occurrences",   "",        "每行替换的匹配数: all|first|N，从行首数起（默认 all）")
+	flags.StringVar(  &cfg.Encoding,      "encoding",      "",        "文件内容的编码: gbk|gb18030|big5|shift-jis|latin-1（默认 UTF-8）")
 }
 
-func runApp() {
-	// 参数验证
-	if cfg.SourceString == "" {
-		log.Fatal("必须指定要替换的源字符串（--from 参数）")
+// addReplaceFlags 注册只对替换有意义的选项
+func addReplaceFlags(flags *pflag.FlagSet) {
+	flags.StringVarP( &cfg.TargetString,  "to",      "t", "",    "替换成的目标字符串")
+	flags.BoolVarP(   &cfg.Trial,         "test",    "T", false, "试验模式（不实际修改）")
+	flags.IntVar(     &cfg.MaxTotal,      "max-total",     0,         "整个运行最多替换的总数（0 为不限制）")
+	flags.IntVar(     &cfg.ConfirmOver,   "confirm-over",  0,         "将修改的文件数超过 N 时先确认（0 为不确认）")
+	flags.BoolVarP(   &cfg.Yes,           "yes",     "y", false,     "自动确认所有提示")
+	flags.StringVar(  &cfg.swapFrom,      "swap",          "",        "--swap A B: 一次扫描中把 A 替换为 B、B 替换为 A（重叠时优先较长者）")
+	flags.BoolVar(    &cfg.TemplateReplace, "template-replace", false,   "把 --to 作为 Go text/template 模板对每处 --regex 匹配求值（.G1、.Groups.name，函数 upper、lower、title、trimSpace、printf）")
+	flags.StringVar(  &cfg.Map,           "map",           "",        "从文件读取多条替换规则（每行 源<TAB>目标[<TAB>regex,ignoreCase,wholeWord]），按顺序在一次扫描中应用；改写前面规则结果的匹配计入后一条规则")
+	flags.IntVar(     &cfg.Detab,         "detab",         0,         "把行首缩进中的制表符展开为空格（制表位宽度 N）")
+	flags.IntVar(     &cfg.Retab,         "retab",         0,         "把行首缩进中的空格折叠为制表符（制表位宽度 N）")
+	flags.StringVar(  &cfg.EOL,           "eol",           "",        "把换行符统一转换为: lf|crlf")
+	flags.BoolVar(    &cfg.TrimTrailing,  "trim-trailing", false,     "清理行尾的空格和制表符（在替换之后进行）")
+	flags.BoolVar(    &cfg.KeepMDBreaks,  "keep-md-breaks", true,     "清理行尾空白时保留 Markdown 文件中两个空格的换行")
+	flags.BoolVar(    &cfg.EOLReport,     "eol-report",    false,     "只统计每个文件的换行符风格，不修改文件")
+	flags.StringVar(  &cfg.TempDir,       "temp-dir",      "",        "临时文件目录（默认与目标文件相同目录）")
+	flags.BoolVar(    &cfg.KeepTimes,     "keep-times",    false,     "替换后保留文件原来的访问和修改时间")
+	flags.BoolVar(    &cfg.VerifyWrite,   "verify-write",  false,     "重命名前重新读取临时文件，确认大小和内容与写入的一致")
+	flags.BoolVar(    &cfg.Clone,         "clone",         false,     "文件系统支持时以写时复制方式克隆原文件，只重写有替换的部分（Btrfs、XFS）")
+	flags.StringVar(  &cfg.Preflight,     "preflight",     "",        "修改前检查目标文件是否可写: warn|strict（strict 时有不可写文件则中止）")
+	flags.Lookup("preflight").NoOptDefVal = PreflightWarn
+	flags.BoolVar(    &cfg.GitCommit,     "git-commit",    false,     "替换后暂存并提交修改的文件，提交消息自动生成")
+	flags.StringVar(  &cfg.GitCommitMessage, "git-commit-message", "", "--git-commit 使用的提交消息（隐含 --git-commit）")
+	flags.BoolVar(    &cfg.GitCommitForce, "git-commit-force", false, "即使有错误也用 --git-commit 创建提交")
+	flags.BoolVar(    &cfg.AllowDirty,    "allow-dirty",   false,     "允许修改有未提交改动的 git 工作树")
+	flags.BoolVar(    &cfg.NoHistory,     "no-history",    false,     "不在 .reStr/history 下记录本次运行")
+	flags.BoolVar(    &cfg.NoLock,        "no-lock",       false,     "不创建锁文件，允许与其他运行同时修改同一目录")
+	flags.StringVar(  &cfg.LockPath,      "lock-path",     "",        "锁文件路径（默认 <源目录>/.reStr/lock）")
+	flags.BoolVar(    &cfg.Journal,       "journal",       false,     "记录被修改文件的原始内容，供 reStr undo 恢复")
+	flags.BoolVar(    &cfg.FailFast,      "fail-fast",     false,     "出现第一个错误时停止遍历，不再处理队列中的文件，以退出码 6 结束")
+	flags.BoolVar(    &cfg.TUI,           "tui",           false,     "先扫描，在全屏界面中审阅每个文件的差异并选择要替换的文件")
+	flags.IntVar(     &cfg.PreviewLimit,  "preview-limit", 200,       "试验模式下只详细输出前 N 个文件，其余文件只计入汇总（0 为不限制）")
+	flags.StringVar(  &cfg.OnComplete,    "on-complete",   "",        "运行结束后执行的命令（结果通过 RESTR_* 环境变量和标准输入的 JSON 汇总传递）")
+	flags.BoolVar(    &cfg.OnCompleteStrict, "on-complete-strict", false, "完成钩子失败时以退出码 5 退出")
+	flags.StringVar(  &cfg.Backup,        "backup",        "",        "替换前把原文件复制为 <文件名>后缀，保留权限和修改时间（默认后缀 .bak）")
+	flags.Lookup("backup").NoOptDefVal = defaultBackupSuffix
+	flags.BoolVar(    &cfg.CheckReversible, "check-reversible", false,    "只检查：列出反向替换（目标→源）不能还原的位置，如已存在的目标字符串；有冲突时以 1 退出")
+	flags.BoolVar(    &cfg.Diff,          "diff",          false,     "以统一差异格式 (diff -u) 输出每个文件的改动，通常与 --test 一起使用")
+	flags.StringVar(  &cfg.EmitScript,    "emit-script",   "",        "试验模式下输出完成同样替换的脚本代替普通输出: sed|powershell")
+	flags.StringVar(  &cfg.ReportMD,      "report-md",     "",        "运行结束时把 Markdown 格式的报告写入指定文件")
+	flags.StringVar(  &cfg.ReportHTML,    "report-html",   "",        "把包含每个文件差异的独立 HTML 报告写入指定文件")
+}
+
+func runApp(args []string) error {
+	args, err := takeSwapArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := validateReplaceFlags(); err != nil {
+		return err
+	}
+	if err := validateMatchFlags(); err != nil {
+		return err
+	}
+	if cfg.CheckReversible {
+		return runCheckReversible(args)
+	}
+	if err := prepareRun(args); err != nil {
+		return err
+	}
+	
+	// Mass replacements must not mix with uncommitted local edits
+	if !cfg.Trial && !cfg.EOLReport && !cfg.Force && !cfg.AllowDirty {
+		roots := cfg.Paths
+		if len(roots) == 0 {
+			roots = []string{cfg.SourceDir}
+		}
+		if err := checkCleanTree(roots); err != nil {
+			return failRun(&cfg, nil, withKind(ErrRefused, err))
+		}
+	}
+	
+	// Find the repository before touching anything so a failure leaves
+	// the tree unmodified
+	var gitTop string
+	changed := &changeCollector{}
+	if cfg.GitCommit {
+		top, err := gitTopLevel(cfg.SourceDir)
+		if err != nil {
+			return failRun(&cfg, nil, kindErrorf(ErrGitFailed, "无法确定 git 仓库状态，未修改任何文件: %w", err))
+		}
+		gitTop = top
+		cfg.Reporter = teeReporter{changed, cfg.Reporter}
+	}
+	
+	start := time.Now()
+	result, err := Run(&cfg)
+	if err != nil {
+		return err
+	}
+	// Trial runs leave the tree untouched, .reStr/history included
+	if !cfg.NoHistory && !readOnlyRun(&cfg) {
+		writeHistory(&cfg, result, start, time.Now(), runStatus(result))
+	}
+	if cfg.GitCommit {
+		if err := commitChanges(&cfg, result, gitTop, changed); err != nil {
+			return err
+		}
+	}
+	if code := runHook(&cfg, result, runStatus(result)); code != 0 && cfg.OnCompleteStrict {
+		return ErrHookFailed
+	}
+	if s := summarizeFailFast(result); s != nil {
+		return kindErrorf(ErrFailFast, "--fail-fast: 处理 %s 时出错，已中止: %s", escapeControl(s.Path), s.Error)
+	}
+	if capReached(result) {
+		return ErrCapReached
+	}
+	if maxFilesReached(result) {
+		return ErrMaxFilesReached
+	}
+	return nil
+}
+
+// takeSwapArgs 处理 --swap A B：A 是选项的值，B 是第一个位置参数，
+// 其余位置参数仍是要处理的路径
+func takeSwapArgs(args []string) ([]string, error) {
+	if !cfg.swapSet {
+		return args, nil
+	}
+	if cfg.SourceString != "" || cfg.targetSet {
+		return nil, configError("--swap A B 已给出两个字符串，不能再指定 --from/--to")
+	}
+	if len(args) == 0 {
+		return nil, configError("--swap 需要两个字符串: --swap A B")
+	}
+	cfg.SourceString, cfg.TargetString, cfg.targetSet = cfg.swapFrom, args[0], true
+	cfg.Swap = true
+	return args[1:], nil
+}
+
+// validateReplaceFlags 验证替换相关的参数
+func validateReplaceFlags() error {
+	if cfg.Detab < 0 || cfg.Retab < 0 {
+		return configError("--detab 和 --retab 的制表位宽度必须大于0")
+	}
+	
+	switch cfg.EOL {
+	case EOLNone, EOLLF, EOLCRLF:
+	default:
+		return configError("无效的换行符风格: %s（可选 lf|crlf）", cfg.EOL)
+	}
+	
+	modes := 0
+	for _, on := range []bool{cfg.Detab > 0, cfg.Retab > 0, cfg.EOL != EOLNone, cfg.EOLReport} {
+		if on {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return configError("--detab、--retab、--eol 和 --eol-report 只能选择其一")
+	}
+	
+	if cfg.TrimTrailing && (cfg.EOL != EOLNone || cfg.EOLReport) {
+		return configError("--trim-trailing 不能与 --eol 或 --eol-report 一起使用")
+	}
+	
+	if cfg.Map != "" {
+		if whitespaceMode(&cfg) {
+			return configError("--map 不能与 --detab、--retab、--eol 或 --eol-report 一起使用")
+		}
+		if cfg.SourceString != "" || cfg.targetSet {
+			return configError("--map 已给出替换规则，不能再指定 --from/--to")
+		}
+		if cfg.Swap || cfg.IgnoreWhitespace || cfg.LineMode || cfg.Anchor != AnchorNone || cfg.Nth > 0 {
+			return configError("--map 不能与 --swap、--ignore-whitespace、--line-mode、--anchor 或 --nth 一起使用")
+		}
+		// --regex, --ignore-case and --word are the defaults of every rule
+		rules, err := loadMap(cfg.Map, mapOptions{Regex: cfg.Regex, IgnoreCase: cfg.IgnoreCase, Word: cfg.Word})
+		if err != nil {
+			return configError("无法读取替换规则文件: %v", err)
+		}
+		cfg.mapRules = rules
+	}
+	
+	if whitespaceMode(&cfg) {
+		if cfg.SourceString != "" || cfg.TargetString != "" {
+			return configError("空白转换模式不需要 --from/--to 参数")
+		}
+		if cfg.LineMode || cfg.Anchor != AnchorNone || cfg.Swap || cfg.Nth > 0 || cfg.Occurrences != "" || cfg.Word {
+			return configError("空白转换模式不能与 --line-mode、--anchor、--swap、--nth、--occurrences 或 --word 一起使用")
+		}
+	} else if cfg.Map == "" && !trimOnly(&cfg) {
+		promptMissing(&cfg.SourceString, &cfg.TargetString)
+		if cfg.SourceString == "" {
+			return configError("必须指定要替换的源字符串（--from 参数）")
+		}
+		
+		// An explicit --to "" deletes the matches
+		if cfg.TargetString == "" && !cfg.targetSet {
+			return configError("必须指定替换成的目标字符串（--to 参数，删除匹配时使用 --to \"\"）")
+		}
+	}
+	
+	if cfg.GitCommitMessage != "" {
+		cfg.GitCommit = true
+	}
+	if cfg.GitCommit && (cfg.Trial || cfg.EOLReport) {
+		return configError("--git-commit 不能与 --test 或 --eol-report 一起使用")
+	}
+	
+	if cfg.TUI && (cfg.Trial || cfg.EOLReport || cfg.EOL != EOLNone) {
+		return configError("--tui 不能与 --test、--eol 或 --eol-report 一起使用")
 	}
 	
-	if cfg.TargetString == "" {
-		log.Fatal("必须指定替换成的目标字符串（--to 参数）")
+	if cfg.TUI && (!isTerminal(os.Stdin) || !isTerminal(os.Stdout)) {
+		return configError("--tui 需要在终端中运行（标准输入和标准输出都必须是终端）")
+	}
+	
+	if err := validateEmitScript(); err != nil {
+		return err
+	}
+	
+	if cfg.Diff && (cfg.EOL != EOLNone || cfg.EOLReport || cfg.TUI || cfg.EmitScript != "" || cfg.Format != FormatConsole) {
+		return configError("--diff 只用于控制台输出，不能与 --eol、--eol-report、--tui 或 --emit-script 一起使用")
+	}
+	
+	if strings.ContainsAny(cfg.Backup, `/\`) {
+		return configError("--backup 只能是文件名后缀，不能包含路径分隔符")
+	}
+	
+	if cfg.PreviewLimit < 0 {
+		return configError("--preview-limit 不能为负数")
+	}
+	
+	if cfg.MaxTotal < 0 {
+		return configError("--max-total 不能为负数")
+	}
+	
+	if cfg.ConfirmOver < 0 {
+		return configError("--confirm-over 不能为负数")
+	}
+	
+	if cfg.Swap && (cfg.LineMode || cfg.Anchor != AnchorNone) {
+		return configError("--swap 不能与 --line-mode 或 --anchor 一起使用")
+	}
+	
+	if cfg.Swap && (cfg.IgnoreWhitespace || cfg.Regex || cfg.IgnoreCase) {
+		return configError("--swap 不能与 --ignore-whitespace、--regex 或 --ignore-case 一起使用")
+	}
+	
+	if cfg.Swap && cfg.SourceString == cfg.TargetString {
+		return configError("--swap 要求源字符串和目标字符串不同")
+	}
+	
+	if cfg.Swap && cfg.TargetString == "" {
+		return configError("--swap 不能使用空的目标字符串")
+	}
+	
+	switch cfg.Preflight {
+	case PreflightOff, PreflightWarn, PreflightStrict:
+	default:
+		return configError("无效的预检模式: %s（可选 warn|strict）", cfg.Preflight)
+	}
+	
+	if cfg.TempDir != "" {
+		absTempDir, err := absPath(cfg.TempDir)
+		if err != nil {
+			return configError("无法获取临时文件目录的绝对路径: %v", err)
+		}
+		if info, err := os.Stat(absTempDir); err != nil || !info.IsDir() {
+			return configError("临时文件目录 %s 不存在或不是目录", cfg.TempDir)
+		}
+		cfg.TempDir = absTempDir
+	}
+	return nil
+}
+
+// validateMatchFlags 验证查找匹配和遍历相关的参数
+func validateMatchFlags() error {
+	if cfg.Sequential {
+		cfg.Workers = 1
 	}
 	
 	if cfg.Workers <= 0 {
-		log.Fatal("工人数必须大于0")
+		return configError("工人数必须大于0")
+	}
+	
+	// A pattern spanning lines is matched as a plain literal over the
+	// whole file; the line-based options have nothing to work on
+	if multilinePattern(cfg.SourceString) {
+		if cfg.Regex || cfg.IgnoreCase || cfg.IgnoreWhitespace || cfg.Word || cfg.LineMode || cfg.Anchor != AnchorNone || cfg.Nth > 0 || cfg.Occurrences != "" || cfg.Swap {
+			return configError("含有换行符的 --from 按字面跨行匹配，不能与 --regex、--ignore-case、--ignore-whitespace、--word、--line-mode、--anchor、--nth、--occurrences 或 --swap 一起使用")
+		}
+		if cfg.TrimTrailing || cfg.Diff || cfg.MaxTotal > 0 || cfg.EmitScript != "" || cfg.CheckReversible || cfg.TUI || cfg.Context > 0 {
+			return configError("含有换行符的 --from 不能与 --trim-trailing、--diff、--max-total、--emit-script、--check-reversible、--tui 或 --context 一起使用")
+		}
+	}
+	
+	if cfg.Trim && !cfg.LineMode {
+		return configError("--trim 只能与 --line-mode 一起使用")
+	}
+	
+	switch cfg.Anchor {
+	case AnchorNone, AnchorStart, AnchorEnd, AnchorBoth:
+	default:
+		return configError("无效的锚定方式: %s（可选 start|end|both）", cfg.Anchor)
+	}
+	
+	if cfg.Anchor != AnchorNone && cfg.LineMode {
+		return configError("--anchor 不能与 --line-mode 一起使用")
+	}
+	
+	if cfg.IgnoreWhitespace && (cfg.LineMode || cfg.Anchor != AnchorNone) {
+		return configError("--ignore-whitespace 不能与 --line-mode 或 --anchor 一起使用")
+	}
+	
+	if cfg.IgnoreCase && (cfg.LineMode || cfg.Anchor != AnchorNone) {
+		return configError("--ignore-case 不能与 --line-mode 或 --anchor 一起使用")
+	}
+	
+	if cfg.Regex && (cfg.LineMode || cfg.Anchor != AnchorNone || cfg.IgnoreWhitespace) {
+		return configError("--regex 不能与 --line-mode、--anchor 或 --ignore-whitespace 一起使用（可在模式中使用 ^ 和 $）")
+	}
+	
+	if cfg.Encoding != "" {
+		enc, ok := textEncodings[cfg.Encoding]
+		if !ok {
+			return configError("无效的编码: %s（可选 %s）", cfg.Encoding, encodingNames())
+		}
+		if err := checkEncodable(enc, cfg.Encoding, cfg.SourceString, cfg.TargetString); err != nil {
+			return err
+		}
+		for _, r := range cfg.mapRules {
+			if err := checkEncodable(enc, cfg.Encoding, r.From, r.To); err != nil {
+				return err
+			}
+		}
+		cfg.encoding = enc
+	}
+	
+	filter, err := newPathFilter(cfg.Include, cfg.Exclude)
+	if err != nil {
+		return err
+	}
+	cfg.filter = filter
+	
+	// Compile once up front: a bad pattern fails before any worker starts
+	pattern, err := compilePattern(&cfg)
+	if err != nil {
+		return configError("无效的正则表达式 %q: %v", cfg.SourceString, err)
+	}
+	cfg.pattern = pattern
+	
+	if cfg.TemplateReplace {
+		if !cfg.Regex || cfg.Map != "" {
+			return configError("--template-replace 只能与 --regex 一起使用，不能与 --map 一起使用")
+		}
+		tmpl, err := compileReplaceTemplate(pattern, cfg.TargetString)
+		if err != nil {
+			return configError("无效的替换模板: %v", err)
+		}
+		cfg.template = tmpl
+	}
+	
+	// An undefined group would silently expand to nothing in every match
+	if cfg.Regex && pattern != nil && !cfg.TemplateReplace {
+		if err := checkGroupRefs(pattern, cfg.TargetString); err != nil {
+			return configError("--to 中的分组引用无效: %v", err)
+		}
+	}
+	
+	if cfg.Nth < 0 {
+		return configError("--nth 必须大于0")
+	}
+	
+	occurrences, err := parseOccurrences(cfg.Occurrences)
+	if err != nil {
+		return withKind(ErrInvalidConfig, err)
+	}
+	cfg.occurrences = occurrences
+	
+	// --nth replaces exactly one match per line
+	if cfg.Nth > 0 && cfg.Occurrences != "" && occurrences != 1 {
+		return configError("--nth %d 只替换每行的一处匹配，与 --occurrences %s 矛盾", cfg.Nth, cfg.Occurrences)
+	}
+	
+	if cfg.MaxFiles < 0 {
+		return configError("--max-files 不能为负数")
+	}
+	
+	if cfg.MaxMatchesShown < 0 {
+		return configError("--max-matches-shown 不能为负数")
+	}
+	
+	if cfg.ProfileFiles < 0 {
+		return configError("--profile-files 不能为负数")
+	}
+	
+	window, err := buildTimeWindow(cfg.Since, cfg.Before, cfg.NewerThan, cfg.OlderThan, time.Now())
+	if err != nil {
+		return withKind(ErrInvalidConfig, err)
+	}
+	cfg.window = window
+	
+	owners, err := parseOwners(cfg.Owner)
+	if err != nil {
+		return withKind(ErrInvalidConfig, err)
+	}
+	cfg.owners = owners
+	
+	maxSize, err := parseSize(cfg.MaxSize)
+	if err != nil {
+		return configError("无效的 --max-size: %v", err)
+	}
+	cfg.maxSize = maxSize
+	
+	if cfg.MinifiedPrefix <= 0 || cfg.MinifiedLineLength <= 0 {
+		return configError("--minified-prefix 和 --minified-line-length 必须大于 0")
+	}
+	
+	if cfg.Sample < 0 || cfg.Sample > 100 {
+		return configError("--sample 必须在 0 到 100 之间")
+	}
+	
+	if cfg.AllowIndent && cfg.Anchor != AnchorStart && cfg.Anchor != AnchorBoth {
+		return configError("--allow-indent 只能与 --anchor start|both 一起使用")
+	}
+	return nil
+}
+
+// prepareRun 解析路径参数、创建输出并做安全检查
+func prepareRun(args []string) error {
+	if !cfg.relativeSet {
+		cfg.Relative = relativeDefault(cfg.SourceDir)
+	}
+	
+	// 确保源目录是绝对路径
+	absSourceDir, err := absPath(cfg.SourceDir)
+	if err != nil {
+		return configError("无法获取源目录的绝对路径: %v", err)
+	}
+	cfg.SourceDir = absSourceDir
+	
+	// 位置参数指定的文件或目录代替源目录作为处理对象
+	paths, err := expandPathArgs(args)
+	if err != nil {
+		return withKind(ErrInvalidConfig, err)
+	}
+	cfg.Paths = paths
+	
+	if cfg.Files != "" && cfg.Files0 != "" {
+		return configError("--files 和 --files0 不能同时使用")
+	}
+	if len(paths) > 0 && (cfg.Files != "" || cfg.Files0 != "") {
+		return configError("--files 和 --files0 不能与路径参数一起使用")
+	}
+	
+	// Subcommands may have installed their own reporter already
+	if cfg.Reporter == nil && cfg.EmitScript != "" {
+		cfg.Reporter = newScriptReporter(os.Stdout, cfg.EmitScript)
+	}
+	if cfg.Reporter == nil {
+		reporter, err := newReporter(cfg.Format, os.Stdout, cfg.Verbose)
+		if err != nil {
+			return withKind(ErrInvalidConfig, err)
+		}
+		cfg.Reporter = reporter
+	}
+	
+	if cfg.ReportMD != "" {
+		addReport(&cfg, cfg.ReportMD, newMarkdownReport(cfg.ReportMD))
+	}
+	if cfg.ReportHTML != "" {
+		addReport(&cfg, cfg.ReportHTML, newHTMLReport(cfg.ReportHTML))
+		cfg.reportPreview = htmlDiffLines
+	}
+	if cfg.ReportJUnit != "" {
+		addReport(&cfg, cfg.ReportJUnit, newJUnitReport(cfg.ReportJUnit))
+	}
+	
+	// Only the output sees relative paths; everything collected for the
+	// run itself keeps the absolute ones. Scripts need paths that work
+	// from anywhere.
+	if cfg.Relative && cfg.EmitScript == "" {
+		cfg.display = newPathDisplay(cfg.SourceDir)
+		cfg.Reporter = relativeReporter{cfg.Reporter, cfg.display}
+	}
+	
+	switch cfg.Color {
+	case ColorAuto, ColorAlways, ColorNever:
+	default:
+		return configError("无效的彩色输出模式: %s（可选 auto|always|never）", cfg.Color)
+	}
+	cfg.color = colorEnabled(cfg.Color, os.Stdout)
+	
+	// 拒绝在根目录或主目录上运行，除非明确使用 --force
+	roots := cfg.Paths
+	if len(roots) == 0 {
+		roots = []string{cfg.SourceDir}
+	}
+	for _, root := range roots {
+		if reason := dangerousDirReason(root); reason != "" && !cfg.Force {
+			return kindErrorf(ErrRefused, "源目录是%s，拒绝运行；如确需处理请使用 --force", reason)
+		}
+	}
+	return nil
+}
+
+func main() {
+	restoreConsole := setupConsole()
+	defer restoreConsole()
+	
+	// Exit statuses are decided here only; everything else returns errors
+	if err := rootCmd.Execute(); err != nil {
+		code, print := exitCode(err)
+		if print {
+			log.Print(err)
+		}
+		restoreConsole()
+		os.Exit(code)
+	}
+}
+
+// Run 执行一次替换（或试验、统计）并返回结果。运行无法开始或中途中止时
+// 返回的错误可以用 errors.Is 与 ErrRefused、ErrCanceled、ErrWalkFailed 等比较；
+// 此时已以 failed 状态运行完成钩子。
+func Run(config *Config) (*Result, error) {
+	config.hookOnce = sync.Once{}
+	if config.Reporter == nil {
+		config.Reporter = newConsoleReporter(os.Stdout, config.Verbose)
+	}
+	
+	if config.FS == nil {
+		config.FS = retryFS{osFS{}}
+	}
+	
+	// Fix the seed up front so the banner can show it and every scan
+	// (confirmation, preflight, the real pass) samples the same files
+	if config.Sample > 0 && config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	
+	rules := ruleNames(config)
+	result := &Result{RuleMatches: make([]int32, len(rules))}
+	if config.FailFast {
+		config.Reporter = failFastReporter{config.Reporter, result}
+	}
+	if result.groups = newDirGroups(config.SourceDir, config.GroupDepth); result.groups != nil {
+		config.Reporter = groupReporter{config.Reporter, result.groups}
+	}
+	
+	// Only runs that modify files take the lock; read-only scans may overlap
+	if !config.Trial && !config.EOLReport && !config.NoLock && config.lock == nil {
+		lock, err := acquireLock(config)
+		if err != nil {
+			return nil, failRun(config, nil, withKind(ErrRefused, err))
+		}
+		config.lock = lock
+		config.artifacts.addFile(lock.path)
+	}
+	defer config.lock.release()
+	stopWatch := watchInterrupt(config)
+	defer stopWatch()
+	
+	if config.Journal && !config.Trial && !config.EOLReport && config.journal == nil {
+		j, err := newJournal(config.SourceDir)
+		if err != nil {
+			return nil, failRun(config, nil, kindErrorf(ErrSetupFailed, "创建撤销日志时发生错误: %w", err))
+		}
+		config.journal = j
+	}
+	
+	config.filesystems = detectFilesystems(config)
+	config.Reporter.Start(config)
+	
+	if config.matcher == nil {
+		config.matcher = buildMatcher(config)
+	}
+	if config.multiline == nil && multilinePattern(config.SourceString) {
+		config.multiline = newMultilineMatcher(config.SourceString, config.TargetString, config.NormalizeEOLInPattern)
+	}
+	
+	if config.mdMatcher == nil && config.TrimTrailing && config.KeepMDBreaks {
+		config.mdMatcher = buildTrailingMatcher(config, true)
+	}
+	
+	// Our own state directory (undo journals) is never input
+	config.artifacts.addDir(filepath.Join(config.SourceDir, stateDirName))
+	
+	// Nor is the --map file, which may live in the tree it rewrites
+	if config.Map != "" {
+		config.artifacts.addFile(config.Map)
+	}
+	
+	// Two-phase run: scan first and ask before touching many files
+	if !config.Trial && !config.EOLReport && config.ConfirmOver > 0 {
+		if err := confirmBlastRadius(config, rules); err != nil {
+			config.journal.close()
+			return nil, failRun(config, nil, err)
+		}
+	}
+	
+	// Let the user pick the files to change in a full-screen review
+	if config.TUI && config.selected == nil {
+		if err := reviewChanges(config, rules); err != nil {
+			config.journal.close()
+			return nil, failRun(config, nil, err)
+		}
+	}
+	
+	// Find unwritable targets before the first file is touched
+	if !config.Trial && !config.EOLReport && config.Preflight != PreflightOff {
+		if err := preflightWritable(config, rules); err != nil {
+			config.journal.close()
+			return nil, failRun(config, nil, err)
+		}
+	}
+	
+	// An interrupt during the prompts or the prescan stops before any
+	// file is touched
+	if interruptRequested(config) {
+		config.journal.close()
+		return nil, interruptedRun(config, nil)
+	}
+	
+	start := time.Now()
+	err := processDirectory(config, result)
+	if err != nil {
+		return nil, failRun(config, result, kindErrorf(ErrWalkFailed, "处理目录时发生错误: %w", err))
+	}
+	if err := config.journal.close(); err != nil {
+		config.Reporter.Error(config.SourceDir, fmt.Errorf("关闭撤销日志时发生错误: %w", err))
+	}
+	result.Elapsed = time.Since(start)
+	
+	config.Reporter.Summary(config, result)
+	
+	// Files finished before the interrupt stay replaced and journaled
+	if interruptRequested(config) {
+		return nil, interruptedRun(config, result)
+	}
+	
+	return result, nil
+}
+
+func processDirectory(config *Config, result *Result) error {
+	// Candidate files, handed out largest first or in walk order with --seq
+	queue := newWorkQueue(config.Sequential)
+	config.sampler = newSampler(config)
+	if config.FollowSymlinks {
+		config.links = newLinkTracker()
+	}
+	
+	// What each worker is doing, dumped on SIGUSR1/SIGQUIT
+	live := newLiveStats(config.Workers, queue)
+	stopStats := watchStats(config, result, live)
+	defer stopStats()
+	
+	// Wait group for workers
+	var wg sync.WaitGroup
+	
+	// Start worker goroutines
+	for i := 0; i < config.Workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			processFiles(config, result, queue, live, workerID)
+		}(i)
+	}
+	
+	// Explicit path arguments replace the source directory as walk roots
+	roots := config.Paths
+	if len(roots) == 0 {
+		roots = []string{config.SourceDir}
+	}
+	
+	var err error
+	if name, _ := fileListSource(config); name != "" {
+		// A list from other tooling (git grep -l, find -print0) takes the
+		// place of the walk
+		err = queueFileList(config, result, queue)
+		roots = nil
+	}
+	for _, root := range roots {
+		if stopAtCap(result) || aborted(result) || interruptRequested(config) {
+			break
+		}
+		if err = walkRoot(config, result, root, queue); err != nil {
+			break
+		}
+	}
+	
+	queue.close()
+	wg.Wait()
+	
+	if errors.Is(err, errMaxFilesReached) {
+		return nil
+	}
+	return err
+}
+
+// walkRoot walks one root and queues candidate files. A root
+// that is a file rather than a directory is a candidate by itself.
+func walkRoot(config *Config, result *Result, root string, queue *workQueue) error {
+	reporter := config.Reporter
+	
+	info, err := config.FS.Lstat(root)
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		reporter.Error(root, fmt.Errorf("访问路径 %s 时发生错误: %w", root, err))
+		return nil
+	}
+	
+	if !info.IsDir() {
+		return considerFile(config, result, root, fs.FileInfoToDirEntry(info), true, queue)
+	}
+	
+	// Links are resolved to their targets, so the roots count as entered
+	if config.links != nil {
+		config.links.enterDir(root, info)
+	}
+	
+	// With -x only directories on the root's filesystem are entered; a
+	// root whose device cannot be determined is not walked at all
+	var rootDev uint64
+	if config.OneFileSystem {
+		rootDev, err = deviceID(root, fs.FileInfoToDirEntry(info))
+		if err != nil {
+			atomic.AddInt32(&result.Errors, 1)
+			reporter.Error(root, fmt.Errorf("获取 %s 所在文件系统时发生错误: %w", root, err))
+			return nil
+		}
+	}
+	
+	// Walk directory and send files to channel
+	return config.FS.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories deleted under the walk are as harmless as files
+			if _, statErr := config.FS.Lstat(path); errors.Is(err, fs.ErrNotExist) && errors.Is(statErr, fs.ErrNotExist) {
+				countSkip(result, SkipVanished)
+				reporter.FileSkipped(path, true, SkipVanished)
+				return nil
+			}
+			atomic.AddInt32(&result.Errors, 1)
+			reporter.Error(path, fmt.Errorf("访问目录 %s 时发生错误: %w", path, err))
+			return nil
+		}
+		
+		// Stop enqueueing once the replacement cap has been used up,
+		// --fail-fast saw an error or the run was interrupted
+		if stopAtCap(result) || aborted(result) || interruptRequested(config) {
+			return filepath.SkipAll
+		}
+		
+		if !d.IsDir() {
+			return considerFile(config, result, path, d, false, queue)
+		}
+		
+		// The root itself was asked for explicitly and is never skipped
+		if path == root {
+			return nil
+		}
+		
+		// Never read back our own output (reports, logs, backups)
+		if config.artifacts.contains(path) {
+			countSkip(result, SkipArtifact)
+			reporter.FileSkipped(path, true, SkipArtifact)
+			return filepath.SkipDir
+		}
+		
+		// Excluded subtrees are pruned without reading them
+		if config.filter.excludesDir(config.SourceDir, path) {
+			countSkip(result, SkipFiltered)
+			reporter.FileSkipped(path, true, SkipFiltered)
+			return filepath.SkipDir
+		}
+		
+		// Only the top level is of interest in non-recursive mode
+		if config.NoRecursive {
+			return filepath.SkipDir
+		}
+		
+		// Mount points lead to another filesystem (bind mounts, NFS, ...)
+		if config.OneFileSystem {
+			dev, err := deviceID(path, d)
+			if err != nil {
+				atomic.AddInt32(&result.Errors, 1)
+				reporter.Error(path, fmt.Errorf("获取 %s 所在文件系统时发生错误: %w", path, err))
+				return filepath.SkipDir
+			}
+			if dev != rootDev {
+				countSkip(result, SkipMountPoint)
+				reporter.FileSkipped(path, true, SkipMountPoint)
+				return filepath.SkipDir
+			}
+		}
+		
+		// /proc, /sys, /dev and /run are never source trees, even under --force
+		if !config.IncludeVirtualFS {
+			if _, virtual := virtualFilesystem(path); virtual {
+				countSkip(result, SkipVirtualFS)
+				reporter.FileSkipped(path, true, SkipVirtualFS)
+				return filepath.SkipDir
+			}
+		}
+		
+		if config.SkipNetworkDirs {
+			if _, network, err := filesystemType(path); err == nil && network {
+				countSkip(result, SkipNetworkDir)
+				reporter.FileSkipped(path, true, SkipNetworkDir)
+				return filepath.SkipDir
+			}
+		}
+		
+		// VCS metadata is never a candidate, whatever its attributes say;
+		// on Windows .git is usually not marked hidden
+		if isVCSDir(d.Name()) && !config.IncludeVCS {
+			countSkip(result, SkipVCS)
+			reporter.FileSkipped(path, true, SkipVCS)
+			return filepath.SkipDir
+		}
+		
+		// Skip hidden directories and their contents based on attributes
+		hidden, err := isHidden(path, d)
+		if err != nil {
+			reporter.Error(path, fmt.Errorf("检查目录 %s 隐藏属性时发生错误: %w", path, err))
+		}
+		
+		if hidden {
+			countSkip(result, SkipHidden)
+			reporter.FileSkipped(path, true, SkipHidden)
+			return filepath.SkipDir
+		}
+		
+		if skipSystem(config, result, path, d) {
+			return filepath.SkipDir
+		}
+		
+		// Already walked through a symlink pointing here
+		if config.links != nil {
+			if info, err := d.Info(); err == nil && !config.links.enterDir(path, info) {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+}
+
+// considerFile applies the file filters and queues the file when it is a
+// candidate. Hidden and system attributes are not checked for files the
+// user named explicitly.
+func considerFile(config *Config, result *Result, path string, d fs.DirEntry, explicit bool, queue *workQueue) error {
+	reporter := config.Reporter
+	
+	// Never read back our own output (reports, logs, backups)
+	if config.artifacts.contains(path) {
+		countSkip(result, SkipArtifact)
+		reporter.FileSkipped(path, false, SkipArtifact)
+		return nil
 	}
 	
-	// 确保源目录是绝对路径
-	absSourceDir, err := filepath.Abs(cfg.SourceDir)
-	if err != nil {
-		log.Fatalf("无法获取源目录的绝对路径: %v", err)
+	if d.Type()&fs.ModeSymlink != 0 {
+		return considerSymlink(config, result, path, d, explicit, queue)
 	}
-	cfg.SourceDir = absSourceDir
 	
-	Run(&cfg)
-}
-
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	// Skip non-regular files and hidden files. The type bits come from
+	// the directory read, so no extra stat is needed here.
+	if !d.Type().IsRegular() {
+		return nil
 	}
-}
-
-func Run(config *Config) {	
-	fmt.Printf("开始字符串替换...:\n")
-	fmt.Printf("  源目录: %s\n", config.SourceDir)
-	fmt.Printf("  源字符串: '%s'\n", config.SourceString)
-	fmt.Printf("  目标字符串: '%s'\n", config.TargetString)
-	fmt.Printf("  工人数: %d\n", config.Workers)
-	fmt.Printf("  试验模式: %v\n", config.Trial)
-	fmt.Println()
 	
-	result := &Result{}
-	err := processDirectory(config, result)
-	if err != nil {
-		log.Fatalf("处理目录时发生错误: %v", err)
+	// A file reached through several links is still processed only once
+	if config.links != nil {
+		if info, err := d.Info(); err == nil && !config.links.visitFile(path, info) {
+			return nil
+		}
 	}
 	
-	fmt.Printf("\n最终结果:\n")
-	fmt.Printf("  发现文件数: %d\n", atomic.LoadInt32(&result.FilesFound))
-	fmt.Printf("  处理文件数: %d\n", atomic.LoadInt32(&result.FilesProcessed))
-	fmt.Printf("  匹配文件数: %d\n", atomic.LoadInt32(&result.FilesMatches))
-	fmt.Printf("  匹配替换数: %d\n", atomic.LoadInt32(&result.Matches))
-	fmt.Printf("  错误: %d\n", atomic.LoadInt32(&result.Errors))
-	
-	if config.Trial {
-		fmt.Println("\n注意：本次运行在试验模式下，未实际执行替换操作.")
+	// Never treat our own temp files as candidates; old ones are
+	// leftovers from a crashed run and may be cleaned up
+	if isTempFileName(d.Name()) {
+		handleTempFile(config, result, path)
+		return nil
 	}
-}
-
-func processDirectory(config *Config, result *Result) error {
-	// Channel for file paths
-	fileChan := make(chan string, 1000)
 	
-	// Wait group for workers
-	var wg sync.WaitGroup
+	// Backups of an earlier --backup run are never input again
+	if isBackupName(d.Name(), config.Backup) {
+		countSkip(result, SkipBackup)
+		reporter.FileSkipped(path, false, SkipBackup)
+		return nil
+	}
 	
-	// Start worker goroutines
-	for i := 0; i < config.Workers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			processFiles(config, result, fileChan, workerID)
-		}(i)
+	if !explicit && !config.filter.allowsFile(config.SourceDir, path) {
+		countSkip(result, SkipFiltered)
+		reporter.FileSkipped(path, false, SkipFiltered)
+		return nil
 	}
 	
-	// Walk directory and send files to channel
-	err := filepath.Walk(config.SourceDir, func(path string, info os.FileInfo, err error) error {
+	if !explicit {
+		hidden, err := isHidden(path, d)
 		if err != nil {
-			atomic.AddInt32(&result.Errors, 1)
-			if config.Verbose {
-				log.Printf("访问目录 %s 时发生错误: %v", path, err)
-			}
-			return nil
+			reporter.Error(path, fmt.Errorf("检查文件 %s 隐藏属性时发生错误: %w", path, err))
 		}
 		
-		// Skip hidden directories and their contents based on attributes
-		if info.IsDir() {
-			hidden, err := isHidden(path, info)
-			if err != nil {
-				if config.Verbose {
-					log.Printf("检查目录 %s 隐藏属性时发生错误: %v", path, err)
-				}
-			}
-			
-			if hidden {
-				if config.Verbose {
-					fmt.Printf("跳过隐藏目录: %s\n", path)
-				}
-				return filepath.SkipDir
-			}
+		if hidden {
+			countSkip(result, SkipHidden)
+			reporter.FileSkipped(path, false, SkipHidden)
 			return nil
 		}
 		
-		// Skip non-regular files and hidden files
-		if !info.Mode().IsRegular() {
+		if skipSystem(config, result, path, d) {
 			return nil
 		}
-		
-		hidden, err := isHidden(path, info)
+	}
+	
+	if config.owners != nil {
+		allowed, err := config.owners.allows(path, d)
 		if err != nil {
-			if config.Verbose {
-				log.Printf("检查目录 %s 隐藏属性时发生错误: %v", path, err)
-			}
+			reporter.Error(path, fmt.Errorf("检查文件 %s 的所有者时发生错误: %w", path, err))
 		}
-		
-		if hidden {
-			if config.Verbose {
-				fmt.Printf("跳过隐藏文件: %s\n", path)
-			}
+		if !allowed {
+			countSkip(result, SkipOwner)
+			reporter.FileSkipped(path, false, SkipOwner)
 			return nil
 		}
-		
-		// NEW: Skip binary files
-		isBinary, err := isBinaryFile(path)
-		if err != nil {
-			if config.Verbose {
-				log.Printf("检查二进制文件 %s 时发生错误: %v", path, err)
-			}
+	}
+	
+	if config.window.active() {
+		info, err := d.Info()
+		if err == nil && !config.window.contains(info.ModTime()) {
+			countSkip(result, SkipAge)
+			reporter.FileSkipped(path, false, SkipAge)
+			return nil
 		}
-
-		if isBinary {
-			if config.Verbose {
-			  fmt.Printf("跳过二进制文件: %s\n", path)
-			}
+	}
+	
+	// Huge files (logs, dumps) are skipped before a single byte is read.
+	// A notice rather than FileSkipped so the log line can carry the size.
+	if config.maxSize > 0 {
+		if info, err := d.Info(); err == nil && info.Size() > config.maxSize {
+			countSkip(result, SkipTooLarge)
+			reporter.Notice(path, fmt.Sprintf("跳过超过 --max-size 的文件 (%s)", formatBytes(info.Size())))
 			return nil
 		}
+	}
+	
+	// Detection is the first read of the file and updates its access
+	// time, so --keep-times notes the original one before it
+	var atime time.Time
+	if config.KeepTimes {
+		if info, err := d.Info(); err == nil {
+			atime = accessTime(info)
+		}
+	}
+	
+	// NEW: Skip binary files
+	detectStart := time.Now()
+	isBinary, err := isBinaryFile(contentFS(config), path, &result.IO)
+	detect := time.Since(detectStart)
+	if skipVanished(config, result, path, err) {
+		return nil
+	}
+	if err != nil {
+		reporter.Error(path, fmt.Errorf("检查二进制文件 %s 时发生错误: %w", path, err))
+	}
 
-		atomic.AddInt32(&result.FilesFound, 1)
-		fileChan <- path
+	if isBinary {
+		countSkip(result, SkipBinary)
+		reporter.FileSkipped(path, false, SkipBinary)
 		return nil
-	})
+	}
+
+	if config.SkipMinified {
+		minified, err := isMinified(config.FS, path, minifiedRuleFor(config), &result.IO)
+		if err != nil {
+			reporter.Error(path, fmt.Errorf("检查文件 %s 是否为压缩代码时发生错误: %w", path, err))
+		}
+		if minified {
+			countSkip(result, SkipMinified)
+			reporter.FileSkipped(path, false, SkipMinified)
+			return nil
+		}
+	}
 	
-	close(fileChan)
-	wg.Wait()
+	// Sample only after every filter so the estimate reflects the real
+	// candidate population
+	if !sampled(config) {
+		countSkip(result, SkipNotSampled)
+		reporter.FileSkipped(path, false, SkipNotSampled)
+		return nil
+	}
 	
-	return err
+	// Stop the walk as soon as one more candidate than allowed shows up
+	if config.MaxFiles > 0 && atomic.LoadInt32(&result.FilesFound) >= int32(config.MaxFiles) {
+		atomic.StoreInt32(&result.MaxFilesReached, 1)
+		return errMaxFilesReached
+	}
+	
+	// The size only orders the queue; an unknown size sorts last
+	item := workItem{path: path, detect: detect, atime: atime}
+	if info, err := d.Info(); err == nil {
+		item.size = info.Size()
+	}
+	
+	atomic.AddInt32(&result.FilesFound, 1)
+	queue.push(item)
+	return nil
+}
+
+// skipSystem reports (and counts) entries carrying the system attribute
+// when --skip-system is on
+func skipSystem(config *Config, result *Result, path string, d fs.DirEntry) bool {
+	if !config.SkipSystem {
+		return false
+	}
+	
+	system, err := isSystem(path, d)
+	if err != nil {
+		config.Reporter.Error(path, fmt.Errorf("检查 %s 系统属性时发生错误: %w", path, err))
+		return false
+	}
+	
+	if system {
+		countSkip(result, SkipSystem)
+		config.Reporter.FileSkipped(path, d.IsDir(), SkipSystem)
+	}
+	return system
+}
+
+// handleTempFile counts stale temp files and removes them when requested.
+// Read-only runs only list the files --clean-stale would remove.
+func handleTempFile(config *Config, result *Result, path string) {
+	stale, err := isStaleTempFile(config.FS, path, config.StaleAge)
+	if err != nil || !stale {
+		return
+	}
+	
+	atomic.AddInt32(&result.StaleTemps, 1)
+	if !config.CleanStale {
+		config.Reporter.Notice(path, "发现残留临时文件")
+		return
+	}
+	
+	if readOnlyRun(config) {
+		config.Reporter.Notice(path, "发现残留临时文件（只读运行，未删除）")
+		return
+	}
+	
+	if err := config.FS.Remove(path); err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		config.Reporter.Error(path, fmt.Errorf("删除残留临时文件 %s 时发生错误: %w", path, err))
+		return
+	}
+	
+	atomic.AddInt32(&result.StaleRemoved, 1)
+	config.Reporter.Notice(path, "删除残留临时文件")
+}
+
+// readOnlyRun reports whether the run must not modify anything: trial
+// runs, find and verify (which run as trials) and --eol-report
+func readOnlyRun(config *Config) bool {
+	return config.Trial || config.EOLReport
 }
 
-func processFiles(config *Config, result *Result, fileChan <-chan string, workerID int) {
-	for filePath := range fileChan {
-		err := processSingleFile(config, result, filePath)
-		if err != nil && config.Verbose {
-			log.Printf("工人 %d: 处理文件 %s 时发生错误: %v", workerID, filePath, err)
+func processFiles(config *Config, result *Result, queue *workQueue, live *liveStats, workerID int) {
+	for {
+		item, ok := queue.pop()
+		if !ok {
+			return
+		}
+		// After --fail-fast or an interrupt stopped the run the rest is
+		// drained unprocessed, so a walk waiting on a full queue can still
+		// finish
+		if aborted(result) || interruptRequested(config) {
+			continue
+		}
+		live.begin(workerID, item.path)
+		start := time.Now()
+		phases := phaseTimes{Detect: item.detect}
+		err := processSingleFile(config, result, item.path, item.atime, &phases)
+		if err != nil {
+			config.Reporter.Error(item.path, fmt.Errorf("工人 %d: %w", workerID, err))
 		}
+		elapsed := time.Since(start)
+		live.end(workerID)
+		config.Reporter.FileScanned(item.path, item.size, elapsed)
+		checkSlow(config, result, item.path, item.detect+elapsed, phases)
 	}
 }
 
-func processSingleFile(config *Config, result *Result, filePath string) error {
+// maxPreviewLines caps how many preview lines per file go into the
+// Markdown report and annotations, independent of --max-matches-shown
+const maxPreviewLines = 10
+
+// consoleLines returns how many matching lines per file the console shows
+func consoleLines(config *Config) int {
+	if config.MaxMatchesShown == 0 {
+		return math.MaxInt
+	}
+	return config.MaxMatchesShown
+}
+
+// processSingleFile scans and rewrites one file, adding the time spent
+// in each phase to phases
+func processSingleFile(config *Config, result *Result, filePath string, atime time.Time, phases *phaseTimes) error {
+	if keep, listed := config.selected[filePath]; config.selected != nil && !keep {
+		if listed {
+			countSkip(result, SkipExcluded)
+			config.Reporter.FileSkipped(filePath, false, SkipExcluded)
+		}
+		return nil
+	}
+	
 	atomic.AddInt32(&result.FilesProcessed, 1)
 	
+	if config.EOLReport {
+		defer timePhase(&phases.Scan)()
+		return surveyLineEndings(config, result, filePath)
+	}
+	
+	if config.EOL != EOLNone {
+		defer timePhase(&phases.Rewrite)()
+		return processLineEndings(config, result, filePath, writeOptionsFor(config, atime))
+	}
+	
+	if config.multiline != nil {
+		defer timePhase(&phases.Rewrite)()
+		return processMultiline(config, result, filePath, writeOptionsFor(config, atime))
+	}
+	
+	// Only collect matching lines when they will actually be shown
+	previewLimit := 0
+	switch {
+	case config.previewAll:
+		previewLimit = math.MaxInt
+	case config.Diff:
+		// The diff is read separately and replaces the line preview
+	case config.Trial || config.Verbose:
+		previewLimit = max(consoleLines(config), maxPreviewLines)
+	}
+	if config.reportPreview > previewLimit {
+		previewLimit = config.reportPreview
+	}
+	
 	// Check if file contains the search string
-	contains, matchCount, err := fileContainsString(filePath, config.SourceString)
+	base := matcherFor(config, filePath)
+	stop := timePhase(&phases.Scan)
+	scan, err := fileContainsString(contentFS(config), filePath, base, len(result.RuleMatches), previewLimit, config.Context, &result.IO)
+	stop()
+	if skipVanished(config, result, filePath, err) {
+		return nil
+	}
 	if err != nil {
 		atomic.AddInt32(&result.Errors, 1)
 		return fmt.Errorf("检查文件 %s 时发生错误: %w", filePath, err)
 	}
 	
-	if !contains {
+	if scan.Matches == 0 {
 		// if config.Verbose {
-		// 	 fmt.Printf("在文件 %s 中没有匹配字符串\n", filePath)
+		// 	 config.output.Printf("在文件 %s 中没有匹配字符串\n", filePath)
 		// }
 		return nil
 	}
 	
-	if config.Verbose {
-		fmt.Printf("发现 %4d 处匹配字符串: %s\n", matchCount, filePath)
+	// Every match already reads as its replacement: rewriting would only
+	// churn the modification time
+	if !scan.Changed && !config.searchOnly {
+		countSkip(result, SkipUnchanged)
+		config.Reporter.FileSkipped(filePath, false, SkipUnchanged)
+		return nil
+	}
+	
+	// Honor the global replacement cap. Matches are reserved before any
+	// substitution so concurrent workers can never overshoot it.
+	matcher := base
+	replaced := 0 // matches the file ends up using from the quota
+	if config.MaxTotal > 0 {
+		granted := reserveMatches(config, result, scan.Matches)
+		if granted == 0 {
+			return nil
+		}
+		// A rewrite that fails or changes nothing hands its quota back
+		defer func() { settleMatches(config, result, granted, replaced) }()
+		if granted < scan.Matches {
+			matcher = &limitMatcher{inner: matcher, remaining: granted}
+			preview := trimPreview(scan.Preview, granted)
+			
+			// Rescan against the capped matcher so the per-rule counts and
+			// the projected size change reflect what is actually replaced
+			stop := timePhase(&phases.Scan)
+			scan, err = fileContainsString(contentFS(config), filePath, &limitMatcher{inner: base, remaining: granted}, len(result.RuleMatches), 0, 0, &result.IO)
+			stop()
+			if skipVanished(config, result, filePath, err) {
+				return nil
+			}
+			if err != nil {
+				atomic.AddInt32(&result.Errors, 1)
+				return fmt.Errorf("检查文件 %s 时发生错误: %w", filePath, err)
+			}
+			scan.Preview = preview
+		}
+	}
+	
+	event := FileEvent{Path: filePath, Matches: scan.Matches, Preview: scan.Preview, Delta: scan.Delta}
+	
+	// A second pass renders the diff before anything is written; a capped
+	// matcher gets a fresh copy so the replacement keeps its full quota
+	if config.Diff {
+		diffMatcher := matcher
+		if lm, ok := matcher.(*limitMatcher); ok {
+			diffMatcher = &limitMatcher{inner: base, remaining: lm.remaining}
+		}
+		stop := timePhase(&phases.Scan)
+		event.Diff, err = fileDiff(contentFS(config), filePath, diffMatcher, config.color, &result.IO)
+		stop()
+		if skipVanished(config, result, filePath, err) {
+			return nil
+		}
+		if err != nil {
+			atomic.AddInt32(&result.Errors, 1)
+			return fmt.Errorf("生成文件 %s 的差异时发生错误: %w", filePath, err)
+		}
 	}
 	
 	if config.Trial {
-		fmt.Printf("[试验] 替换 %d 处字符串: %s\n", matchCount, filePath)
-		atomic.AddInt32(&result.Matches, int32(matchCount))
+		replaced = scan.Matches
+		atomic.AddInt32(&result.Matches, int32(scan.Matches))
   	atomic.AddInt32(&result.FilesMatches, 1);
+		atomic.AddInt64(&result.SizeDelta, scan.Delta)
+		addRuleMatches(result, scan.RuleCounts)
+		config.Reporter.FileMatched(event)
+		return nil
+	}
+	
+	if config.unwritable[filePath] {
+		countSkip(result, SkipUnwritable)
+		config.Reporter.FileSkipped(filePath, false, SkipUnwritable)
+		return nil
+	}
+	
+	// The temp copy needs about as much space as the original; refuse
+	// rather than fail halfway through a nearly full volume
+	size := int64(0)
+	if info, err := config.FS.Lstat(filePath); err == nil {
+		size = info.Size()
+	}
+	release, ok := reserveTempSpace(config, result, filePath, size)
+	if !ok {
+		countSkip(result, SkipNoSpace)
+		config.Reporter.FileSkipped(filePath, false, SkipNoSpace)
+		return nil
+	}
+	defer release()
+	
+	defer timePhase(&phases.Rewrite)()
+	
+	// Keep the original for undo before it is overwritten
+	backup, err := config.journal.save(config.FS, filePath)
+	if skipVanished(config, result, filePath, err) {
+		return nil
+	}
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		return fmt.Errorf("记录 %s 的原始内容时发生错误: %w", filePath, err)
+	}
+	
+	// A copy next to the original for trees without version control
+	backupPath, err := writeBackup(config, filePath)
+	if skipVanished(config, result, filePath, err) {
 		return nil
 	}
+	if err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		return fmt.Errorf("备份 %s 时发生错误: %w", filePath, err)
+	}
 	
 	// Perform actual replacement
-	replacedCount, err := replaceInFile(filePath, config.SourceString, config.TargetString)
+	opts := writeOptionsFor(config, atime)
+	opts.size = size + scan.Delta
+	rewrite, err := replaceInFile(contentFS(config), filePath, config.TempDir, matcher, len(result.RuleMatches), opts, &result.IO)
+	if err != nil {
+		discardBackup(config, backupPath)
+	}
+	if err != nil && isNoSpace(err) {
+		// The temp file is already gone and the original untouched
+		countSkip(result, SkipNoSpace)
+		config.Reporter.FileSkipped(filePath, false, SkipNoSpace)
+		return nil
+	}
+	if skipVanished(config, result, filePath, err) {
+		return nil
+	}
+	if errors.Is(err, errUnchanged) {
+		countSkip(result, SkipUnchanged)
+		config.Reporter.FileSkipped(filePath, false, SkipUnchanged)
+		return nil
+	}
 	if err != nil {
 		atomic.AddInt32(&result.Errors, 1)
 		return fmt.Errorf("替换 %s 文件时发生错误: %w", filePath, err)
 	}
 	
-	atomic.AddInt32(&result.Matches, int32(replacedCount))
+	if rewrite.PreallocErr != nil {
+		config.Reporter.Notice(filePath, fmt.Sprintf("预分配临时文件失败（%v），未预分配继续写入", rewrite.PreallocErr))
+	}
+	if rewrite.OwnerErr != nil {
+		config.Reporter.Notice(filePath, fmt.Sprintf("无法保留原文件的所有者（%v）", rewrite.OwnerErr))
+	}
+	
+	if err := config.journal.record(config.FS, filePath, backup); err != nil {
+		atomic.AddInt32(&result.Errors, 1)
+		config.Reporter.Error(filePath, fmt.Errorf("登记撤销日志 %s 时发生错误: %w", filePath, err))
+	}
+	
+	// The rewrite counted UTF-8 bytes; report the encoded sizes on disk
+	if config.encoding != nil {
+		rewrite.BytesBefore = size
+		if info, err := config.FS.Lstat(filePath); err == nil {
+			rewrite.BytesAfter = info.Size()
+		}
+	}
+	
+	if backupPath != "" {
+		atomic.AddInt32(&result.Backups, 1)
+	}
+	
+	replaced = rewrite.Replaced
+	atomic.AddInt32(&result.Matches, int32(rewrite.Replaced))
 	atomic.AddInt32(&result.FilesMatches, 1);
-	fmt.Printf("替换 %d 处字符串: %s\n", replacedCount, filePath)
+	atomic.AddInt64(&result.SizeDelta, rewrite.Delta())
+	addRuleMatches(result, rewrite.RuleCounts)
+	
+	event.Matches = rewrite.Replaced
+	event.Delta = rewrite.Delta()
+	event.BytesBefore = rewrite.BytesBefore
+	event.BytesAfter = rewrite.BytesAfter
+	config.Reporter.FileReplaced(event)
 	
 	return nil
 }
 
-func fileContainsString(filePath, searchStr string) (bool, int, error) {
-	file, err := os.Open(filePath)
+// addRuleMatches adds a file's per-rule counts to the run totals
+func addRuleMatches(result *Result, ruleCounts []int) {
+	for i, n := range ruleCounts {
+		atomic.AddInt32(&result.RuleMatches[i], int32(n))
+	}
+}
+
+// fileScan is the outcome of the counting pass over a single file
+type fileScan struct {
+	Matches    int
+	RuleCounts []int       // per-rule matches, nil when there is a single rule
+	Delta      int64       // projected size change in bytes
+	Preview    []lineMatch // up to previewLimit matching lines
+	Changed    bool        // some match differs from its replacement
+}
+
+// fileContainsString counts matches in the file and returns up to
+// previewLimit matching lines for display, each surrounded by up to
+// context lines before and after it (context lines carry no matches)
+func fileContainsString(fsys FileSystem, filePath string, matcher Matcher, rules, previewLimit, context int, stats *IOStats) (fileScan, error) {
+	var scan fileScan
+	
+	file, err := stats.open(fsys, filePath)
 	if err != nil {
-		return false, 0, err
+		return scan, err
 	}
 	defer file.Close()
 	
-	matchCount := 0
-	scanner := bufio.NewScanner(file)
+	if rules > 0 {
+		scan.RuleCounts = make([]int, rules)
+	}
 	
-	for scanner.Scan() {
-		line := scanner.Text()
-		count := strings.Count(line, searchStr)
-		matchCount += count
+	// A plain literal search needs no lines when nothing is previewed
+	if lm, ok := matcher.(*literalMatcher); ok && lm.search != "" && previewLimit == 0 && context == 0 && scan.RuleCounts == nil {
+		count, err := countLiteral(stats.reader(file), []byte(lm.search))
+		if err != nil {
+			return fileScan{}, err
+		}
+		scan.Matches = count
+		scan.Delta = int64(count) * int64(len(lm.replace)-len(lm.search))
+		scan.Changed = count > 0 && lm.search != lm.replace
+		return scan, nil
 	}
 	
-	if err := scanner.Err(); err != nil {
-		return false, 0, err
+	lineNo := 0
+	shown := 0       // matching lines added to the preview
+	lastShown := 0   // line number of the last preview line
+	after := 0       // context lines still to show after the last match
+	var before []lineMatch
+	
+	// Lines of any length, as in replaceInFile; a bufio.Scanner would fail
+	// on minified or generated files with lines over 64KB
+	lines := newLineReader(stats.reader(file))
+	for {
+		_, body, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fileScan{}, err
+		}
+		lineNo++
+		// A view of the reader's buffer; copied only when the preview
+		// keeps the line
+		line := lineString(body)
+		matches := matcher.FindAll(line)
+		if err := checkMatches(line, matches); err != nil {
+			return fileScan{}, fmt.Errorf("第 %d 行: %w", lineNo, err)
+		}
+		scan.Matches += len(matches)
+		
+		for _, m := range matches {
+			scan.Delta += int64(len(m.Replacement) - (m.End - m.Start))
+			if scan.RuleCounts != nil {
+				scan.RuleCounts[m.Rule]++
+			}
+		}
+		if !scan.Changed {
+			scan.Changed = !sameReplacements(line, matches)
+		}
+		
+		switch {
+		case len(matches) > 0 && shown < previewLimit:
+			for _, lm := range before {
+				if lm.LineNo > lastShown {
+					scan.Preview = append(scan.Preview, lm)
+				}
+			}
+			before = before[:0]
+			scan.Preview = append(scan.Preview, lineMatch{LineNo: lineNo, Line: strings.Clone(line), Matches: matches})
+			lastShown = lineNo
+			after = context
+			shown++
+		case after > 0:
+			scan.Preview = append(scan.Preview, lineMatch{LineNo: lineNo, Line: strings.Clone(line)})
+			lastShown = lineNo
+			after--
+		case context > 0 && shown < previewLimit:
+			if len(before) == context {
+				before = append(before[:0], before[1:]...)
+			}
+			before = append(before, lineMatch{LineNo: lineNo, Line: strings.Clone(line)})
+		}
 	}
 	
-	return matchCount > 0, matchCount, nil
+	return scan, nil
 }
 
-func replaceInFile(filePath, searchStr, replaceStr string) (int, error) {
-	// Create temporary file
-	tempFile := filePath + ".tmp"
+// errUnchanged reports a rewrite whose output turned out byte-identical
+// to the input; the temp file is discarded and the original left alone
+var errUnchanged = errors.New("替换后内容不变")
+
+// rewriteResult describes a completed in-place replacement
+type rewriteResult struct {
+	Replaced    int
+	RuleCounts  []int // per-rule substitutions, nil when there is a single rule
+	BytesBefore int64
+	BytesAfter  int64
 	
-	inputFile, err := os.Open(filePath)
+	// PreallocErr is why the temp file could not be preallocated; the
+	// rewrite went ahead without it
+	PreallocErr error
+	
+	// OwnerErr is why the original owner could not be kept; the file was
+	// replaced with the current user as owner
+	OwnerErr    error
+}
+
+// Delta returns the size change of the rewritten file in bytes
+func (r rewriteResult) Delta() int64 {
+	return r.BytesAfter - r.BytesBefore
+}
+
+func replaceInFile(fsys FileSystem, filePath, tempDir string, matcher Matcher, rules int, opts writeOptions, stats *IOStats) (rewrite rewriteResult, err error) {
+	inputFile, err := stats.open(fsys, filePath)
 	if err != nil {
-		return 0, err
+		return rewrite, err
 	}
 	defer inputFile.Close()
 	
-	outputFile, err := os.Create(tempFile)
+	// Create temporary file
+	outputFile, err := createTempFile(fsys, filePath, tempDir)
 	if err != nil {
-		return 0, err
+		return rewrite, err
 	}
+	stats.addOpen()
+	tempFile := outputFile.Name()
 	defer outputFile.Close()
 	
-	replacementCount := 0
-	reader := bufio.NewReader(inputFile)
-	writer := bufio.NewWriter(outputFile)
+	// With --clone, lines whose replacements keep their length are
+	// patched into a copy-on-write clone of the original
+	var clone *cloneTarget
+	if opts.clone {
+		clone = tryClone(inputFile, outputFile)
+	}
+	
+	// Reserve the expected output size up front so a nearly full volume
+	// fails before any writing rather than halfway through
+	preallocated := false
+	if clone == nil {
+		preallocated, rewrite.PreallocErr = preallocTemp(outputFile, opts.size)
+	}
+	
+	// Everything that went into the temp file counts as written; the
+	// extents a clone still shares with the original do not
+	defer func() {
+		stats.addWritten(rewrite.BytesAfter - clone.sharedBytes())
+	}()
+	
+	// Never leave a temp file behind when the replacement fails
+	defer func() {
+		if err != nil {
+			discardTemp(fsys, outputFile)
+		}
+	}()
+	
+	if rules > 0 {
+		rewrite.RuleCounts = make([]int, rules)
+	}
+	
+	verifier := newWriteVerifier(opts.verify)
+	lines := newLineReader(stats.reader(inputFile))
+	writer := bufio.NewWriter(verifier.wrap(outputFile))
 	
+	// Lines are views of the reader's buffer (or of a reused buffer for
+	// lines longer than it); nothing is allocated for lines without matches
+	changed := false // whether any replacement differs from the text it replaces
 	for {
-		line, err := reader.ReadString('\n')
-		if err != nil && err != io.EOF {
-			return replacementCount, err
+		line, body, err := lines.next()
+		if err == io.EOF {
+			break
 		}
-		
-		// Perform replacement on the line (excluding newline character)
-		var lineContent string
-		if strings.HasSuffix(line, "\n") {
-			lineContent = line[:len(line)-1]
-		} else {
-			lineContent = line
+		if err != nil {
+			return rewrite, err
 		}
+		rewrite.BytesBefore += int64(len(line))
 		
-		newLineContent := strings.ReplaceAll(lineContent, searchStr, replaceStr)
-		
-		// Count replacements
-		count := (len(lineContent) - len(strings.ReplaceAll(lineContent, searchStr, ""))) / len(searchStr)
-		replacementCount += count
+		// Perform replacement on the line without its terminator, "\n" or
+		// "\r\n", exactly as the counting pass sees it; the terminator is
+		// written back untouched after the replaced body
+		lineContent := lineString(body)
 		
-		// Write the processed line
-		if _, writeErr := writer.WriteString(newLineContent); writeErr != nil {
-			return replacementCount, writeErr
+		matches := matcher.FindAll(lineContent)
+		if err := checkMatches(lineContent, matches); err != nil {
+			return rewrite, err
 		}
 		
-		// Add appropriate newline
-		if err == nil {
-			// Normal line - use system-appropriate newline
-			if _, writeErr := writer.WriteString(getNewline()); writeErr != nil {
-				return replacementCount, writeErr
+		// Count replacements
+		rewrite.Replaced += len(matches)
+		if rewrite.RuleCounts != nil {
+			for _, m := range matches {
+				rewrite.RuleCounts[m.Rule]++
 			}
 		}
+		if !changed {
+			changed = !sameReplacements(lineContent, matches)
+		}
 		
-		if err == io.EOF {
-			break
+		patched, cloneErr := clone.patch(line, matches)
+		if cloneErr != nil {
+			return rewrite, cloneErr
+		}
+		if patched {
+			rewrite.BytesAfter += int64(len(line))
+			verifier.add(line, matches)
+			continue
+		}
+		
+		// Splice the replacements straight into the output buffer. The
+		// terminator goes back exactly as it was read; bytes outside
+		// matches are never altered, whatever the platform.
+		n, writeErr := writeMatches(writer, line, matches)
+		rewrite.BytesAfter += int64(n)
+		if writeErr != nil {
+			return rewrite, writeErr
 		}
 	}
 	
 	if err := writer.Flush(); err != nil {
-		return replacementCount, err
+		return rewrite, err
+	}
+	
+	if !changed {
+		return rewrite, errUnchanged
+	}
+	
+	// Drop whatever the preallocation reserved beyond the actual output
+	if preallocated {
+		if f, ok := osFile(outputFile); ok {
+			if err := f.Truncate(rewrite.BytesAfter); err != nil {
+				return rewrite, err
+			}
+		}
 	}
 	
 	// Close files before renaming
 	inputFile.Close()
-	outputFile.Close()
+	if err := verifier.finish(fsys, outputFile, rewrite.BytesAfter, stats); err != nil {
+		return rewrite, err
+	}
 	
 	// Replace original file with temporary file
-	if err := os.Rename(tempFile, filePath); err != nil {
-		return replacementCount, err
+	rewrite.OwnerErr, err = commitTempFile(fsys, tempFile, filePath, opts)
+	if err != nil {
+		return rewrite, err
 	}
 	
-	return replacementCount, nil
+	return rewrite, nil
 }
 
-// getNewline returns the appropriate newline character for the current platform
-func getNewline() string {
-	// On Windows, use \r\n, otherwise use \n
-	if runtime.GOOS == "windows" {
-		return "\r\n"
-	}
-	return "\n"
+// formatDelta formats a size change in bytes with an explicit sign
+func formatDelta(delta int64) string {
+	return fmt.Sprintf("%+d 字节", delta)
 }
 
 // isHidden checks if a file or directory is hidden based on system attributes
-func isHidden(path string, info os.FileInfo) (bool, error) {
+func isHidden(path string, d fs.DirEntry) (bool, error) {
 	// Always skip current and parent directory entries
-	name := info.Name()
+	name := d.Name()
 	if name == "." || name == ".." {
 		return false, nil
 	}
 	
-	return isHiddenDir(path, info)
+	return isHiddenDir(path, d)
 }
 
 
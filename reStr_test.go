@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// CRLF 文件中整行匹配：计数和实际替换都不能受行尾 \r 影响
+func TestLineModeCRLF(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		in      string
+		want    string
+		matches int
+	}{
+		{"exact", &lineMatcher{search: "foo", replace: "baz"}, "foo\r\nbar foo\r\n", "baz\r\nbar foo\r\n", 1},
+		{"trim", &lineMatcher{search: "foo", replace: "baz", trim: true}, "  foo \r\nfoo", "baz\r\nbaz", 2},
+		{"mixed", &lineMatcher{search: "foo", replace: "baz"}, "foo\nfoo\r\nfoo", "baz\nbaz\r\nbaz", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted, replaced, out := countAndReplace(t, tt.in, tt.matcher)
+			if counted != tt.matches || replaced != tt.matches {
+				t.Errorf("计数 %d、替换 %d，应为 %d", counted, replaced, tt.matches)
+			}
+			if out != tt.want {
+				t.Errorf("替换后 %q，应为 %q", out, tt.want)
+			}
+		})
+	}
+}
+
+// 试验模式的计数与实际运行的替换数一致
+func TestLineModeCRLFRun(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "foo\r\nbar foo\r\n", 0o644)
+
+	trial := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "baz", LineMode: true, Trial: true})
+	real := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: "baz", LineMode: true})
+	if trial.Matches != 1 || real.Matches != 1 {
+		t.Errorf("试验 %d 处、实际 %d 处，应均为 1", trial.Matches, real.Matches)
+	}
+	if got := readTestFile(t, path); got != "baz\r\nbar foo\r\n" {
+		t.Errorf("替换后 %q", got)
+	}
+}
+
+// --regex 的 $ 在 CRLF 文件中同样匹配 "\r" 之前的行尾，计数与替换一致
+func TestRegexCRLF(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		in      string
+		want    string
+		matches int
+	}{
+		{"end", "foo$", "a foo\r\nfoo b\r\n", "a baz\r\nfoo b\r\n", 1},
+		{"mixed", "foo$", "foo\nfoo\r\nfoo", "baz\nbaz\r\nbaz", 3},
+		{"whole line", "^foo$", "foo\r\nfoo \r\n", "baz\r\nfoo \r\n", 1},
+		{"lone cr", "foo$", "foo\rfoo\r\n", "foo\rbaz\r\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := &regexMatcher{pattern: regexp.MustCompile(tt.pattern), replace: "baz"}
+			counted, replaced, out := countAndReplace(t, tt.in, matcher)
+			if counted != tt.matches || replaced != tt.matches {
+				t.Errorf("计数 %d、替换 %d，应为 %d", counted, replaced, tt.matches)
+			}
+			if out != tt.want {
+				t.Errorf("替换后 %q，应为 %q", out, tt.want)
+			}
+		})
+	}
+}
+
+// 试验模式报告的 --regex 'foo$' 匹配数与实际运行一致
+func TestRegexCRLFRun(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "a foo\r\nfoo b\r\n", 0o644)
+
+	pattern := regexp.MustCompile("foo$")
+	trial := runTest(t, &Config{SourceDir: dir, SourceString: "foo$", TargetString: "baz", Regex: true, pattern: pattern, Trial: true})
+	real := runTest(t, &Config{SourceDir: dir, SourceString: "foo$", TargetString: "baz", Regex: true, pattern: pattern})
+	if trial.Matches != 1 || real.Matches != 1 {
+		t.Errorf("试验 %d 处、实际 %d 处，应均为 1", trial.Matches, real.Matches)
+	}
+	if got := readTestFile(t, path); got != "a baz\r\nfoo b\r\n" {
+		t.Errorf("替换后 %q", got)
+	}
+}
+
+// 每个文件的行结束符原样保留：LF、CRLF、混合、单独的 CR 和没有结尾换行符的文件，
+// 只有匹配的文本改变
+func TestLineEndingsPreserved(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"lf", "foo one\nfoo two\nthree\n"},
+		{"crlf", "foo one\r\nfoo two\r\nthree\r\n"},
+		{"mixed", "foo lf\nfoo crlf\r\nfoo cr\rfoo\n\r\n\nfoo"},
+		{"cr only", "foo one\rfoo two\rthree\r"},
+		{"no final lf", "one\nfoo"},
+		{"no final crlf", "one\r\nfoo two"},
+		{"match before terminator", "a foo\r\nb foo\nc foo\r"},
+		{"blank lines", "\n\r\n\r\nfoo\r\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, to := range []string{"quux", "f", ""} {
+				dir := t.TempDir()
+				path := writeTestFile(t, dir, "a.txt", tt.in, 0o644)
+				result := runTest(t, &Config{SourceDir: dir, SourceString: "foo", TargetString: to})
+
+				if got, want := readTestFile(t, path), strings.ReplaceAll(tt.in, "foo", to); got != want {
+					t.Errorf("foo→%q: 替换后 %q，应为 %q", to, got, want)
+				}
+				if want := strings.Count(tt.in, "foo"); int(result.Matches) != want {
+					t.Errorf("foo→%q: 替换 %d 处，应为 %d 处", to, result.Matches, want)
+				}
+			}
+		})
+	}
+}
+
+// randomContent 生成含有匹配、LF、CRLF、单独的 CR 和无效 UTF-8 字节的内容，
+// 最后一行可能没有行结束符
+func randomContent(rng *rand.Rand) string {
+	pieces := []string{"foo", "fo", "o", "a", " ", "\t", "\n", "\r\n", "\r", "\xff", "\xc3", "\xe4\xbd", "中", "\x00"}
+	var sb strings.Builder
+	for range rng.Intn(200) {
+		sb.WriteString(pieces[rng.Intn(len(pieces))])
+	}
+	return sb.String()
+}
+
+// referenceReplace 逐行（去掉 "\n" 和它之前的 "\r"）应用匹配，
+// 行结束符原样保留，得到替换后应有的内容和匹配数
+func referenceReplace(content string, matcher Matcher) (string, int) {
+	var sb strings.Builder
+	count := 0
+	for len(content) > 0 {
+		line, rest, found := strings.Cut(content, "\n")
+		term := ""
+		if found {
+			term = "\n"
+		}
+		if strings.HasSuffix(line, "\r") {
+			line, term = line[:len(line)-1], "\r"+term
+		}
+		matches := matcher.FindAll(line)
+		count += len(matches)
+		sb.WriteString(applyMatches(line, matches) + term)
+		content = rest
+	}
+	return sb.String(), count
+}
+
+// 计数和替换两次扫描看到相同的行：匹配数一致，匹配之外的字节原样写回
+func TestCountAndRewriteAgree(t *testing.T) {
+	matchers := map[string]func() Matcher{
+		"literal":  func() Matcher { return newLiteralMatcher("foo", "X") },
+		"no-match": func() Matcher { return newLiteralMatcher("zzz", "X") },
+		"delete":   func() Matcher { return newLiteralMatcher("o", "") },
+		"regex":    func() Matcher { return &regexMatcher{pattern: regexp.MustCompile(`o+$`), replace: "!"} },
+		"anchor":   func() Matcher { return &anchoredMatcher{search: "a", replace: "A", start: true, end: true} },
+		"line":     func() Matcher { return &lineMatcher{search: "foo", replace: "bar", trim: true} },
+		"word":     func() Matcher { return &wordMatcher{inner: newLiteralMatcher("a", "b")} },
+	}
+
+	fixed := []string{
+		"foo\n", "foo\r\n", "foo", "foo\r", "a foo\r\nfoo\nfoo\r\rfoo", "\r\n\r\n", "",
+		"caf\xe9 foo \xff\xfe\n", "foo\xc3\r\n\xe4\xbd foo",
+	}
+	rng := rand.New(rand.NewSource(1))
+	inputs := append([]string(nil), fixed...)
+	for range 100 {
+		inputs = append(inputs, randomContent(rng))
+	}
+
+	for name, newMatcher := range matchers {
+		t.Run(name, func(t *testing.T) {
+			for _, in := range inputs {
+				want, wantCount := referenceReplace(in, newMatcher())
+				counted, replaced, out := countAndReplace(t, in, newMatcher())
+				if counted != wantCount || replaced != wantCount {
+					t.Errorf("%q: 计数 %d、替换 %d，应为 %d", in, counted, replaced, wantCount)
+				}
+				if out != want {
+					t.Errorf("%q: 替换后 %q，应为 %q", in, out, want)
+				}
+				if name == "literal" && out != string(bytes.ReplaceAll([]byte(in), []byte("foo"), []byte("X"))) {
+					t.Errorf("%q: 匹配之外的字节被改动: %q", in, out)
+				}
+			}
+		})
+	}
+}
+
+// wideTree 创建 dirs 个子目录、每个含 files 个空 .log 文件的目录树
+func wideTree(b *testing.B, dirs, files int) string {
+	b.Helper()
+	root := b.TempDir()
+	for i := range dirs {
+		sub := filepath.Join(root, fmt.Sprintf("d%03d", i))
+		if err := os.Mkdir(sub, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		for j := range files {
+			if err := os.WriteFile(filepath.Join(sub, fmt.Sprintf("f%04d.log", j)), nil, 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+// 宽目录树上 filepath.Walk（每个条目一次 Lstat）与 walkRoot 使用的 WalkDir 的对比。
+// 文件都被 --exclude 排除，不做内容检测，耗时只来自遍历本身。
+func BenchmarkWalk(b *testing.B) {
+	const dirs, files = 20, 1000
+	root := wideTree(b, dirs, files)
+	filter, err := newPathFilter(nil, []string{"*.log"})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Walk", func(b *testing.B) {
+		for b.Loop() {
+			skipped := 0
+			err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.Mode().IsRegular() && !filter.allowsFile(root, path) {
+					skipped++
+				}
+				return nil
+			})
+			if err != nil || skipped != dirs*files {
+				b.Fatalf("跳过 %d 个文件（%v），应为 %d", skipped, err, dirs*files)
+			}
+		}
+	})
+
+	b.Run("WalkDir", func(b *testing.B) {
+		config := &Config{SourceDir: root, FS: osFS{}, Reporter: silentReporter{}, filter: filter}
+		for b.Loop() {
+			result := &Result{}
+			if err := walkRoot(config, result, root, newWorkQueue(false)); err != nil {
+				b.Fatal(err)
+			}
+			if got := result.Skipped[SkipFiltered]; got != dirs*files {
+				b.Fatalf("跳过 %d 个文件，应为 %d", got, dirs*files)
+			}
+		}
+	})
+}
+
+// --clean-stale 只在实际替换时删除残留临时文件；试验模式、find/verify 和
+// --eol-report 只报告
+func TestCleanStaleReadOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(c *Config)
+		removed bool
+	}{
+		{"trial", func(c *Config) { c.Trial = true }, false},
+		{"find", func(c *Config) { c.Trial, c.searchOnly = true, true }, false},
+		{"eol-report", func(c *Config) { c.EOLReport = true }, false},
+		{"replace", func(c *Config) {}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+			stale := writeTestFile(t, dir, tempFilePrefix+"a.txt-1"+tempFileSuffix, "foo\n", 0o600)
+			old := time.Now().Add(-2 * time.Hour)
+			if err := os.Chtimes(stale, old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			config := &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", CleanStale: true, StaleAge: time.Hour}
+			tt.set(config)
+			result := runTest(t, config)
+
+			_, err := os.Stat(stale)
+			if removed := os.IsNotExist(err); removed != tt.removed {
+				t.Errorf("残留临时文件被删除: %v，应为 %v", removed, tt.removed)
+			}
+			wantRemoved := int32(0)
+			if tt.removed {
+				wantRemoved = 1
+			}
+			if result.StaleTemps != 1 || result.StaleRemoved != wantRemoved {
+				t.Errorf("发现 %d 个、删除 %d 个，应为 1 和 %d", result.StaleTemps, result.StaleRemoved, wantRemoved)
+			}
+		})
+	}
+}
+
+// snapshotTree 返回 root 下每个条目的内容、权限和修改时间
+func snapshotTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := fmt.Sprintf("%v %v", info.Mode(), info.ModTime().UnixNano())
+		if d.Type().IsRegular() {
+			entry += " " + readTestFile(t, path)
+		}
+		tree[path] = entry
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+// 试验运行不修改目录树中的任何字节，也不写运行历史
+func TestTrialLeavesTreeUnchanged(t *testing.T) {
+	defer func() { cfg = Config{} }()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "a\r\nb a\n", 0o644)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "sub/c.txt", "xa", 0o600)
+	before := snapshotTree(t, dir)
+
+	defaultConfig(t)
+	cfg.SourceDir = dir
+	cfg.Trial = true
+	cfg.NoLock = true
+	cfg.Reporter = silentReporter{}
+	if err := runApp(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	after := snapshotTree(t, dir)
+	for path, entry := range after {
+		if before[path] != entry {
+			t.Errorf("%s 被修改或创建", path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			t.Errorf("%s 被删除", path)
+		}
+	}
+}
+
+// 明确给出的空 --to 删除匹配，计数与试验模式一致；没有给出 --to 时仍然报错
+func TestEmptyTarget(t *testing.T) {
+	defer func() { cfg = Config{} }()
+	defaultConfig(t)
+	cfg.TargetString, cfg.targetSet = "", true
+	if err := validateReplaceFlags(); err != nil {
+		t.Fatalf("--to \"\": %v", err)
+	}
+	cfg.targetSet = false
+	if err := validateReplaceFlags(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("没有 --to 时错误 = %v，应属于 ErrInvalidConfig", err)
+	}
+
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.go", "//go:build legacy\n\npackage a // legacy legacy\n", 0o644)
+	trial := runTest(t, &Config{SourceDir: dir, SourceString: " legacy", TargetString: "", Trial: true})
+	real := runTest(t, &Config{SourceDir: dir, SourceString: " legacy", TargetString: ""})
+	if trial.Matches != 3 || real.Matches != 3 {
+		t.Errorf("试验 %d 处、实际 %d 处，应均为 3", trial.Matches, real.Matches)
+	}
+	if real.SizeDelta != -21 {
+		t.Errorf("大小变化 %d，应为 -21", real.SizeDelta)
+	}
+	if got := readTestFile(t, path); got != "//go:build\n\npackage a //\n" {
+		t.Errorf("删除后 %q", got)
+	}
+}
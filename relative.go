@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// pathDisplay 把输出中的路径显示为相对于源目录的形式（--relative），
+// 处理过程中仍然使用绝对路径。nil 时路径原样显示。
+type pathDisplay struct {
+	root    string
+	outside int32 // 不在源目录下、仍以绝对路径显示的路径数
+}
+
+// newPathDisplay 创建相对于 root 显示路径的 pathDisplay
+func newPathDisplay(root string) *pathDisplay {
+	return &pathDisplay{root: root}
+}
+
+// show 返回 path 相对于源目录的形式；不在源目录下的路径保持绝对路径
+func (d *pathDisplay) show(path string) string {
+	if d == nil {
+		return path
+	}
+	rel, err := filepath.Rel(d.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		atomic.AddInt32(&d.outside, 1)
+		return path
+	}
+	return rel
+}
+
+// text 去掉 s 中嵌入的源目录前缀，用于包含路径的错误信息
+func (d *pathDisplay) text(s string) string {
+	if d == nil {
+		return s
+	}
+	return strings.ReplaceAll(s, d.root+string(filepath.Separator), "")
+}
+
+// outsideCount 返回以绝对路径显示的路径数
+func (d *pathDisplay) outsideCount() int32 {
+	if d == nil {
+		return 0
+	}
+	return atomic.LoadInt32(&d.outside)
+}
+
+// displayError 是路径改写为相对形式的错误，errors.Is/As 仍作用于原来的错误
+type displayError struct {
+	err  error
+	text string
+}
+
+func (e *displayError) Error() string { return e.text }
+func (e *displayError) Unwrap() error { return e.err }
+
+// relativeReporter 把事件中的路径改写为相对路径后交给输出用的 Reporter。
+// 只包在输出外面：确认、预检和审阅界面收集的路径仍是绝对路径。
+type relativeReporter struct {
+	Reporter
+	display *pathDisplay
+}
+
+func (r relativeReporter) FileMatched(ev FileEvent) {
+	ev.Path = r.display.show(ev.Path)
+	r.Reporter.FileMatched(ev)
+}
+
+func (r relativeReporter) FileReplaced(ev FileEvent) {
+	ev.Path = r.display.show(ev.Path)
+	r.Reporter.FileReplaced(ev)
+}
+
+func (r relativeReporter) FileSkipped(path string, isDir bool, reason SkipReason) {
+	r.Reporter.FileSkipped(r.display.show(path), isDir, reason)
+}
+
+func (r relativeReporter) Notice(path, message string) {
+	r.Reporter.Notice(r.display.show(path), r.display.text(message))
+}
+
+func (r relativeReporter) FileScanned(path string, size int64, elapsed time.Duration) {
+	r.Reporter.FileScanned(r.display.show(path), size, elapsed)
+}
+
+func (r relativeReporter) FileSlow(f SlowFile) {
+	f.Path = r.display.show(f.Path)
+	r.Reporter.FileSlow(f)
+}
+
+func (r relativeReporter) FileLineEndings(path string, info EOLInfo) {
+	r.Reporter.FileLineEndings(r.display.show(path), info)
+}
+
+func (r relativeReporter) Error(path string, err error) {
+	r.Reporter.Error(r.display.show(path), &displayError{err: err, text: r.display.text(err.Error())})
+}
+
+// relativeDefault 判断 --relative 的默认值：源目录以相对路径给出时显示相对路径
+func relativeDefault(sourceDir string) bool {
+	return !filepath.IsAbs(sourceDir)
+}
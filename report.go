@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// teeReporter 把每个事件依次转发给多个 Reporter，用于在正常输出之外
+// 额外生成报告文件
+type teeReporter []Reporter
+
+func (t teeReporter) Start(config *Config) {
+	for _, r := range t {
+		r.Start(config)
+	}
+}
+
+func (t teeReporter) FileMatched(ev FileEvent) {
+	for _, r := range t {
+		r.FileMatched(ev)
+	}
+}
+
+func (t teeReporter) FileReplaced(ev FileEvent) {
+	for _, r := range t {
+		r.FileReplaced(ev)
+	}
+}
+
+func (t teeReporter) FileSkipped(path string, isDir bool, reason SkipReason) {
+	for _, r := range t {
+		r.FileSkipped(path, isDir, reason)
+	}
+}
+
+func (t teeReporter) Notice(path, message string) {
+	for _, r := range t {
+		r.Notice(path, message)
+	}
+}
+
+func (t teeReporter) FileScanned(path string, size int64, elapsed time.Duration) {
+	for _, r := range t {
+		r.FileScanned(path, size, elapsed)
+	}
+}
+
+func (t teeReporter) FileSlow(f SlowFile) {
+	for _, r := range t {
+		r.FileSlow(f)
+	}
+}
+
+func (t teeReporter) FileLineEndings(path string, info EOLInfo) {
+	for _, r := range t {
+		r.FileLineEndings(path, info)
+	}
+}
+
+func (t teeReporter) Error(path string, err error) {
+	for _, r := range t {
+		r.Error(path, err)
+	}
+}
+
+func (t teeReporter) Summary(config *Config, result *Result) {
+	for _, r := range t {
+		r.Summary(config, result)
+	}
+}
+
+// reportEntry 报告中的一个文件
+type reportEntry struct {
+	FileEvent
+	Replaced bool
+}
+
+// reportError 报告中的一个错误
+type reportError struct {
+	Path string
+	Err  string
+}
+
+// reportCollector 收集生成报告文件所需的事件，由各种报告格式嵌入
+type reportCollector struct {
+	silentReporter
+	path   string
+	mu     sync.Mutex
+	files  []reportEntry
+	errors []reportError
+}
+
+func (c *reportCollector) addFile(ev FileEvent, replaced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = append(c.files, reportEntry{FileEvent: ev, Replaced: replaced})
+}
+
+func (c *reportCollector) FileMatched(ev FileEvent)  { c.addFile(ev, false) }
+func (c *reportCollector) FileReplaced(ev FileEvent) { c.addFile(ev, true) }
+
+func (c *reportCollector) Error(path string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, reportError{Path: path, Err: err.Error()})
+}
+
+// sorted 按路径排序后返回收集到的文件和错误，使报告与工人的完成顺序无关
+func (c *reportCollector) sorted() ([]reportEntry, []reportError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sort.Slice(c.files, func(i, j int) bool { return c.files[i].Path < c.files[j].Path })
+	sort.SliceStable(c.errors, func(i, j int) bool { return c.errors[i].Path < c.errors[j].Path })
+	return c.files, c.errors
+}
+
+// write 把生成的报告原子地写入报告文件，失败时计为一个错误
+func (c *reportCollector) write(config *Config, result *Result, data []byte) {
+	if err := writeFileAtomic(config.FS, c.path, data); err != nil {
+		reportWriteError(result, c.path, err)
+	}
+}
+
+// reportWriteError 报告写入报告文件失败，计为一个错误
+func reportWriteError(result *Result, path string, err error) {
+	atomic.AddInt32(&result.Errors, 1)
+	fmt.Fprintf(os.Stderr, "写入报告 %s 时发生错误: %v\n", escapeControl(path), err)
+}
+
+// writeFileAtomic 先写入同一目录中的临时文件再重命名，
+// 读取者不会看到写了一半的文件
+func writeFileAtomic(fsys FileSystem, path string, data []byte) error {
+	temp, err := createTempFile(fsys, path, "")
+	if err != nil {
+		return err
+	}
+
+	if _, err := bytes.NewReader(data).WriteTo(temp); err != nil {
+		discardTemp(fsys, temp)
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		fsys.Remove(temp.Name())
+		return err
+	}
+
+	if err := fsys.Rename(temp.Name(), path); err != nil {
+		fsys.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
+
+// addReport 在 config.Reporter 之外增加一个报告文件；报告文件登记为本次
+// 运行的输出，不会被当作源文件处理
+func addReport(config *Config, path string, report Reporter) {
+	config.artifacts.addFile(path)
+	config.Reporter = teeReporter{report, config.Reporter}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTML 报告中每个文件差异的上限
+const (
+	htmlDiffLines = 200       // 最多显示的匹配行数
+	htmlDiffBytes = 256 << 10 // 差异内容的大致字节上限
+	htmlLineBytes = 4 << 10   // 超过此长度的行不显示内容
+)
+
+// htmlReport 生成单个独立的 HTML 页面，样式和脚本都内联在页面中。
+// 每个文件的差异在事件到达时立即写入临时文件，内存中只保留文件列表，
+// 汇总和文件表在结束时追加到页面末尾，再由 CSS 排到差异之前显示。
+type htmlReport struct {
+	silentReporter
+	path string
+
+	mu     sync.Mutex
+	config *Config
+	temp   TempFile
+	w      *bufio.Writer
+	err    error // 第一个写入错误，结束时报告
+	files  []htmlFile
+	errors []reportError
+}
+
+type htmlFile struct {
+	id       int
+	path     string
+	matches  int
+	delta    int64
+	replaced bool
+}
+
+// newHTMLReport 创建写入 path 的 HTML 报告
+func newHTMLReport(path string) *htmlReport {
+	return &htmlReport{path: path}
+}
+
+func (r *htmlReport) Start(config *Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = config
+	r.temp, r.err = createTempFile(config.FS, r.path, "")
+	if r.err != nil {
+		return
+	}
+	r.w = bufio.NewWriter(r.temp)
+	fmt.Fprintf(r.w, "<!DOCTYPE html>\n<html lang=\"zh-CN\">\n<head>\n<meta charset=\"utf-8\">\n<title>reStr 报告</title>\n<style>%s</style>\n</head>\n<body>\n<main>\n<section id=\"diffs\">\n<h2>差异</h2>\n", htmlStyle)
+}
+
+func (r *htmlReport) addFile(ev FileEvent, replaced bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := htmlFile{id: len(r.files), path: ev.Path, matches: ev.Matches, delta: ev.Delta, replaced: replaced}
+	r.files = append(r.files, f)
+	if r.w == nil {
+		return
+	}
+
+	fmt.Fprintf(r.w, "<details id=\"f%d\"><summary><code>%s</code> <span class=\"n\">%d</span></summary>\n<table class=\"diff\">\n", f.id, htmlText(ev.Path), ev.Matches)
+	shown, size := 0, 0
+	for _, lm := range ev.Preview {
+		if shown == htmlDiffLines || size >= htmlDiffBytes {
+			break
+		}
+		if len(lm.Matches) > 0 {
+			shown++
+		}
+		size += len(lm.Line)
+		r.w.WriteString(htmlDiffRow(lm))
+	}
+	r.w.WriteString("</table>\n")
+	if shown == htmlDiffLines || size >= htmlDiffBytes {
+		r.w.WriteString("<p class=\"more\">差异过大，其余部分未显示</p>\n")
+	}
+	r.w.WriteString("</details>\n")
+}
+
+func (r *htmlReport) FileMatched(ev FileEvent)  { r.addFile(ev, false) }
+func (r *htmlReport) FileReplaced(ev FileEvent) { r.addFile(ev, true) }
+
+func (r *htmlReport) Error(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, reportError{Path: path, Err: err.Error()})
+}
+
+func (r *htmlReport) Summary(config *Config, result *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.w != nil {
+		r.writeSummary(config, result)
+		r.err = r.w.Flush()
+	}
+	if r.temp != nil {
+		if err := r.temp.Close(); r.err == nil {
+			r.err = err
+		}
+		if r.err == nil {
+			r.err = config.FS.Rename(r.temp.Name(), r.path)
+		}
+		if r.err != nil {
+			config.FS.Remove(r.temp.Name())
+		}
+	}
+	if r.err != nil {
+		reportWriteError(result, r.path, r.err)
+	}
+}
+
+// writeSummary 在差异之后写入汇总、文件表和错误列表
+func (r *htmlReport) writeSummary(config *Config, result *Result) {
+	s := summarize(config, result)
+	w := r.w
+
+	w.WriteString("</section>\n<section id=\"summary\">\n<h1>reStr 报告</h1>\n")
+	if config.Trial {
+		w.WriteString("<p class=\"mode\">模式：试验（未修改任何文件）</p>\n")
+	} else {
+		w.WriteString("<p class=\"mode\">模式：实际替换</p>\n")
+	}
+	if config.SourceString != "" || config.TargetString != "" {
+		fmt.Fprintf(w, "<p><code>%s</code> → <code>%s</code></p>\n", htmlText(config.SourceString), htmlText(config.TargetString))
+	}
+
+	w.WriteString("<table class=\"counts\">\n")
+	row := func(name string, value interface{}) {
+		fmt.Fprintf(w, "<tr><th>%s</th><td>%v</td></tr>\n", htmlText(name), value)
+	}
+	row("发现文件数", s.FilesFound)
+	row("处理文件数", s.FilesProcessed)
+	row("匹配文件数", s.FilesMatches)
+	row("匹配替换数", s.Matches)
+	row("错误", s.Errors)
+	for reason := SkipReason(0); reason < skipReasonCount; reason++ {
+		if n := s.Skipped[reason.Key()]; n > 0 {
+			row("跳过（"+reason.String()+"）", n)
+		}
+	}
+	for _, rule := range s.Rules {
+		row(rule.Rule, rule.Matches)
+	}
+	if s.SizeDelta != 0 {
+		row("大小变化", formatDelta(s.SizeDelta))
+	}
+	row("耗时", result.Elapsed.Round(time.Millisecond))
+	w.WriteString("</table>\n</section>\n")
+
+	w.WriteString("<section id=\"files\">\n<h2>文件</h2>\n<p><input id=\"filter\" type=\"search\" placeholder=\"筛选路径\"> <label><input id=\"unified\" type=\"checkbox\"> 合并显示差异</label></p>\n")
+	w.WriteString("<table id=\"list\">\n<thead><tr><th data-k=\"s\">文件</th><th data-k=\"n\">匹配数</th><th data-k=\"n\">大小变化</th><th data-k=\"s\">状态</th></tr></thead>\n<tbody>\n")
+	for _, f := range r.files {
+		status := "将修改"
+		if f.replaced {
+			status = "已修改"
+		}
+		fmt.Fprintf(w, "<tr><td data-v=\"%s\"><a href=\"#f%d\"><code>%s</code></a></td><td data-v=\"%d\">%d</td><td data-v=\"%d\">%s</td><td data-v=\"%s\">%s</td></tr>\n",
+			htmlText(f.path), f.id, htmlText(f.path), f.matches, f.matches, f.delta, formatDelta(f.delta), status, status)
+	}
+	w.WriteString("</tbody>\n</table>\n")
+
+	if len(r.errors) > 0 {
+		w.WriteString("<h2>错误</h2>\n<ul>\n")
+		for _, e := range r.errors {
+			fmt.Fprintf(w, "<li><code>%s</code>: %s</li>\n", htmlText(e.Path), htmlText(e.Err))
+		}
+		w.WriteString("</ul>\n")
+	}
+	fmt.Fprintf(w, "</section>\n</main>\n<script>%s</script>\n</body>\n</html>\n", htmlScript)
+}
+
+// htmlDiffRow 把一个预览行渲染为差异表中的一行：行号、原文、替换后的内容。
+// 上下文行只有原文；过长的行不显示内容。
+func htmlDiffRow(lm lineMatch) string {
+	if len(lm.Line) > htmlLineBytes {
+		return fmt.Sprintf("<tr><td class=\"ln\">%d</td><td class=\"skip\" colspan=\"2\">（行过长，省略 %d 字节）</td></tr>\n", lm.LineNo, len(lm.Line))
+	}
+	if len(lm.Matches) == 0 {
+		text := htmlText(lm.Line)
+		return fmt.Sprintf("<tr class=\"ctx\"><td class=\"ln\">%d</td><td class=\"old\">%s</td><td class=\"new\">%s</td></tr>\n", lm.LineNo, text, text)
+	}
+
+	var old, replaced strings.Builder
+	last := 0
+	for _, m := range lm.Matches {
+		text := htmlText(lm.Line[last:m.Start])
+		old.WriteString(text)
+		replaced.WriteString(text)
+		fmt.Fprintf(&old, "<del>%s</del>", htmlText(lm.Line[m.Start:m.End]))
+		fmt.Fprintf(&replaced, "<ins>%s</ins>", htmlText(m.Replacement))
+		last = m.End
+	}
+	text := htmlText(lm.Line[last:])
+	old.WriteString(text)
+	replaced.WriteString(text)
+	return fmt.Sprintf("<tr><td class=\"ln\">%d</td><td class=\"old\">%s</td><td class=\"new\">%s</td></tr>\n", lm.LineNo, old.String(), replaced.String())
+}
+
+// htmlText 转义控制字符和 HTML 特殊字符
+func htmlText(s string) string {
+	return html.EscapeString(escapeControl(s))
+}
+
+const htmlStyle = `
+body{font:14px/1.5 system-ui,sans-serif;margin:0;color:#24292f}
+main{display:flex;flex-direction:column;padding:1em 2em}
+#summary{order:-2}#files{order:-1}
+table{border-collapse:collapse}
+th,td{padding:2px 8px;text-align:left;vertical-align:top}
+.counts td{text-align:right}
+#list th{cursor:pointer;border-bottom:1px solid #ccc}
+#list tr:nth-child(even){background:#f6f8fa}
+.mode{font-weight:bold}
+details{margin:4px 0;border:1px solid #d0d7de;border-radius:4px}
+summary{cursor:pointer;padding:4px 8px;background:#f6f8fa}
+summary .n{color:#57606a}
+.diff{width:100%;font:12px/1.4 ui-monospace,monospace;table-layout:fixed}
+.diff td{white-space:pre-wrap;word-break:break-all}
+.diff .ln{width:5em;color:#57606a;text-align:right}
+.diff tr:not(.ctx) .old{background:#ffebe9}
+.diff tr:not(.ctx) .new{background:#e6ffec}
+del{background:#ffc1c0;text-decoration:none}
+ins{background:#abf2bc;text-decoration:none}
+.skip,.more{color:#57606a;font-style:italic;padding:4px 8px}
+.unified .diff td{display:block;width:auto}
+.unified .diff .ln{text-align:left}
+.unified .diff tr:not(.ctx) .old::before{content:"- "}
+.unified .diff tr:not(.ctx) .new::before{content:"+ "}
+.unified .diff tr.ctx .new{display:none}
+`
+
+const htmlScript = `
+(function(){
+var list=document.getElementById("list"),body=list.tBodies[0];
+var heads=list.tHead.rows[0].cells;
+for(var i=0;i<heads.length;i++)(function(col,head){
+head.onclick=function(){
+var asc=head.getAttribute("data-asc")!=="1";head.setAttribute("data-asc",asc?"1":"0");
+var rows=Array.prototype.slice.call(body.rows),num=head.getAttribute("data-k")==="n";
+rows.sort(function(a,b){
+var x=a.cells[col].getAttribute("data-v"),y=b.cells[col].getAttribute("data-v");
+var c=num?x-y:(x<y?-1:x>y?1:0);return asc?c:-c;});
+rows.forEach(function(r){body.appendChild(r);});};
+})(i,heads[i]);
+document.getElementById("filter").oninput=function(){
+var q=this.value.toLowerCase();
+Array.prototype.forEach.call(body.rows,function(r){
+r.style.display=r.cells[0].getAttribute("data-v").toLowerCase().indexOf(q)<0?"none":"";});};
+document.getElementById("unified").onchange=function(){
+document.body.classList.toggle("unified",this.checked);};
+})();
+`
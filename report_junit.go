@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JUnit 失败信息中最多列出的匹配行
+const junitExcerptLines = 20
+
+// junitReport 生成 JUnit XML 报告：每个扫描过的文件是一个测试用例，
+// 仍包含源字符串的文件记为失败，便于 CI 直接展示
+type junitReport struct {
+	reportCollector
+	cases map[string]*junitCase
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string       `xml:"name,attr"`
+	Tests     int          `xml:"tests,attr"`
+	Failures  int          `xml:"failures,attr"`
+	Errors    int          `xml:"errors,attr"`
+	Time      string       `xml:"time,attr"`
+	Timestamp string       `xml:"timestamp,attr"`
+	Cases     []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// newJUnitReport 创建写入 path 的 JUnit XML 报告
+func newJUnitReport(path string) *junitReport {
+	return &junitReport{reportCollector: reportCollector{path: path}, cases: make(map[string]*junitCase)}
+}
+
+func (r *junitReport) FileScanned(path string, _ int64, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caseFor(path).Time = junitSeconds(elapsed)
+}
+
+// caseFor 返回路径对应的测试用例，调用方必须持有锁
+func (r *junitReport) caseFor(path string) *junitCase {
+	c := r.cases[path]
+	if c == nil {
+		c = &junitCase{Name: escapeControl(path), ClassName: "reStr", Time: junitSeconds(0)}
+		r.cases[path] = c
+	}
+	return c
+}
+
+func (r *junitReport) Summary(config *Config, result *Result) {
+	files, errors := r.sorted()
+
+	r.mu.Lock()
+	for _, f := range files {
+		var text strings.Builder
+		shown := 0
+		for _, lm := range f.Preview {
+			if len(lm.Matches) == 0 {
+				continue
+			}
+			if shown == junitExcerptLines {
+				text.WriteString("...\n")
+				break
+			}
+			fmt.Fprintf(&text, "%s:%d: %s\n", escapeControl(f.Path), lm.LineNo, escapeControl(lm.Line))
+			shown++
+		}
+		r.caseFor(f.Path).Failure = &junitMessage{
+			Message: fmt.Sprintf("包含 %d 处 %s", f.Matches, escapeControl(config.SourceString)),
+			Type:    "StillPresent",
+			Text:    text.String(),
+		}
+	}
+	for _, e := range errors {
+		c := r.caseFor(e.Path)
+		if c.Error == nil {
+			c.Error = &junitMessage{Message: escapeControl(e.Err), Type: "Error"}
+		}
+	}
+
+	suite := junitTestSuite{
+		Name:      "reStr verify",
+		Time:      junitSeconds(result.Elapsed),
+		Timestamp: time.Now().Add(-result.Elapsed).Format("2006-01-02T15:04:05"),
+	}
+	for _, c := range r.cases {
+		suite.Cases = append(suite.Cases, c)
+		switch {
+		case c.Error != nil:
+			suite.Errors++
+		case c.Failure != nil:
+			suite.Failures++
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(suite.Cases, func(i, j int) bool { return suite.Cases[i].Name < suite.Cases[j].Name })
+	suite.Tests = len(suite.Cases)
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		reportWriteError(result, r.path, err)
+		return
+	}
+	r.write(config, result, append([]byte(xml.Header), append(data, '\n')...))
+}
+
+// junitSeconds 把耗时格式化为 JUnit 使用的秒数
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
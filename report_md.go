@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// markdownReport 在运行结束时生成 Markdown 报告，便于粘贴到拉取请求的说明中
+type markdownReport struct {
+	reportCollector
+}
+
+// newMarkdownReport 创建写入 path 的 Markdown 报告
+func newMarkdownReport(path string) *markdownReport {
+	return &markdownReport{reportCollector{path: path}}
+}
+
+func (r *markdownReport) Summary(config *Config, result *Result) {
+	s := summarize(config, result)
+	files, errors := r.sorted()
+
+	var sb strings.Builder
+	sb.WriteString("# reStr 报告\n\n")
+	if config.Trial {
+		sb.WriteString("**模式：试验（未修改任何文件）**\n\n")
+	} else {
+		sb.WriteString("**模式：实际替换**\n\n")
+	}
+	if config.SourceString != "" || config.TargetString != "" {
+		fmt.Fprintf(&sb, "%s → %s\n\n", mdCode(config.SourceString), mdCode(config.TargetString))
+	}
+
+	sb.WriteString("| 项目 | 数量 |\n|---|---:|\n")
+	fmt.Fprintf(&sb, "| 发现文件数 | %d |\n", s.FilesFound)
+	fmt.Fprintf(&sb, "| 处理文件数 | %d |\n", s.FilesProcessed)
+	fmt.Fprintf(&sb, "| 匹配文件数 | %d |\n", s.FilesMatches)
+	fmt.Fprintf(&sb, "| 匹配替换数 | %d |\n", s.Matches)
+	fmt.Fprintf(&sb, "| 错误 | %d |\n", s.Errors)
+	for reason := SkipReason(0); reason < skipReasonCount; reason++ {
+		if n := s.Skipped[reason.Key()]; n > 0 {
+			fmt.Fprintf(&sb, "| 跳过（%s） | %d |\n", reason, n)
+		}
+	}
+	for _, rule := range s.Rules {
+		fmt.Fprintf(&sb, "| %s | %d |\n", mdCell(mdCode(rule.Rule)), rule.Matches)
+	}
+	if s.SizeDelta != 0 {
+		fmt.Fprintf(&sb, "| 大小变化 | %s |\n", formatDelta(s.SizeDelta))
+	}
+	fmt.Fprintf(&sb, "| 耗时 | %v |\n", result.Elapsed.Round(time.Millisecond))
+
+	if groups := mdGroups(s.Directories); groups != "" {
+		sb.WriteString(groups)
+	}
+
+	if len(files) > 0 {
+		if config.Trial {
+			sb.WriteString("\n## 将被修改的文件\n\n")
+		} else {
+			sb.WriteString("\n## 已修改的文件\n\n")
+		}
+		sb.WriteString("| 文件 | 匹配数 | 大小变化 |\n|---|---:|---:|\n")
+		for _, f := range files {
+			fmt.Fprintf(&sb, "| %s | %d | %s |\n", mdCell(mdCode(f.Path)), f.Matches, formatDelta(f.Delta))
+		}
+
+		for _, f := range files {
+			preview := firstMatchLines(f.Preview, maxPreviewLines)
+			if len(preview) == 0 {
+				continue
+			}
+			fmt.Fprintf(&sb, "\n<details>\n<summary>%s</summary>\n\n", htmlText(f.Path))
+			sb.WriteString(mdFence(preview))
+			sb.WriteString("</details>\n")
+		}
+	}
+
+	if len(errors) > 0 {
+		sb.WriteString("\n## 错误\n\n")
+		for _, e := range errors {
+			fmt.Fprintf(&sb, "- %s: %s\n", mdCode(e.Path), escapeControl(e.Err))
+		}
+	}
+
+	r.write(config, result, []byte(sb.String()))
+}
+
+// mdCode 把文本渲染为代码段，文本中含有反引号时使用更长的定界符
+func mdCode(s string) string {
+	s = escapeControl(s)
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") || s == "" {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// mdCell 转义表格单元格中的竖线，代码段内的竖线同样需要转义
+func mdCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// mdFence 把预览行渲染为 diff 代码块，- 为原文，+ 为替换后的内容
+func mdFence(preview []lineMatch) string {
+	var body strings.Builder
+	for _, lm := range preview {
+		line := escapeControl(lm.Line)
+		if len(lm.Matches) == 0 {
+			fmt.Fprintf(&body, " %d: %s\n", lm.LineNo, line)
+			continue
+		}
+		fmt.Fprintf(&body, "-%d: %s\n", lm.LineNo, line)
+		fmt.Fprintf(&body, "+%d: %s\n", lm.LineNo, escapeControl(applyMatches(lm.Line, lm.Matches)))
+	}
+
+	fence := "```"
+	for strings.Contains(body.String(), fence) {
+		fence += "`"
+	}
+	return fence + "diff\n" + body.String() + fence + "\n"
+}
+
+// mdGroups 生成第一级目录的汇总表，没有匹配或错误的目录不列出
+func mdGroups(groups []*DirGroup) string {
+	var sb strings.Builder
+	for _, g := range groups {
+		if g.FilesMatched == 0 && g.Errors == 0 {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteString("\n## 按目录\n\n| 目录 | 文件数 | 匹配文件数 | 匹配数 | 错误 |\n|---|---:|---:|---:|---:|\n")
+		}
+		fmt.Fprintf(&sb, "| %s | %d | %d | %d | %d |\n", mdCell(mdCode(g.Path)), g.Files, g.FilesMatched, g.Matches, g.Errors)
+	}
+	return sb.String()
+}
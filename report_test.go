@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 源目录中的报告文件（包括上一次运行留下的）不会被当作输入处理
+func TestReportInsideSourceTree(t *testing.T) {
+	for _, trial := range []bool{true, false} {
+		dir := t.TempDir()
+		path := writeTestFile(t, dir, "a.txt", "foo\n", 0o644)
+		report := writeTestFile(t, dir, "report.md", "上次运行: foo\n", 0o644)
+
+		config := &Config{SourceDir: dir, SourceString: "foo", TargetString: "bar", Trial: trial, Workers: 1, NoLock: true, Reporter: silentReporter{}}
+		addReport(config, report, newMarkdownReport(report))
+		result, err := Run(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.FilesMatches != 1 || result.Skipped[SkipArtifact] != 1 {
+			t.Errorf("trial=%v: 匹配 %d 个文件、跳过自身输出 %d 个，应为 1 和 1", trial, result.FilesMatches, result.Skipped[SkipArtifact])
+		}
+		want := "foo\n"
+		if !trial {
+			want = "bar\n"
+		}
+		if got := readTestFile(t, path); got != want {
+			t.Errorf("trial=%v: a.txt = %q，应为 %q", trial, got, want)
+		}
+		got := readTestFile(t, report)
+		if strings.Contains(got, "上次运行") || !strings.Contains(got, "a.txt") || strings.Contains(got, filepath.Base(report)) {
+			t.Errorf("trial=%v: 报告应只列出 a.txt:\n%s", trial, got)
+		}
+	}
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Reporter 接收处理过程中的事件并负责全部输出，使处理逻辑与输出格式解耦。
+// 除 Start 和 Summary 外，其余方法会被多个工人并发调用，实现必须自行同步。
+type Reporter interface {
+	// Start 在处理开始前调用一次
+	Start(config *Config)
+	// FileMatched 试验模式下文件存在匹配（未修改文件）
+	FileMatched(ev FileEvent)
+	// FileReplaced 文件已完成替换
+	FileReplaced(ev FileEvent)
+	// FileSkipped 文件或目录被跳过
+	FileSkipped(path string, isDir bool, reason SkipReason)
+	// Notice 其他提示信息（如发现或清理残留临时文件），仅详细模式关心
+	Notice(path, message string)
+	// FileScanned 一个文件处理完毕（无论是否匹配），size 为遍历时的文件大小，elapsed 为处理耗时
+	FileScanned(path string, size int64, elapsed time.Duration)
+	// FileSlow 文件处理耗时超过 --slow-threshold，在该文件处理完毕时立即调用
+	FileSlow(f SlowFile)
+	// FileLineEndings 换行符报告模式下统计了一个文件
+	FileLineEndings(path string, info EOLInfo)
+	// Error 处理某个路径时发生错误
+	Error(path string, err error)
+	// Summary 在处理结束后调用一次
+	Summary(config *Config, result *Result)
+}
+
+// FileEvent 描述一个匹配或已替换的文件
+type FileEvent struct {
+	Path        string
+	Matches     int
+	Preview     []lineMatch
+	Delta       int64 // 大小变化（试验模式下为预计值）
+	BytesBefore int64 // 仅在实际替换时有效
+	BytesAfter  int64
+	Diff        string // --diff 的统一差异输出，已按需着色
+}
+
+// 输出格式
+const (
+	FormatConsole   = "console"
+	FormatJSON      = "json"
+	FormatPorcelain = "porcelain"
+	FormatSilent    = "silent"
+)
+
+// newReporter 根据输出格式创建 Reporter
+func newReporter(format string, w io.Writer, verbose bool) (Reporter, error) {
+	switch format {
+	case FormatConsole:
+		return newConsoleReporter(w, verbose), nil
+	case FormatJSON:
+		return newJSONReporter(w), nil
+	case FormatPorcelain:
+		return newPorcelainReporter(w), nil
+	case FormatSilent:
+		return silentReporter{}, nil
+	}
+	return nil, fmt.Errorf("无效的输出格式: %s（可选 console|json|porcelain|silent）", format)
+}
+
+// RuleSummary 单条规则的匹配统计
+type RuleSummary struct {
+	Rule    string `json:"rule"`
+	Matches int32  `json:"matches"`
+}
+
+// RunSummary 是运行结束时 Result 的快照，供各种报告格式共用
+type RunSummary struct {
+	Trial           bool             `json:"trial"`
+	FilesFound      int32            `json:"filesFound"`
+	FilesProcessed  int32            `json:"filesProcessed"`
+	FilesMatches    int32            `json:"filesMatched"`
+	Matches         int32            `json:"matches"`
+	Errors          int32            `json:"errors"`
+	Skipped         map[string]int32 `json:"skipped,omitempty"`
+	Rules           []RuleSummary    `json:"rules,omitempty"`
+	EOL             map[string]int32 `json:"eol,omitempty"`
+	NoFinalNewline  int32            `json:"noFinalNewline,omitempty"`
+	SizeDelta       int64            `json:"sizeDelta"`
+	BytesRead       int64            `json:"bytesRead"`
+	BytesWritten    int64            `json:"bytesWritten"`
+	FileOpens       int64            `json:"fileOpens"`
+	ElapsedMs       int64            `json:"elapsedMs"`
+	StaleTemps      int32            `json:"staleTemps,omitempty"`
+	StaleRemoved    int32            `json:"staleRemoved,omitempty"`
+	Backups         int32            `json:"backups,omitempty"`
+	CapReached      bool             `json:"capReached,omitempty"`
+	MaxFilesReached bool             `json:"maxFilesReached,omitempty"`
+	Sample          *SampleSummary   `json:"sample,omitempty"`
+	FailFast        *FailFastSummary `json:"failFast,omitempty"`
+	Filesystems     []FilesystemInfo `json:"filesystems,omitempty"`
+	SlowFiles       []SlowFile       `json:"slowFiles,omitempty"`
+	Directories     []*DirGroup      `json:"directories,omitempty"`
+}
+
+// summarize 生成 Result 的快照
+func summarize(config *Config, result *Result) RunSummary {
+	s := RunSummary{
+		Trial:           config.Trial,
+		FilesFound:      atomic.LoadInt32(&result.FilesFound),
+		FilesProcessed:  atomic.LoadInt32(&result.FilesProcessed),
+		FilesMatches:    atomic.LoadInt32(&result.FilesMatches),
+		Matches:         atomic.LoadInt32(&result.Matches),
+		Errors:          atomic.LoadInt32(&result.Errors),
+		SizeDelta:       atomic.LoadInt64(&result.SizeDelta),
+		BytesRead:       atomic.LoadInt64(&result.IO.BytesRead),
+		BytesWritten:    atomic.LoadInt64(&result.IO.BytesWritten),
+		FileOpens:       atomic.LoadInt64(&result.IO.FileOpens),
+		ElapsedMs:       result.Elapsed.Milliseconds(),
+		StaleTemps:      atomic.LoadInt32(&result.StaleTemps),
+		StaleRemoved:    atomic.LoadInt32(&result.StaleRemoved),
+		Backups:         atomic.LoadInt32(&result.Backups),
+		CapReached:      capReached(result),
+		MaxFilesReached: maxFilesReached(result),
+		NoFinalNewline:  atomic.LoadInt32(&result.NoFinalNewline),
+		FailFast:        summarizeFailFast(result),
+		Filesystems:     config.filesystems,
+		SlowFiles:       result.slow.list(),
+		Directories:     result.groups.summary(),
+	}
+
+	// Paths in the summary follow --relative like the per-file output
+	if config.display != nil {
+		if s.FailFast != nil {
+			s.FailFast.Path = config.display.show(s.FailFast.Path)
+		}
+		for i := range s.SlowFiles {
+			s.SlowFiles[i].Path = config.display.show(s.SlowFiles[i].Path)
+		}
+		s.Filesystems = append([]FilesystemInfo(nil), s.Filesystems...)
+		for i := range s.Filesystems {
+			s.Filesystems[i].Path = config.display.show(s.Filesystems[i].Path)
+		}
+	}
+
+	for style := EOLStyle(0); style < eolStyleCount; style++ {
+		if n := atomic.LoadInt32(&result.EOLStyles[style]); n > 0 {
+			if s.EOL == nil {
+				s.EOL = make(map[string]int32)
+			}
+			s.EOL[style.Key()] = n
+		}
+	}
+
+	for reason := SkipReason(0); reason < skipReasonCount; reason++ {
+		if n := atomic.LoadInt32(&result.Skipped[reason]); n > 0 {
+			if s.Skipped == nil {
+				s.Skipped = make(map[string]int32)
+			}
+			s.Skipped[reason.Key()] = n
+		}
+	}
+
+	for i, rule := range ruleNames(config) {
+		s.Rules = append(s.Rules, RuleSummary{Rule: rule, Matches: atomic.LoadInt32(&result.RuleMatches[i])})
+	}
+
+	s.Sample = summarizeSample(config, s)
+
+	return s
+}
+
+// consoleReporter 面向终端的默认输出
+type consoleReporter struct {
+	out     *outputSink
+	verbose bool
+	unit    string // 匹配数的单位，随模式变化
+	color   bool
+	preview bool // 试验或详细模式下显示匹配行
+
+	// 试验模式下详细输出的文件数上限（--preview-limit），超出后只计数
+	limit     int32
+	shown     int32
+	truncated int32
+
+	maxShown int // 每个文件最多输出的匹配行数
+
+	profile fileProfile
+}
+
+// newConsoleReporter 创建终端输出
+func newConsoleReporter(w io.Writer, verbose bool) *consoleReporter {
+	return &consoleReporter{out: newOutputSink(w), verbose: verbose, unit: "处字符串"}
+}
+
+func (r *consoleReporter) Start(config *Config) {
+	r.unit = matchUnit(config)
+	r.color = config.color
+	r.preview = config.Trial || config.Verbose
+	r.limit = int32(config.PreviewLimit)
+	r.profile.top = config.ProfileFiles
+	r.maxShown = consoleLines(config)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "开始字符串替换...:\n")
+	if name, _ := fileListSource(config); name != "" {
+		if name == "-" {
+			name = "标准输入"
+		}
+		fmt.Fprintf(&sb, "  文件列表: %s\n", name)
+	} else if len(config.Paths) > 0 {
+		paths := make([]string, len(config.Paths))
+		for i, p := range config.Paths {
+			paths[i] = config.display.show(p)
+		}
+		fmt.Fprintf(&sb, "  源路径: %s\n", strings.Join(paths, ", "))
+	} else {
+		fmt.Fprintf(&sb, "  源目录: %s\n", config.SourceDir)
+	}
+	switch {
+	case config.Detab > 0:
+		fmt.Fprintf(&sb, "  缩进转换: 制表符 → 空格 (制表位宽度: %d)\n", config.Detab)
+	case config.EOLReport:
+		fmt.Fprintf(&sb, "  换行符报告: 只读，不修改文件\n")
+	case config.EOL != EOLNone:
+		fmt.Fprintf(&sb, "  换行符转换: → %s\n", formatEOLStyle(config.EOL))
+	case config.Retab > 0:
+		fmt.Fprintf(&sb, "  缩进转换: 空格 → 制表符 (制表位宽度: %d)\n", config.Retab)
+	case trimOnly(config):
+	case config.Map != "":
+		defaults := mapOptions{Regex: config.Regex, IgnoreCase: config.IgnoreCase, Word: config.Word}
+		fmt.Fprintf(&sb, "  替换规则: %d 条，来自 %s%s\n", len(config.mapRules), config.Map, defaults.label())
+	default:
+		label, notes := "源字符串", ""
+		if config.Regex {
+			label = "正则表达式"
+		}
+		if config.IgnoreWhitespace {
+			notes += " (忽略空白差异)"
+		}
+		if config.IgnoreCase {
+			notes += " (忽略大小写)"
+		}
+		if config.Word {
+			notes += " (整词)"
+		}
+		fmt.Fprintf(&sb, "  %s: '%s'%s\n", label, config.SourceString, notes)
+		if config.TargetString == "" {
+			fmt.Fprintf(&sb, "  目标字符串: '' (删除匹配)\n")
+		} else {
+			fmt.Fprintf(&sb, "  目标字符串: '%s'\n", config.TargetString)
+		}
+	}
+	fmt.Fprintf(&sb, "  工人数: %d\n", config.Workers)
+	if config.Sequential {
+		fmt.Fprintf(&sb, "  顺序模式: 按遍历顺序处理\n")
+	}
+	fmt.Fprintf(&sb, "  试验模式: %v\n", config.Trial)
+	if config.TrimTrailing {
+		fmt.Fprintf(&sb, "  清理行尾空白: %v (保留 Markdown 换行: %v)\n", config.TrimTrailing, config.KeepMDBreaks)
+	}
+	if config.journal != nil {
+		fmt.Fprintf(&sb, "  撤销日志: %s\n", config.journal.dir)
+	}
+	if config.NoRecursive {
+		fmt.Fprintf(&sb, "  非递归模式: 只处理源目录下的文件\n")
+	}
+	if config.LineMode {
+		fmt.Fprintf(&sb, "  整行模式: %v (忽略首尾空白: %v)\n", config.LineMode, config.Trim)
+	}
+	if config.Swap {
+		fmt.Fprintf(&sb, "  互换模式: %v\n", config.Swap)
+	}
+	if config.MaxTotal > 0 {
+		fmt.Fprintf(&sb, "  替换总数上限: %d\n", config.MaxTotal)
+	}
+	if config.MaxFiles > 0 {
+		fmt.Fprintf(&sb, "  文件数上限: %d\n", config.MaxFiles)
+	}
+	if config.Sample > 0 {
+		fmt.Fprintf(&sb, "  抽样: %g%% 的候选文件 (种子: %d)\n", config.Sample, config.Seed)
+	}
+	if config.Nth > 0 {
+		fmt.Fprintf(&sb, "  每行只替换第 %d 处匹配\n", config.Nth)
+	} else if config.occurrences > 0 {
+		fmt.Fprintf(&sb, "  每行只替换前 %d 处匹配\n", config.occurrences)
+	}
+	if config.Anchor != AnchorNone {
+		fmt.Fprintf(&sb, "  锚定方式: %s (允许缩进: %v)\n", config.Anchor, config.AllowIndent)
+	}
+	if w := config.window; w.active() {
+		fmt.Fprintf(&sb, "  修改时间: %s\n", w)
+	}
+	sb.WriteString(paint(formatNetworkWarning(config.filesystems), ansiMatch, r.color))
+	sb.WriteString("\n")
+	r.out.Print(sb.String())
+	r.out.Flush()
+}
+
+// writeFile 以一个完整的块输出文件的匹配详情，避免与其他工人交错
+func (r *consoleReporter) writeFile(ev FileEvent, final string) {
+	var sb strings.Builder
+	if r.verbose {
+		fmt.Fprintf(&sb, "发现 %4d %s: %s\n", ev.Matches, r.unit, escapeControl(ev.Path))
+	}
+	// Reports may have collected more lines than the console shows
+	preview := ev.Preview
+	if !r.preview || ev.Diff != "" {
+		preview = nil
+	} else {
+		preview = firstMatchLines(preview, r.maxShown)
+	}
+	shown := 0
+	for _, lm := range preview {
+		shown += len(lm.Matches)
+		line := escapeControl(formatHighlight(lm.Line, lm.Matches))
+		if r.color {
+			line = formatHighlightColor(lm.Line, lm.Matches)
+		}
+		fmt.Fprintf(&sb, "  %s:%s: %s\n", paint(escapeControl(ev.Path), ansiPath, r.color), paint(strconv.Itoa(lm.LineNo), ansiLineNo, r.color), line)
+	}
+	if len(preview) > 0 && ev.Matches > shown {
+		fmt.Fprintf(&sb, "  … 该文件还有 %d %s未显示\n", ev.Matches-shown, r.unit)
+	}
+	sb.WriteString(ev.Diff)
+	sb.WriteString(final)
+	r.out.Print(sb.String())
+}
+
+// firstMatchLines 截取预览，只保留前 n 个匹配行及其之前的上下文行
+func firstMatchLines(preview []lineMatch, n int) []lineMatch {
+	for i, lm := range preview {
+		if len(lm.Matches) == 0 {
+			continue
+		}
+		if n--; n == 0 {
+			return preview[:i+1]
+		}
+	}
+	return preview
+}
+
+func (r *consoleReporter) FileMatched(ev FileEvent) {
+	if r.limit > 0 && atomic.AddInt32(&r.shown, 1) > r.limit {
+		atomic.AddInt32(&r.truncated, 1)
+		return
+	}
+	r.writeFile(ev, fmt.Sprintf("[试验] 替换 %d %s: %s (预计 %s)\n", ev.Matches, r.unit, escapeControl(ev.Path), formatDelta(ev.Delta)))
+}
+
+func (r *consoleReporter) FileReplaced(ev FileEvent) {
+	r.writeFile(ev, fmt.Sprintf("替换 %d %s: %s (%d → %d 字节, %s)\n", ev.Matches, r.unit, escapeControl(ev.Path), ev.BytesBefore, ev.BytesAfter, formatDelta(ev.Delta)))
+}
+
+func (r *consoleReporter) FileLineEndings(path string, info EOLInfo) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-6s %s", info.Style, escapeControl(path))
+	if info.Style == EOLStyleMixed {
+		fmt.Fprintf(&sb, " (LF %d, CRLF %d, CR %d)", info.LF, info.CRLF, info.CR)
+	}
+	if !info.FinalNewline {
+		sb.WriteString(" [缺少结尾换行]")
+	}
+	sb.WriteString("\n")
+	r.out.Print(sb.String())
+}
+
+func (r *consoleReporter) FileSkipped(path string, isDir bool, reason SkipReason) {
+	// Always worth knowing: the file still needs the replacement
+	if reason == SkipNoSpace {
+		r.out.Printf("跳过（磁盘空间不足，未修改）: %s\n", escapeControl(path))
+		return
+	}
+
+	// Only happens when walking from / or a container root
+	if reason == SkipVirtualFS {
+		r.out.Printf("跳过虚拟文件系统: %s\n", escapeControl(path))
+		return
+	}
+
+	if !r.verbose {
+		return
+	}
+
+	var what string
+	switch reason {
+	case SkipHidden:
+		what = "隐藏文件"
+		if isDir {
+			what = "隐藏目录"
+		}
+	case SkipBinary:
+		what = "二进制文件"
+	case SkipVCS:
+		what = "版本控制目录"
+	case SkipArtifact:
+		what = "本次运行的输出文件"
+	case SkipSystem:
+		what = "系统文件"
+		if isDir {
+			what = "系统目录"
+		}
+	case SkipUnwritable:
+		what = "不可写的文件"
+	case SkipUnchanged:
+		what = "替换后内容不变的文件"
+	case SkipAge:
+		what = "修改时间不在范围内的文件"
+	case SkipFiltered:
+		what = "被 --include/--exclude 排除的文件"
+		if isDir {
+			what = "被 --exclude 排除的目录"
+		}
+	case SkipVanished:
+		what = "处理时已被删除的文件"
+		if isDir {
+			what = "遍历时已被删除的目录"
+		}
+	case SkipBackup:
+		what = "备份文件"
+	case SkipSymlink:
+		what = "符号链接（未使用 --follow-symlinks）"
+	default:
+		what = reason.String()
+	}
+	r.out.Printf("跳过%s: %s\n", what, escapeControl(path))
+}
+
+func (r *consoleReporter) Notice(path, message string) {
+	if r.verbose {
+		r.out.Printf("%s: %s\n", message, escapeControl(path))
+	}
+}
+
+func (r *consoleReporter) FileScanned(path string, size int64, elapsed time.Duration) {
+	r.profile.add(path, size, elapsed)
+}
+
+// FileSlow is always shown: a stuck file is worth knowing about mid-run
+func (r *consoleReporter) FileSlow(f SlowFile) {
+	r.out.Print(paint("慢文件: "+formatSlowFile(f), ansiMatch, r.color) + "\n")
+}
+
+func (r *consoleReporter) Error(path string, err error) {
+	if r.verbose {
+		log.Print(escapeControl(err.Error()))
+	}
+}
+
+func (r *consoleReporter) Summary(config *Config, result *Result) {
+	s := summarize(config, result)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n最终结果:\n")
+	fmt.Fprintf(&sb, "  发现文件数: %d\n", s.FilesFound)
+	fmt.Fprintf(&sb, "  处理文件数: %d\n", s.FilesProcessed)
+	fmt.Fprintf(&sb, "  匹配文件数: %d\n", s.FilesMatches)
+	fmt.Fprintf(&sb, "  匹配替换数: %d\n", s.Matches)
+	fmt.Fprintf(&sb, "  错误: %d\n", s.Errors)
+
+	fmt.Fprintf(&sb, "  耗时: %v\n", result.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(&sb, "  I/O: %s\n", formatIOStats(&result.IO, result.Elapsed))
+
+	if s.SizeDelta != 0 {
+		fmt.Fprintf(&sb, "  大小变化: %s\n", formatDelta(s.SizeDelta))
+	}
+
+	if skipped := formatSkipped(result); skipped != "" {
+		fmt.Fprintf(&sb, "  跳过: %s\n", skipped)
+	}
+
+	for _, rule := range s.Rules {
+		fmt.Fprintf(&sb, "  %s: %d\n", rule.Rule, rule.Matches)
+	}
+
+	if s.Sample != nil {
+		fmt.Fprintf(&sb, "  抽样: %d / %d 个候选文件 (%g%%, 种子 %d)\n", s.Sample.Sampled, s.Sample.Candidates, s.Sample.Percent, s.Sample.Seed)
+		fmt.Fprintf(&sb, "  估计总数: 匹配文件约 %d, 匹配约 %d\n", s.Sample.EstFilesMatched, s.Sample.EstMatches)
+	}
+
+	if config.EOLReport {
+		fmt.Fprintf(&sb, "  换行符: %s\n", formatEOLStyles(result))
+		fmt.Fprintf(&sb, "  缺少结尾换行: %d\n", s.NoFinalNewline)
+	}
+
+	if s.Backups > 0 {
+		fmt.Fprintf(&sb, "  备份文件: %d (后缀 %s)\n", s.Backups, config.Backup)
+	}
+
+	if s.StaleTemps > 0 {
+		if config.CleanStale && readOnlyRun(config) {
+			fmt.Fprintf(&sb, "  残留临时文件: %d (只读运行，未删除)\n", s.StaleTemps)
+		} else {
+			fmt.Fprintf(&sb, "  残留临时文件: %d (已删除 %d)\n", s.StaleTemps, s.StaleRemoved)
+		}
+		if !config.CleanStale {
+			fmt.Fprintf(&sb, "\n警告：发现 %d 个之前运行遗留的临时文件，可使用 --clean-stale 清理.\n", s.StaleTemps)
+		}
+	}
+
+	if s.CapReached {
+		fmt.Fprintf(&sb, "\n注意：已达到替换总数上限 %d，其余文件未再替换.\n", config.MaxTotal)
+	}
+
+	if s.FailFast != nil {
+		fmt.Fprintf(&sb, "\n注意：处理 %s 时出错，已按 --fail-fast 中止，其余文件未处理.\n", escapeControl(s.FailFast.Path))
+	}
+
+	if s.MaxFilesReached {
+		fmt.Fprintf(&sb, "\n注意：已达到文件数上限 %d，其余文件未处理.\n", config.MaxFiles)
+	}
+
+	sb.WriteString(formatGroups(s.Directories))
+
+	if len(s.SlowFiles) > 0 {
+		sb.WriteString(formatSlowFiles(s.SlowFiles, config.SlowThreshold))
+	}
+
+	if profile := r.profile.summary(); profile != nil {
+		sb.WriteString(formatProfile(profile))
+	}
+
+	if n := atomic.LoadInt32(&r.truncated); n > 0 {
+		fmt.Fprintf(&sb, "\n注意：详细输出在 %d 个文件后截断（另有 %d 个文件未列出），汇总包含全部文件.\n", r.limit, n)
+	}
+
+	if config.display.outsideCount() > 0 {
+		fmt.Fprintf(&sb, "\n注意：部分路径不在源目录 %s 下，仍以绝对路径显示.\n", escapeControl(config.SourceDir))
+	}
+
+	if config.Trial {
+		fmt.Fprintf(&sb, "\n注意：本次运行在试验模式下，未实际执行替换操作.\n")
+	}
+
+	r.out.Print(sb.String())
+	r.out.Flush()
+}
+
+// silentReporter 不输出任何内容
+type silentReporter struct{}
+
+func (silentReporter) Start(*Config)                            {}
+func (silentReporter) FileMatched(FileEvent)                    {}
+func (silentReporter) FileReplaced(FileEvent)                   {}
+func (silentReporter) FileSkipped(string, bool, SkipReason)     {}
+func (silentReporter) Notice(string, string)                    {}
+func (silentReporter) FileScanned(string, int64, time.Duration) {}
+func (silentReporter) FileSlow(SlowFile)                        {}
+func (silentReporter) FileLineEndings(string, EOLInfo)          {}
+func (silentReporter) Error(string, error)                      {}
+func (silentReporter) Summary(*Config, *Result)                 {}
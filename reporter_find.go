@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// findOptions 控制 find 子命令的输出方式
+type findOptions struct {
+	FilesOnly bool // -l：只输出文件名
+	Count     bool // -c：输出每个文件的匹配行数
+	Null      bool // -0：文件名后用 NUL 代替 ':' 或换行
+	Unique    int  // --unique-lines：汇总不同的匹配行，最多列出的种数（0 为不汇总）
+}
+
+// findReporter 是 find 和 verify 子命令的终端输出，格式与 grep 相同：
+// 匹配行为 路径:行号:内容，上下文行为 路径-行号-内容；指定 -C 时不相邻的片段之间用 -- 分隔。
+// 错误总是输出到标准错误。没有 -0 时，-l/-c 的文件名按 quotePath 规则转义，
+// 匹配行中的路径和内容转义控制字符。
+type findReporter struct {
+	out    *outputSink
+	errLog *log.Logger
+	opts   findOptions
+	verify bool
+	from    string
+	color   bool
+	context int
+}
+
+// newFindReporter 创建 find/verify 输出
+func newFindReporter(w, errw io.Writer, opts findOptions, verify bool) *findReporter {
+	return &findReporter{out: newOutputSink(w), errLog: log.New(errw, "", 0), opts: opts, verify: verify}
+}
+
+func (r *findReporter) Start(config *Config) {
+	r.from = config.SourceString
+	r.color = config.color
+	r.context = config.Context
+}
+
+// pathEnd 返回文件名之后的分隔符
+func (r *findReporter) pathEnd(sep string) string {
+	if r.opts.Null {
+		return "\x00"
+	}
+	return sep
+}
+
+// listPath 返回列表输出中的文件名：-0 时原样输出，否则加引号转义
+func (r *findReporter) listPath(path string) string {
+	if r.opts.Null {
+		return path
+	}
+	return quotePath(path)
+}
+
+func (r *findReporter) FileMatched(ev FileEvent) {
+	var sb strings.Builder
+	switch {
+	case r.opts.FilesOnly:
+		sb.WriteString(paint(r.listPath(ev.Path), ansiPath, r.color) + r.pathEnd("\n"))
+	case r.opts.Count:
+		lines := 0
+		for _, lm := range ev.Preview {
+			if len(lm.Matches) > 0 {
+				lines++
+			}
+		}
+		fmt.Fprintf(&sb, "%s%s%d\n", paint(r.listPath(ev.Path), ansiPath, r.color), paint(r.pathEnd(":"), ansiSep, r.color), lines)
+	default:
+		path := ev.Path
+		if !r.opts.Null {
+			path = escapeControl(path)
+		}
+		prev := 0
+		for _, lm := range ev.Preview {
+			if r.context > 0 && prev > 0 && lm.LineNo > prev+1 {
+				sb.WriteString("--\n")
+			}
+			sep := "-"
+			if len(lm.Matches) > 0 {
+				sep = ":"
+			}
+			fmt.Fprintf(&sb, "%s%s%s%s%s\n", paint(path, ansiPath, r.color), paint(r.pathEnd(sep), ansiSep, r.color),
+				paint(strconv.Itoa(lm.LineNo), ansiLineNo, r.color), paint(sep, ansiSep, r.color), r.highlight(lm))
+			prev = lm.LineNo
+		}
+	}
+	r.out.Print(sb.String())
+}
+
+// highlight 输出匹配行内容，启用颜色时标出匹配部分
+func (r *findReporter) highlight(lm lineMatch) string {
+	if !r.color || len(lm.Matches) == 0 {
+		return escapeControl(lm.Line)
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range lm.Matches {
+		sb.WriteString(escapeControl(lm.Line[last:m.Start]))
+		sb.WriteString(paint(escapeControl(lm.Line[m.Start:m.End]), ansiMatch, true))
+		last = m.End
+	}
+	sb.WriteString(escapeControl(lm.Line[last:]))
+	return sb.String()
+}
+
+func (r *findReporter) FileReplaced(ev FileEvent)                { r.FileMatched(ev) }
+func (r *findReporter) FileSkipped(string, bool, SkipReason)     {}
+func (r *findReporter) Notice(string, string)                    {}
+func (r *findReporter) FileScanned(string, int64, time.Duration) {}
+func (r *findReporter) FileSlow(SlowFile)                        {}
+func (r *findReporter) FileLineEndings(string, EOLInfo)          {}
+
+func (r *findReporter) Error(path string, err error) {
+	r.errLog.Print(escapeControl(err.Error()))
+}
+
+func (r *findReporter) Summary(config *Config, result *Result) {
+	files, matches := atomic.LoadInt32(&result.FilesMatches), atomic.LoadInt32(&result.Matches)
+	switch {
+	case r.verify && matches == 0:
+		r.out.Printf("验证通过: 未发现 '%s'\n", r.from)
+	case r.verify:
+		r.out.Printf("\n验证失败: %d 个文件中仍有 %d 处 '%s'\n", files, matches, r.from)
+	case config.Verbose:
+		r.out.Printf("\n%d 个文件中共 %d 处匹配\n", files, matches)
+	}
+	r.out.Flush()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// find 的输出与 grep 相同：只有指定 -C 时不相邻的片段之间才输出 --
+func TestFindOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		context int
+		want    string
+	}{
+		{"无上下文", "foo\nbar\nfoo\n", 0, "a.txt:1:foo\na.txt:3:foo\n"},
+		{"无上下文相邻行", "foo\nfoo\n", 0, "a.txt:1:foo\na.txt:2:foo\n"},
+		{"上下文相连", "foo\nbar\nfoo\n", 1, "a.txt:1:foo\na.txt-2-bar\na.txt:3:foo\n"},
+		{"上下文不相连", "foo\na\nb\nc\nfoo\n", 1, "a.txt:1:foo\na.txt-2-a\n--\na.txt-4-c\na.txt:5:foo\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, dir, "a.txt", tt.content, 0o644)
+			var out, errOut bytes.Buffer
+			config := &Config{SourceDir: dir, SourceString: "foo", TargetString: "foo", Trial: true,
+				previewAll: true, searchOnly: true, Context: tt.context, Workers: 1, NoLock: true,
+				Reporter: newFindReporter(&out, &errOut, findOptions{}, false)}
+			if _, err := Run(config); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(bytes.ReplaceAll(out.Bytes(), []byte(dir+string(os.PathSeparator)), nil)); got != tt.want {
+				t.Errorf("输出为 %q，应为 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// jsonReporter 在运行结束时输出一个完整的 JSON 文档
+type jsonReporter struct {
+	w      io.Writer
+	mu     sync.Mutex
+	config jsonConfig
+	files  []jsonFile
+	eols   []jsonEOL
+	errors []jsonError
+
+	profile fileProfile
+}
+
+type jsonConfig struct {
+	SourceDir    string `json:"dir"`
+	SourceString string `json:"from"`
+	TargetString string `json:"to"`
+	Trial        bool   `json:"trial"`
+	Workers      int    `json:"workers"`
+}
+
+type jsonFile struct {
+	Path        string `json:"path"`
+	Matches     int    `json:"matches"`
+	Replaced    bool   `json:"replaced"`
+	SizeDelta   int64  `json:"sizeDelta"`
+	BytesBefore int64  `json:"bytesBefore,omitempty"`
+	BytesAfter  int64  `json:"bytesAfter,omitempty"`
+}
+
+type jsonEOL struct {
+	Path         string `json:"path"`
+	Style        string `json:"style"`
+	LF           int    `json:"lf"`
+	CRLF         int    `json:"crlf"`
+	CR           int    `json:"cr"`
+	FinalNewline bool   `json:"finalNewline"`
+}
+
+type jsonError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type jsonDocument struct {
+	Config  jsonConfig      `json:"config"`
+	Files   []jsonFile      `json:"files"`
+	EOL     []jsonEOL       `json:"lineEndings,omitempty"`
+	Errors  []jsonError     `json:"errors,omitempty"`
+	Summary RunSummary      `json:"summary"`
+	Profile *ProfileSummary `json:"profile,omitempty"`
+}
+
+// newJSONReporter 创建 JSON 输出
+func newJSONReporter(w io.Writer) *jsonReporter {
+	return &jsonReporter{w: w}
+}
+
+func (r *jsonReporter) Start(config *Config) {
+	// JSON output carries the timing of every file
+	if config.ProfileFiles > 0 {
+		r.profile.top = math.MaxInt
+	}
+	r.config = jsonConfig{
+		SourceDir:    config.SourceDir,
+		SourceString: config.SourceString,
+		TargetString: config.TargetString,
+		Trial:        config.Trial,
+		Workers:      config.Workers,
+	}
+}
+
+func (r *jsonReporter) addFile(ev FileEvent, replaced bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = append(r.files, jsonFile{
+		Path:        ev.Path,
+		Matches:     ev.Matches,
+		Replaced:    replaced,
+		SizeDelta:   ev.Delta,
+		BytesBefore: ev.BytesBefore,
+		BytesAfter:  ev.BytesAfter,
+	})
+}
+
+func (r *jsonReporter) FileMatched(ev FileEvent)  { r.addFile(ev, false) }
+func (r *jsonReporter) FileReplaced(ev FileEvent) { r.addFile(ev, true) }
+
+func (r *jsonReporter) FileSkipped(string, bool, SkipReason) {}
+func (r *jsonReporter) Notice(string, string)                {}
+func (r *jsonReporter) FileScanned(path string, size int64, elapsed time.Duration) {
+	r.profile.add(path, size, elapsed)
+}
+
+// FileSlow needs no event: the summary lists every slow file
+func (r *jsonReporter) FileSlow(SlowFile) {}
+
+func (r *jsonReporter) FileLineEndings(path string, info EOLInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.eols = append(r.eols, jsonEOL{
+		Path:         path,
+		Style:        info.Style.Key(),
+		LF:           info.LF,
+		CRLF:         info.CRLF,
+		CR:           info.CR,
+		FinalNewline: info.FinalNewline,
+	})
+}
+
+func (r *jsonReporter) Error(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, jsonError{Path: path, Error: err.Error()})
+}
+
+func (r *jsonReporter) Summary(config *Config, result *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc := jsonDocument{
+		Config:  r.config,
+		Files:   r.files,
+		EOL:     r.eols,
+		Errors:  r.errors,
+		Summary: summarize(config, result),
+		Profile: r.profile.summary(),
+	}
+	if doc.Files == nil {
+		doc.Files = []jsonFile{}
+	}
+
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	enc.Encode(doc)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// porcelainReporter 输出稳定的、便于脚本解析的格式，每行一个文件。
+// 路径按 quotePath 规则转义，含特殊字符时带双引号：
+//
+//	M<TAB>匹配数<TAB>路径    试验模式下存在匹配
+//	R<TAB>替换数<TAB>路径    已完成替换
+//	L<TAB>风格<TAB>路径      换行符报告（风格为 none|lf|crlf|cr|mixed，缺少结尾换行时附加 ,noeol）
+//	S<TAB>毫秒<TAB>阶段<TAB>路径  处理超过 --slow-threshold（阶段为 detect|scan|rewrite）
+//	E<TAB>路径<TAB>错误信息  处理出错
+type porcelainReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newPorcelainReporter 创建 porcelain 输出
+func newPorcelainReporter(w io.Writer) *porcelainReporter {
+	return &porcelainReporter{w: w}
+}
+
+func (r *porcelainReporter) printf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, format, args...)
+}
+
+func (r *porcelainReporter) Start(*Config) {}
+
+func (r *porcelainReporter) FileMatched(ev FileEvent) {
+	r.printf("M\t%d\t%s\n", ev.Matches, quotePath(ev.Path))
+}
+
+func (r *porcelainReporter) FileReplaced(ev FileEvent) {
+	r.printf("R\t%d\t%s\n", ev.Matches, quotePath(ev.Path))
+}
+
+func (r *porcelainReporter) FileSkipped(string, bool, SkipReason)     {}
+func (r *porcelainReporter) Notice(string, string)                    {}
+func (r *porcelainReporter) FileScanned(string, int64, time.Duration) {}
+
+func (r *porcelainReporter) FileSlow(f SlowFile) {
+	r.printf("S\t%d\t%s\t%s\n", f.ElapsedMs, f.Phase, quotePath(f.Path))
+}
+
+func (r *porcelainReporter) FileLineEndings(path string, info EOLInfo) {
+	style := info.Style.Key()
+	if !info.FinalNewline {
+		style += ",noeol"
+	}
+	r.printf("L\t%s\t%s\n", style, quotePath(path))
+}
+
+func (r *porcelainReporter) Error(path string, err error) {
+	r.printf("E\t%s\t%s\n", quotePath(path), escapeControl(err.Error()))
+}
+
+func (r *porcelainReporter) Summary(*Config, *Result) {}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ExitNotReversible 是 --check-reversible 发现冲突时的退出码
+const ExitNotReversible = 1
+
+// ErrNotReversible 表示反向替换不能完全还原替换前的内容
+var ErrNotReversible = errors.New("替换不可逆")
+
+// reversibleMatcher 检查替换是否可逆：对每行先做正向替换（源→目标），再看反向替换
+// （目标→源）是否恰好落在正向替换插入的文本上。落在别处的反向匹配就是冲突，
+// 包括原本就存在的目标字符串和替换文本与相邻字符拼出的目标字符串。
+// 冲突作为匹配返回，位置换算回原行，替换文本与原文相同。
+type reversibleMatcher struct {
+	forward *literalMatcher
+	reverse *literalMatcher
+}
+
+// newReversibleMatcher 创建检查 search→replace 是否可逆的匹配器
+func newReversibleMatcher(search, replace string) *reversibleMatcher {
+	return &reversibleMatcher{forward: newLiteralMatcher(search, replace), reverse: newLiteralMatcher(replace, search)}
+}
+
+// FindAll 返回该行中反向替换不能还原的位置
+func (m *reversibleMatcher) FindAll(line string) []Match {
+	fwd := m.forward.FindAll(line)
+	out := applyMatches(line, fwd)
+
+	// Where the forward replacements land in the output
+	spans := make([][2]int, len(fwd))
+	inserted := make(map[int]bool, len(fwd))
+	shift := 0
+	for i, f := range fwd {
+		start := f.Start + shift
+		spans[i] = [2]int{start, start + len(f.Replacement)}
+		inserted[start] = true
+		shift += len(f.Replacement) - (f.End - f.Start)
+	}
+
+	// Inserted spans never overlap, so a reverse match that skips one
+	// must itself start somewhere else and is reported
+	var conflicts []Match
+	for _, r := range m.reverse.FindAll(out) {
+		if inserted[r.Start] {
+			continue
+		}
+		start, end := originalPos(r.Start, fwd, spans, false), originalPos(r.End, fwd, spans, true)
+		if n := len(conflicts); n > 0 && start < conflicts[n-1].End {
+			conflicts[n-1].End = end
+			conflicts[n-1].Replacement = strings.Clone(line[conflicts[n-1].Start:end])
+			continue
+		}
+		conflicts = append(conflicts, Match{Start: start, End: end, Replacement: strings.Clone(line[start:end])})
+	}
+	return conflicts
+}
+
+// originalPos 把正向替换结果中的位置换算为原行中的位置，
+// 落在插入的替换文本内时取对应匹配的起点（end 为 true 时取终点）
+func originalPos(p int, fwd []Match, spans [][2]int, end bool) int {
+	shift := 0
+	for i, s := range spans {
+		if p < s[0] || (end && p == s[0]) {
+			break
+		}
+		if p < s[1] || (end && p == s[1]) {
+			if end {
+				return fwd[i].End
+			}
+			return fwd[i].Start
+		}
+		shift += (s[1] - s[0]) - (fwd[i].End - fwd[i].Start)
+	}
+	return p - shift
+}
+
+// reversibleReporter 以 find 的格式列出冲突，最后给出结论
+type reversibleReporter struct {
+	*findReporter
+	from, to string
+}
+
+func (r reversibleReporter) Summary(config *Config, result *Result) {
+	files, conflicts := atomic.LoadInt32(&result.FilesMatches), atomic.LoadInt32(&result.Matches)
+	if conflicts == 0 {
+		r.out.Printf("可逆: 反向替换 '%s' → '%s' 能完全还原\n", escapeControl(r.to), escapeControl(r.from))
+	} else {
+		r.out.Printf("\n不可逆: %d 个文件中有 %d 处冲突，反向替换 '%s' → '%s' 会改动这些位置\n",
+			files, conflicts, escapeControl(r.to), escapeControl(r.from))
+	}
+	r.out.Flush()
+}
+
+// runCheckReversible 执行 --check-reversible：只扫描，不修改任何文件。
+// 参数已由 runApp 校验。
+func runCheckReversible(args []string) error {
+	if whitespaceMode(&cfg) || cfg.TrimTrailing || cfg.Swap || cfg.Map != "" || cfg.IgnoreWhitespace || cfg.Regex || cfg.IgnoreCase || cfg.Word || cfg.LineMode || cfg.Anchor != AnchorNone ||
+		cfg.Nth > 0 || cfg.Occurrences != "" || cfg.MaxTotal > 0 || cfg.TUI || cfg.EmitScript != "" || cfg.GitCommit {
+		return configError("--check-reversible 只检查普通的字符串替换，不能与其他转换模式、--map、--ignore-whitespace、--regex、--ignore-case、--word、--nth、--occurrences、--max-total、--tui、--emit-script 或 --git-commit 一起使用")
+	}
+	if cfg.SourceString == cfg.TargetString {
+		return configError("--check-reversible 需要不同的源字符串和目标字符串")
+	}
+	if cfg.TargetString == "" {
+		return configError("--check-reversible 不能检查删除：空的目标字符串无法反向替换")
+	}
+
+	cfg.Trial = true
+	cfg.searchOnly = true
+	cfg.previewAll = true
+	cfg.matcher = newReversibleMatcher(cfg.SourceString, cfg.TargetString)
+	if cfg.Format == FormatConsole {
+		cfg.Reporter = reversibleReporter{newFindReporter(os.Stdout, os.Stderr, findOptions{}, false), cfg.SourceString, cfg.TargetString}
+	}
+	if err := prepareRun(args); err != nil {
+		return err
+	}
+
+	result, err := Run(&cfg)
+	if err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&result.Matches) > 0 {
+		return ErrNotReversible
+	}
+	return nil
+}
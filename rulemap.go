@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// mapOptions 是一条规则的匹配选项
+type mapOptions struct {
+	Regex      bool
+	IgnoreCase bool
+	Word       bool
+}
+
+// mapOptionNames 是选项列中可用的名称，按显示顺序排列
+var mapOptionNames = []string{"regex", "ignoreCase", "wholeWord"}
+
+// option 返回名称对应的选项字段
+func (o *mapOptions) option(name string) *bool {
+	switch name {
+	case "regex":
+		return &o.Regex
+	case "ignoreCase":
+		return &o.IgnoreCase
+	case "wholeWord":
+		return &o.Word
+	}
+	return nil
+}
+
+// label 返回汇总中显示的选项，没有任何选项时为空
+func (o mapOptions) label() string {
+	var on []string
+	for _, name := range mapOptionNames {
+		if *o.option(name) {
+			on = append(on, name)
+		}
+	}
+	if len(on) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(on, ",") + "]"
+}
+
+// mapRule 是 --map 文件中的一条替换规则
+type mapRule struct {
+	From string
+	To   string
+	mapOptions
+	pattern *regexp.Regexp // regex 或 ignoreCase 规则编译后的模式
+}
+
+// loadMap 读取 --map 文件。每行一条规则 from<TAB>to[<TAB>选项]，按文件中的顺序应用；
+// 空行和以 # 开头的行被忽略。字段以双引号开头时按 Go 字符串字面量解析，
+// 可以用 \t、\" 等转义写出制表符和引号；否则按原样使用，保留前导空格。
+// 选项列是逗号分隔的 regex、ignoreCase、wholeWord，可写成 name=false 关闭；
+// 没有写出的选项取 defaults（命令行上的 --regex、--ignore-case 和 --word）。
+// 错误给出规则的序号（从 1 起）和行号。
+func loadMap(path string, defaults mapOptions) ([]mapRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []mapRule
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseMapLine(line, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("%s 规则 %d（第 %d 行）: %w", path, len(rules)+1, lineNo, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s 中没有替换规则", path)
+	}
+	return rules, nil
+}
+
+// parseMapLine 把一行拆分为源字符串、目标字符串和选项，并编译需要的模式
+func parseMapLine(line string, defaults mapOptions) (mapRule, error) {
+	from, rest, err := mapField(line)
+	if err != nil {
+		return mapRule{}, err
+	}
+	if !strings.HasPrefix(rest, "\t") {
+		return mapRule{}, errors.New("缺少分隔源字符串和目标字符串的制表符")
+	}
+	to, rest, err := mapField(rest[1:])
+	if err != nil {
+		return mapRule{}, err
+	}
+	opts := defaults
+	if rest != "" {
+		if !strings.HasPrefix(rest, "\t") {
+			return mapRule{}, errors.New("目标字符串的引号之后还有内容")
+		}
+		if opts, err = parseMapOptions(rest[1:], defaults); err != nil {
+			return mapRule{}, err
+		}
+	}
+	if from == "" {
+		return mapRule{}, errors.New("源字符串不能为空")
+	}
+	if strings.ContainsAny(from, "\r\n") {
+		return mapRule{}, errors.New("源字符串含有换行符；reStr 逐行匹配，跨行的字符串无法匹配")
+	}
+	rule := mapRule{From: from, To: to, mapOptions: opts}
+	if opts.Regex || opts.IgnoreCase {
+		expr := from
+		if !opts.Regex {
+			expr = regexp.QuoteMeta(from)
+		}
+		if opts.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		if rule.pattern, err = regexp.Compile(expr); err != nil {
+			return mapRule{}, fmt.Errorf("无效的正则表达式 %q: %v", from, err)
+		}
+		if opts.Regex {
+			if err := checkGroupRefs(rule.pattern, to); err != nil {
+				return mapRule{}, fmt.Errorf("目标字符串中的分组引用无效: %v", err)
+			}
+		}
+	}
+	return rule, nil
+}
+
+// parseMapOptions 解析选项列，从 defaults 开始应用其中写出的选项
+func parseMapOptions(s string, defaults mapOptions) (mapOptions, error) {
+	opts := defaults
+	if strings.Contains(s, "\t") {
+		return opts, errors.New("多于三列；字符串中的制表符请写在引号内（\"\\t\"）")
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(item, "=")
+		if name == "multiline" {
+			return opts, errors.New("不支持 multiline：替换规则逐行应用，跨行的字符串请用 --from 单独替换")
+		}
+		field := opts.option(name)
+		if field == nil {
+			return opts, fmt.Errorf("未知的选项 %q（可选 %s）", name, strings.Join(mapOptionNames, "、"))
+		}
+		on := true
+		if hasValue {
+			var err error
+			if on, err = strconv.ParseBool(value); err != nil {
+				return opts, fmt.Errorf("选项 %s 的值 %q 无效（可选 true|false）", name, value)
+			}
+		}
+		*field = on
+	}
+	return opts, nil
+}
+
+// mapField 读取一个字段，返回字段值和之后的剩余部分
+func mapField(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		field, rest, _ := strings.Cut(s, "\t")
+		if len(field) < len(s) {
+			rest = "\t" + rest
+		}
+		return field, rest, nil
+	}
+
+	// The closing quote is the first one not escaped by a backslash
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			field, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("无效的引号字符串 %s", s[:i+1])
+			}
+			return field, s[i+1:], nil
+		}
+	}
+	return "", "", errors.New("引号没有闭合")
+}
+
+// mapRuleNames 返回每条规则在汇总中显示的名称
+func mapRuleNames(rules []mapRule) []string {
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = mapDisplay(r.From) + "→" + mapDisplay(r.To) + r.label()
+	}
+	return names
+}
+
+// mapDisplay 在字符串为空、首尾有空白或含有控制字符时按引号形式显示，
+// 与 --map 文件中的写法一致
+func mapDisplay(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.IndexFunc(s, unicode.IsControl) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// chainMatcher 按顺序应用多条规则：每条规则在前面规则替换后的文本上查找，
+// 一次扫描完成全部替换。匹配仍以原始行中的位置给出；后面的规则匹配到前面
+// 规则写入的文本时，两处替换合并为一处，计入后一条规则。
+type chainMatcher struct {
+	rules []Matcher
+}
+
+// chainPiece 是替换过程中的一段文本，对应原始行中 [start, end) 的字节
+type chainPiece struct {
+	text       string
+	start, end int
+	replaced   bool // text 是替换结果；否则与原始行中的原文相同
+	rule       int
+}
+
+// FindAll 依次应用各规则，把替换过的片段作为匹配返回
+func (m *chainMatcher) FindAll(line string) []Match {
+	pieces := []chainPiece{{text: line, start: 0, end: len(line)}}
+	current := line
+	for k, rule := range m.rules {
+		matches := rule.FindAll(current)
+		if len(matches) == 0 {
+			continue
+		}
+		// Right to left, so the offsets of the pieces before each match
+		// are still those of the text the rule searched
+		for j := len(matches) - 1; j >= 0; j-- {
+			pieces = replacePieces(pieces, matches[j], k)
+		}
+		current = joinPieces(pieces)
+	}
+	if len(pieces) == 1 && !pieces[0].replaced {
+		return nil
+	}
+
+	var matches []Match
+	for _, p := range pieces {
+		if p.replaced {
+			matches = append(matches, Match{Start: p.start, End: p.end, Replacement: p.text, Rule: p.rule})
+		}
+	}
+	return matches
+}
+
+// replacePieces 用一处匹配（位置相对于各片段拼接成的文本）替换它覆盖的片段。
+// 原文片段在匹配边界处切开；部分覆盖的替换片段整段并入新的替换。
+func replacePieces(pieces []chainPiece, m Match, rule int) []chainPiece {
+	first, pos := 0, 0
+	for first < len(pieces) && pos+len(pieces[first].text) <= m.Start {
+		pos += len(pieces[first].text)
+		first++
+	}
+	last, end := first, pos
+	for last < len(pieces) {
+		end += len(pieces[last].text)
+		if end >= m.End {
+			break
+		}
+		last++
+	}
+
+	f, l := pieces[first], pieces[last]
+	merged := chainPiece{replaced: true, rule: rule, start: f.start, end: l.end}
+	var spliced []chainPiece
+
+	head, tail := f.text[:m.Start-pos], l.text[len(l.text)-(end-m.End):]
+	if !f.replaced && head != "" {
+		spliced = append(spliced, chainPiece{text: head, start: f.start, end: f.start + len(head)})
+		merged.start += len(head)
+		head = ""
+	}
+	var rest *chainPiece
+	if !l.replaced && tail != "" {
+		rest = &chainPiece{text: tail, start: l.end - len(tail), end: l.end}
+		merged.end -= len(tail)
+		tail = ""
+	}
+	merged.text = head + m.Replacement + tail
+	spliced = append(spliced, merged)
+	if rest != nil {
+		spliced = append(spliced, *rest)
+	}
+
+	out := make([]chainPiece, 0, len(pieces)+2)
+	out = append(out, pieces[:first]...)
+	out = append(out, spliced...)
+	return append(out, pieces[last+1:]...)
+}
+
+// joinPieces 拼接各片段的当前文本
+func joinPieces(pieces []chainPiece) string {
+	var sb strings.Builder
+	for _, p := range pieces {
+		sb.WriteString(p.text)
+	}
+	return sb.String()
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 放在被替换目录中的 --map 文件本身不是输入，不会被它的规则改写
+func TestMapFileNotRewritten(t *testing.T) {
+	dir := t.TempDir()
+	rules := "# British spellings\ncolour\tcolor\nbehaviour\tbehavior\n"
+	mapPath := writeTestFile(t, dir, "rules.map", rules, 0o644)
+	path := writeTestFile(t, dir, "a.txt", "colour and behaviour\n", 0o644)
+
+	loaded, err := loadMap(mapPath, mapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A relative --map path is registered as well
+	t.Chdir(dir)
+	result := runTest(t, &Config{SourceDir: dir, Map: "rules.map", mapRules: loaded})
+
+	if got := readTestFile(t, mapPath); got != rules {
+		t.Errorf("--map 文件被改写为 %q", got)
+	}
+	if got := readTestFile(t, path); got != "color and behavior\n" {
+		t.Errorf("替换后 %q", got)
+	}
+	if result.FilesMatches != 1 || result.Matches != 2 {
+		t.Errorf("修改 %d 个文件、%d 处，应为 1 个文件、2 处", result.FilesMatches, result.Matches)
+	}
+	if result.Skipped[SkipArtifact] != 1 {
+		t.Errorf("跳过的自身文件 %d 个，应为 1 个", result.Skipped[SkipArtifact])
+	}
+}
+
+func TestParseMapLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		from, to string
+		wantErr  bool
+	}{
+		{"foo\tbar", "foo", "bar", false},
+		{"foo\t", "foo", "", false},
+		{"  foo \t bar ", "  foo ", " bar ", false},
+		{`"a\tb"` + "\t" + `"c\"d"`, "a\tb", `c"d`, false},
+		{`"x"` + "\t" + "plain", "x", "plain", false},
+		{`"\\"` + "\t" + `"\\\\"`, `\`, `\\`, false},
+		{"say \"hi\"\tsay hello", `say "hi"`, "say hello", false},
+		{`"foo`, "", "", true},
+		{`"foo\"` + "\tbar", "", "", true},
+		{"foo", "", "", true},
+		{"foo bar", "", "", true},
+		{"a\tb\tc", "", "", true},
+		{`"a"x` + "\tb", "", "", true},
+		{"\tbar", "", "", true},
+		{`""` + "\tbar", "", "", true},
+		{`"a\nb"` + "\tc", "", "", true},
+		{`"\q"` + "\tc", "", "", true},
+		{"a\t\"b\"c", "", "", true},
+	}
+	for _, tt := range tests {
+		rule, err := parseMapLine(tt.line, mapOptions{})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseMapLine(%q) = %+v，应返回错误", tt.line, rule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMapLine(%q): %v", tt.line, err)
+			continue
+		}
+		if rule.From != tt.from || rule.To != tt.to {
+			t.Errorf("parseMapLine(%q) = %q→%q，应为 %q→%q", tt.line, rule.From, rule.To, tt.from, tt.to)
+		}
+	}
+}
+
+// 注释、空行和 CRLF 行尾被忽略，错误带有行号
+func TestLoadMap(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "rules.map", "# 注释\r\n\r\nfoo\tbar\r\n  \n\"a b\"\t\"\"\n", 0o644)
+	rules, err := loadMap(path, mapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mapRule{{From: "foo", To: "bar"}, {From: "a b", To: ""}}
+	if len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] {
+		t.Errorf("规则 = %+v，应为 %+v", rules, want)
+	}
+
+	bad := writeTestFile(t, dir, "bad.map", "foo\tbar\nbaz\n", 0o644)
+	if _, err := loadMap(bad, mapOptions{}); err == nil || !strings.Contains(err.Error(), "规则 2（第 2 行）") {
+		t.Errorf("错误 = %v，应指出规则 2（第 2 行）", err)
+	}
+	empty := writeTestFile(t, dir, "empty.map", "# 只有注释\n\n", 0o644)
+	if _, err := loadMap(empty, mapOptions{}); err == nil {
+		t.Error("没有规则的文件应返回错误")
+	}
+}
+
+// newChain 用字面规则构造 chainMatcher
+func newChain(rules ...string) *chainMatcher {
+	chain := &chainMatcher{}
+	for i := 0; i < len(rules); i += 2 {
+		chain.rules = append(chain.rules, newLiteralMatcher(rules[i], rules[i+1]))
+	}
+	return chain
+}
+
+// 链式替换的结果与逐条执行 strings.ReplaceAll 相同：后面的规则看到前面规则的输出
+func TestChainMatcherSequential(t *testing.T) {
+	tests := []struct {
+		rules []string
+		line  string
+	}{
+		{[]string{"a", "b", "b", "c"}, "a b ab"},
+		{[]string{"b", "c", "a", "b"}, "a b ab"},
+		{[]string{"colour", "color", "color", "hue"}, "colour color"},
+		{[]string{"ab", "X", "Xc", "Y"}, "abc abd c"},
+		{[]string{"a", "xyz", "y", "Q"}, "aaa y"},
+		{[]string{"foo", "", "bar", "baz"}, "foobar fobaro"},
+		{[]string{"oo", "o", "oo", "o"}, "foooo"},
+		{[]string{"a", "b"}, "no match here"},
+		{[]string{"ab", "c", "bc", "d", "cd", "e"}, "abcd"},
+	}
+	for _, tt := range tests {
+		want := tt.line
+		for i := 0; i < len(tt.rules); i += 2 {
+			want = strings.ReplaceAll(want, tt.rules[i], tt.rules[i+1])
+		}
+		matches := newChain(tt.rules...).FindAll(tt.line)
+		if got := applyMatches(tt.line, matches); got != want {
+			t.Errorf("规则 %q 作用于 %q = %q，应为 %q", tt.rules, tt.line, got, want)
+		}
+
+		// Matches are in order, do not overlap and cover real text
+		for i, m := range matches {
+			if m.Start > m.End || m.End > len(tt.line) || (i > 0 && m.Start < matches[i-1].End) {
+				t.Errorf("规则 %q: 匹配 %d 的位置 [%d,%d) 无效", tt.rules, i, m.Start, m.End)
+			}
+		}
+	}
+}
+
+// 合并的替换以原始行中的位置给出，计入后一条规则
+func TestChainMatcherCredit(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		line  string
+		want  []Match
+	}{
+		{"independent", []string{"a", "A", "b", "B"}, "ab", []Match{{0, 1, "A", 0, nil}, {1, 2, "B", 1, nil}}},
+		{"chained", []string{"a", "b", "b", "c"}, "xa", []Match{{1, 2, "c", 1, nil}}},
+		{"partial", []string{"ab", "X", "Xc", "Y"}, "abc", []Match{{0, 3, "Y", 1, nil}}},
+		{"inside", []string{"a", "xyz", "y", "Q"}, "-a-", []Match{{1, 2, "xQz", 1, nil}}},
+		{"deleted", []string{"o", "", "fx", "F"}, "fox", []Match{{0, 3, "F", 1, nil}}},
+		{"untouched", []string{"a", "b", "b", "c"}, "b", []Match{{0, 1, "c", 1, nil}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newChain(tt.rules...).FindAll(tt.line)
+			if len(got) != len(tt.want) {
+				t.Fatalf("匹配 = %+v，应为 %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("匹配 %d = %+v，应为 %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 试验模式和实际运行按规则分别计数，合并的替换计入后一条规则
+func TestMapRuleMatches(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "a b c\n", 0o644)
+	rules := []mapRule{{From: "a", To: "b"}, {From: "b", To: "c"}}
+
+	trial := runTest(t, &Config{SourceDir: dir, Map: "rules.map", mapRules: rules, Trial: true})
+	real := runTest(t, &Config{SourceDir: dir, Map: "rules.map", mapRules: rules})
+	for _, result := range []*Result{trial, real} {
+		if result.Matches != 2 || len(result.RuleMatches) != 2 || result.RuleMatches[0] != 0 || result.RuleMatches[1] != 2 {
+			t.Errorf("共 %d 处、按规则 %v，应为 2 处、[0 2]", result.Matches, result.RuleMatches)
+		}
+	}
+	if got := readTestFile(t, path); got != "c c c\n" {
+		t.Errorf("替换后 %q", got)
+	}
+}
+
+// 选项列覆盖命令行给出的默认选项，错误给出规则序号
+func TestMapRuleOptions(t *testing.T) {
+	tests := []struct {
+		line     string
+		defaults mapOptions
+		want     mapOptions
+		wantErr  bool
+	}{
+		{"a\tb", mapOptions{}, mapOptions{}, false},
+		{"a\tb", mapOptions{Regex: true, Word: true}, mapOptions{Regex: true, Word: true}, false},
+		{"a\tb\t", mapOptions{IgnoreCase: true}, mapOptions{IgnoreCase: true}, false},
+		{"a\tb\tregex", mapOptions{}, mapOptions{Regex: true}, false},
+		{"a\tb\tregex, ignoreCase,wholeWord", mapOptions{}, mapOptions{Regex: true, IgnoreCase: true, Word: true}, false},
+		{"a\tb\tregex=false,wholeWord=true", mapOptions{Regex: true}, mapOptions{Word: true}, false},
+		{`"a"` + "\t" + `"b"` + "\tignoreCase", mapOptions{}, mapOptions{IgnoreCase: true}, false},
+		{"a\tb\tmultiline", mapOptions{}, mapOptions{}, true},
+		{"a\tb\tfold", mapOptions{}, mapOptions{}, true},
+		{"a\tb\tregex=yes", mapOptions{}, mapOptions{}, true},
+		{"a\tb\tregex\tmore", mapOptions{}, mapOptions{}, true},
+		{"a(\tb\tregex", mapOptions{}, mapOptions{}, true},
+		{"a(\tb", mapOptions{Regex: true}, mapOptions{}, true},
+		{"(a)\t$2\tregex", mapOptions{}, mapOptions{}, true},
+		{"(?P<x>a)\t${x}_\tregex", mapOptions{}, mapOptions{Regex: true}, false},
+		{"a(\tb\tignoreCase", mapOptions{}, mapOptions{IgnoreCase: true}, false},
+	}
+	for _, tt := range tests {
+		rule, err := parseMapLine(tt.line, tt.defaults)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseMapLine(%q) = %+v，应返回错误", tt.line, rule.mapOptions)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMapLine(%q): %v", tt.line, err)
+			continue
+		}
+		if rule.mapOptions != tt.want {
+			t.Errorf("parseMapLine(%q) 的选项 = %+v，应为 %+v", tt.line, rule.mapOptions, tt.want)
+		}
+		if compiled := rule.pattern != nil; compiled != (tt.want.Regex || tt.want.IgnoreCase) {
+			t.Errorf("parseMapLine(%q): 编译了模式 %v", tt.line, compiled)
+		}
+	}
+
+	path := writeTestFile(t, t.TempDir(), "rules.map", "# 注释\nfoo\tbar\n\nx(\ty\tregex\n", 0o644)
+	if _, err := loadMap(path, mapOptions{}); err == nil || !strings.Contains(err.Error(), "规则 2（第 4 行）") {
+		t.Errorf("错误 = %v，应指出规则 2（第 4 行）", err)
+	}
+}
+
+// 每条规则按自己的选项匹配，汇总的规则名称显示生效的选项
+func TestMapRuleFlagsRun(t *testing.T) {
+	dir := t.TempDir()
+	mapPath := writeTestFile(t, t.TempDir(), "rules.map",
+		"v(\\d+)\tversion $1\tregex\nTODO\tFIXME\tignoreCase\nid\tkey\twholeWord\n.\t!\n", 0o644)
+	path := writeTestFile(t, dir, "a.txt", "v12 todo Todo idea id.\n", 0o644)
+
+	rules, err := loadMap(mapPath, mapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{SourceDir: dir, Map: mapPath, mapRules: rules}
+	result := runTest(t, config)
+
+	if got := readTestFile(t, path); got != "version 12 FIXME FIXME idea key!\n" {
+		t.Errorf("替换后 %q", got)
+	}
+	want := []string{`v(\d+)→version $1 [regex]`, "TODO→FIXME [ignoreCase]", "id→key [wholeWord]", ".→!"}
+	names := ruleNames(config)
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("规则名称 %q，应为 %q", names, want)
+	}
+	if len(result.RuleMatches) != 4 || result.RuleMatches[0] != 1 || result.RuleMatches[1] != 2 || result.RuleMatches[2] != 1 || result.RuleMatches[3] != 1 {
+		t.Errorf("按规则计数 %v，应为 [1 2 1 1]", result.RuleMatches)
+	}
+}
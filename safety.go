@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// dangerousDirReason 检查源目录是否是文件系统根目录或用户主目录本身，
+// 是则返回原因说明，否则返回空字符串。
+// 调用方需先将路径转换为绝对路径，避免相对路径绕过检查。
+func dangerousDirReason(absDir string) string {
+	candidates := []string{filepath.Clean(absDir)}
+	if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+		candidates = append(candidates, filepath.Clean(resolved))
+	}
+
+	home, _ := os.UserHomeDir()
+	for _, dir := range candidates {
+		if filepath.Dir(dir) == dir {
+			return "文件系统根目录 " + dir
+		}
+
+		if home != "" && samePath(dir, filepath.Clean(home)) {
+			return "用户主目录 " + dir
+		}
+	}
+
+	return ""
+}
+
+// samePath 比较两个路径是否相同（Windows 下不区分大小写）
+func samePath(a, b string) bool {
+	if isCaseInsensitiveFS() {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// isCaseInsensitiveFS 判断当前平台的文件名是否通常不区分大小写
+func isCaseInsensitiveFS() bool {
+	return runtime.GOOS == "windows"
+}
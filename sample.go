@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// newSampler 为 --sample 创建随机数生成器。每次遍历都重新创建，
+// 同一种子在每次扫描中抽中相同的文件。
+func newSampler(config *Config) *rand.Rand {
+	if config.Sample <= 0 {
+		return nil
+	}
+	return rand.New(rand.NewSource(config.Seed))
+}
+
+// sampled 按 --sample 的百分比决定是否处理一个已通过过滤的候选文件。
+// 遍历只在一个协程中进行，因此生成器无需加锁，相同种子得到相同的抽样。
+func sampled(config *Config) bool {
+	if config.sampler == nil {
+		return true
+	}
+	return config.sampler.Float64()*100 < config.Sample
+}
+
+// SampleSummary 抽样运行的规模和按比例外推的估计总数
+type SampleSummary struct {
+	Percent         float64 `json:"percent"`
+	Seed            int64   `json:"seed"`
+	Sampled         int32   `json:"sampled"`
+	Candidates      int32   `json:"candidates"`
+	EstFilesMatched int64   `json:"estimatedFilesMatched"`
+	EstMatches      int64   `json:"estimatedMatches"`
+}
+
+// summarizeSample 把抽样中得到的数字外推到全部候选文件
+func summarizeSample(config *Config, s RunSummary) *SampleSummary {
+	if config.Sample <= 0 {
+		return nil
+	}
+
+	sample := &SampleSummary{
+		Percent:    config.Sample,
+		Seed:       config.Seed,
+		Sampled:    s.FilesFound,
+		Candidates: s.FilesFound + s.Skipped[SkipNotSampled.Key()],
+	}
+	if sample.Sampled > 0 {
+		scale := float64(sample.Candidates) / float64(sample.Sampled)
+		sample.EstFilesMatched = int64(math.Round(float64(s.FilesMatches) * scale))
+		sample.EstMatches = int64(math.Round(float64(s.Matches) * scale))
+	}
+	return sample
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 发布信息的来源。每个版本的资产命名为 reStr_<GOOS>_<GOARCH>[.exe]，
+// 并附带 sha256sum 格式的 checksums.txt。
+const (
+	releaseAPI        = "https://api.github.com/repos/rankalpha2023/reStr/releases/latest"
+	checksumAssetName = "checksums.txt"
+)
+
+var checkOnly bool
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "从 GitHub 发布页更新 reStr 到最新版本",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSelfUpdate()
+	},
+}
+
+func init() {
+	selfUpdateCmd.Flags().BoolVar(&checkOnly, "check-only", false, "只检查是否有新版本，不下载")
+	rootCmd.AddCommand(selfUpdateCmd)
+	rootCmd.Version = version
+}
+
+// release 是 GitHub 发布 API 返回内容中用到的部分
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// asset 按名称查找发布资产
+func (r *release) asset(name string) (releaseAsset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// platformAssetName 返回当前平台的可执行文件资产名
+func platformAssetName() string {
+	name := fmt.Sprintf("reStr_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// httpGet 下载 URL 的内容，非 200 响应视为错误
+func httpGet(url string) (io.ReadCloser, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("请求 %s 失败: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// latestRelease 获取最新发布的信息
+func latestRelease() (*release, error) {
+	body, err := httpGet(releaseAPI)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var r release
+	if err := json.NewDecoder(body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("解析发布信息失败: %w", err)
+	}
+	return &r, nil
+}
+
+// expectedChecksum 从 checksums.txt 中取出指定资产的 SHA-256
+func expectedChecksum(r *release, name string) (string, error) {
+	asset, ok := r.asset(checksumAssetName)
+	if !ok {
+		return "", fmt.Errorf("发布 %s 中没有 %s，无法校验", r.TagName, checksumAssetName)
+	}
+
+	body, err := httpGet(asset.URL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s 中没有 %s 的校验和", checksumAssetName, name)
+}
+
+func runSelfUpdate() error {
+	if version == "dev" {
+		return errors.New("当前为未注入版本号的开发构建，无法判断是否需要更新")
+	}
+
+	r, err := latestRelease()
+	if err != nil {
+		return fmt.Errorf("检查更新失败: %w", err)
+	}
+
+	if compareVersions(r.TagName, version) <= 0 {
+		fmt.Printf("已是最新版本: %s\n", version)
+		return nil
+	}
+
+	name := platformAssetName()
+	asset, ok := r.asset(name)
+	if !ok {
+		return fmt.Errorf("发现新版本 %s，但没有适用于 %s/%s 的文件 %s", r.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+
+	fmt.Printf("发现新版本: %s (当前 %s)\n", r.TagName, version)
+	if checkOnly {
+		return nil
+	}
+
+	checksum, err := expectedChecksum(r, name)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("无法确定当前可执行文件的位置: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	if err := replaceExecutable(exe, asset.URL, checksum); err != nil {
+		return fmt.Errorf("更新失败: %w", err)
+	}
+	fmt.Printf("已更新到 %s\n", r.TagName)
+	return nil
+}
+
+// replaceExecutable 把新版本下载到可执行文件所在目录，校验通过后替换当前文件
+func replaceExecutable(exe, url, checksum string) (err error) {
+	body, err := httpGet(url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dir := filepath.Dir(exe)
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+filepath.Base(exe)+"-*"+tempFileSuffix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != checksum {
+		return fmt.Errorf("校验和不匹配: 期望 %s，实际 %s", checksum, got)
+	}
+
+	if err = os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+
+	// Windows 不能覆盖正在运行的可执行文件，但可以重命名它：
+	// 先把当前文件移到 .old，再把新文件移到原位置
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err = os.Rename(exe, old); err != nil {
+			return err
+		}
+		if err = os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(old, exe)
+			return err
+		}
+		return nil
+	}
+
+	return os.Rename(tmp.Name(), exe)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits 是 parseSize 接受的单位后缀，按 1024 进位
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}}
+
+// parseSize 解析文件大小，如 512K、10M、1.5G，不带单位时为字节数；
+// 单位不区分大小写，也可以写作 KB、MiB 等
+func parseSize(s string) (int64, error) {
+	num := strings.ToUpper(strings.TrimSpace(s))
+	num = strings.TrimSuffix(strings.TrimSuffix(num, "IB"), "B")
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, unit = strings.TrimSuffix(num, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q 不是有效的大小（例如 512K、10M、1G）", s)
+	}
+	return int64(n * float64(unit)), nil
+}
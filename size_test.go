@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"100", 100},
+		{"512K", 512 << 10},
+		{"512k", 512 << 10},
+		{"10M", 10 << 20},
+		{"10MB", 10 << 20},
+		{"10MiB", 10 << 20},
+		{"1.5G", 3 << 29},
+		{" 2 K ", 2 << 10},
+		{"1B", 1},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if err != nil {
+			t.Errorf("parseSize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d，应为 %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "ten", "-1K", "10X", "K"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) 应返回错误", in)
+		}
+	}
+}
+
+// openLogFS 记录打开过的文件名
+type openLogFS struct {
+	osFS
+	mu     sync.Mutex
+	opened []string
+}
+
+func (f *openLogFS) Open(name string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	f.opened = append(f.opened, filepath.Base(name))
+	f.mu.Unlock()
+	return f.osFS.Open(name)
+}
+
+// 超过 --max-size 的文件在读取之前跳过并单独计数，等于上限的文件照常处理
+func TestMaxSizeRun(t *testing.T) {
+	dir := t.TempDir()
+	small := writeTestFile(t, dir, "small.txt", "foo\n", 0o644)
+	exact := writeTestFile(t, dir, "exact.txt", "foo"+strings.Repeat("x", 1020)+"\n", 0o644)
+	large := writeTestFile(t, dir, "large.log", "foo"+strings.Repeat("x", 2048)+"\n", 0o644)
+
+	defer func() { cfg = Config{} }()
+	defaultConfig(t)
+	cfg.SourceDir, cfg.SourceString, cfg.TargetString, cfg.MaxSize = dir, "foo", "bar", "1K"
+	if err := validateMatchFlags(); err != nil {
+		t.Fatal(err)
+	}
+	fsys := &openLogFS{}
+	cfg.FS = fsys
+	result := runTest(t, &cfg)
+
+	if result.Skipped[SkipTooLarge] != 1 || result.FilesMatches != 2 {
+		t.Errorf("跳过过大的文件 %d 个、修改 %d 个，应为 1 和 2", result.Skipped[SkipTooLarge], result.FilesMatches)
+	}
+	if !slices.Contains(fsys.opened, "small.txt") || slices.Contains(fsys.opened, "large.log") {
+		t.Errorf("打开了 %v，过大的文件不应被打开", fsys.opened)
+	}
+	for path, want := range map[string]string{small: "bar", exact: "bar", large: "foo"} {
+		if got := readTestFile(t, path); !strings.HasPrefix(got, want) {
+			t.Errorf("%s 开头为 %.3q，应为 %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+)
+
+// SkipReason 文件或目录被跳过的原因
+type SkipReason int
+
+const (
+	SkipHidden SkipReason = iota
+	SkipBinary
+	SkipVCS
+	SkipArtifact
+	SkipSystem
+	SkipNoSpace
+	SkipUnwritable
+	SkipNotSampled
+	SkipExcluded
+	SkipMountPoint
+	SkipNetworkDir
+	SkipUnchanged
+	SkipMinified
+	SkipAge
+	SkipOwner
+	SkipVanished
+	SkipVirtualFS
+	SkipFiltered
+	SkipBackup
+	SkipTooLarge
+	SkipSymlink
+	skipReasonCount
+)
+
+// skipReasonNames 跳过原因在汇总中显示的名称
+var skipReasonNames = [skipReasonCount]string{
+	SkipHidden:     "隐藏",
+	SkipBinary:     "二进制",
+	SkipVCS:        "版本控制目录",
+	SkipArtifact:   "输出文件",
+	SkipSystem:     "系统文件",
+	SkipNoSpace:    "磁盘空间不足",
+	SkipUnwritable: "不可写",
+	SkipNotSampled: "未抽中",
+	SkipExcluded:   "审阅时排除",
+	SkipMountPoint: "其他文件系统",
+	SkipNetworkDir: "网络文件系统",
+	SkipUnchanged:  "匹配但内容不变",
+	SkipMinified:   "压缩代码",
+	SkipAge:        "修改时间不在范围内",
+	SkipOwner:      "其他用户的文件",
+	SkipVanished:   "处理时已被删除",
+	SkipVirtualFS:  "虚拟文件系统",
+	SkipFiltered:   "被 --include/--exclude 排除",
+	SkipBackup:     "备份文件",
+	SkipTooLarge:   "超过 --max-size",
+	SkipSymlink:    "符号链接",
+}
+
+// skipReasonKeys 跳过原因在机器可读输出中使用的键
+var skipReasonKeys = [skipReasonCount]string{
+	SkipHidden:     "hidden",
+	SkipBinary:     "binary",
+	SkipVCS:        "vcs",
+	SkipArtifact:   "artifact",
+	SkipSystem:     "system",
+	SkipNoSpace:    "nospace",
+	SkipUnwritable: "unwritable",
+	SkipNotSampled: "unsampled",
+	SkipExcluded:   "excluded",
+	SkipMountPoint: "mountpoint",
+	SkipNetworkDir: "network",
+	SkipUnchanged:  "unchanged",
+	SkipMinified:   "minified",
+	SkipAge:        "age",
+	SkipOwner:      "owner",
+	SkipVanished:   "vanished",
+	SkipVirtualFS:  "virtual-fs",
+	SkipFiltered:   "filtered",
+	SkipBackup:     "backup",
+	SkipTooLarge:   "size",
+	SkipSymlink:    "symlink",
+}
+
+func (r SkipReason) String() string {
+	return skipReasonNames[r]
+}
+
+// Key 返回跳过原因的机器可读名称
+func (r SkipReason) Key() string {
+	return skipReasonKeys[r]
+}
+
+// countSkip 记录一次跳过
+func countSkip(result *Result, reason SkipReason) {
+	atomic.AddInt32(&result.Skipped[reason], 1)
+}
+
+// skipVanished 在 err 表示 filePath 已被删除时把它记为跳过而不是错误。
+// 活跃的工作目录中，遍历到的文件可能在处理前被清理或被编辑器原子保存替换。
+func skipVanished(config *Config, result *Result, filePath string, err error) bool {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	// The error may be about something else, such as a missing --temp-dir
+	if _, statErr := config.FS.Lstat(filePath); !errors.Is(statErr, fs.ErrNotExist) {
+		return false
+	}
+	countSkip(result, SkipVanished)
+	config.Reporter.FileSkipped(filePath, false, SkipVanished)
+	return true
+}
+
+// formatSkipped 生成跳过原因的分类统计，没有跳过时返回空字符串
+func formatSkipped(result *Result) string {
+	var parts []string
+	for reason := SkipReason(0); reason < skipReasonCount; reason++ {
+		if n := atomic.LoadInt32(&result.Skipped[reason]); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", reason, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// vcsDirNames 版本控制元数据目录，无论是否带隐藏属性都不进入
+var vcsDirNames = map[string]bool{
+	".git": true,
+	".hg":  true,
+	".svn": true,
+	".bzr": true,
+}
+
+// isVCSDir 判断目录名是否是版本控制元数据目录
+func isVCSDir(name string) bool {
+	return vcsDirNames[name]
+}
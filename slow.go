@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// phaseTimes 记录一个文件在各处理阶段的耗时
+type phaseTimes struct {
+	Detect  time.Duration // 遍历时的二进制检测
+	Scan    time.Duration // 统计匹配
+	Rewrite time.Duration // 写入临时文件并替换原文件
+}
+
+// 处理阶段
+const (
+	PhaseDetect  = "detect"
+	PhaseScan    = "scan"
+	PhaseRewrite = "rewrite"
+)
+
+var phaseNames = map[string]string{
+	PhaseDetect:  "检测",
+	PhaseScan:    "扫描",
+	PhaseRewrite: "改写",
+}
+
+// slowest 返回耗时最长的阶段
+func (p phaseTimes) slowest() string {
+	switch {
+	case p.Rewrite >= p.Scan && p.Rewrite >= p.Detect:
+		return PhaseRewrite
+	case p.Scan >= p.Detect:
+		return PhaseScan
+	}
+	return PhaseDetect
+}
+
+// SlowFile 是处理耗时超过 --slow-threshold 的文件
+type SlowFile struct {
+	Path      string `json:"path"`
+	ElapsedMs int64  `json:"elapsedMs"`
+	Phase     string `json:"phase"` // 耗时最长的阶段：detect|scan|rewrite
+	DetectMs  int64  `json:"detectMs"`
+	ScanMs    int64  `json:"scanMs"`
+	RewriteMs int64  `json:"rewriteMs"`
+}
+
+// newSlowFile 根据总耗时和各阶段耗时生成记录
+func newSlowFile(path string, elapsed time.Duration, phases phaseTimes) SlowFile {
+	return SlowFile{
+		Path:      path,
+		ElapsedMs: elapsed.Milliseconds(),
+		Phase:     phases.slowest(),
+		DetectMs:  phases.Detect.Milliseconds(),
+		ScanMs:    phases.Scan.Milliseconds(),
+		RewriteMs: phases.Rewrite.Milliseconds(),
+	}
+}
+
+// slowFiles 收集运行中的慢文件，供汇总使用
+type slowFiles struct {
+	mu    sync.Mutex
+	files []SlowFile
+}
+
+func (s *slowFiles) add(f SlowFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, f)
+}
+
+// list 按耗时从长到短返回全部慢文件
+func (s *slowFiles) list() []SlowFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := append([]SlowFile(nil), s.files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].ElapsedMs > files[j].ElapsedMs })
+	return files
+}
+
+// checkSlow 在文件耗时超过阈值时记录并立即报告
+func checkSlow(config *Config, result *Result, path string, elapsed time.Duration, phases phaseTimes) {
+	if config.SlowThreshold <= 0 || elapsed < config.SlowThreshold {
+		return
+	}
+	f := newSlowFile(path, elapsed, phases)
+	result.slow.add(f)
+	config.Reporter.FileSlow(f)
+}
+
+// formatSlowFile 输出一个慢文件及其各阶段耗时
+func formatSlowFile(f SlowFile) string {
+	ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+	return fmt.Sprintf("%v，主要耗时在%s (检测 %v, 扫描 %v, 改写 %v): %s",
+		ms(f.ElapsedMs), phaseNames[f.Phase], ms(f.DetectMs), ms(f.ScanMs), ms(f.RewriteMs), escapeControl(f.Path))
+}
+
+// formatSlowFiles 输出汇总中的慢文件列表
+func formatSlowFiles(files []SlowFile, threshold time.Duration) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n处理超过 %v 的文件（%d 个）:\n", threshold, len(files))
+	for _, f := range files {
+		fmt.Fprintf(&sb, "  %s\n", formatSlowFile(f))
+	}
+	return sb.String()
+}
+
+// timePhase 开始计时，返回的函数把经过的时间加到 d 上
+func timePhase(d *time.Duration) func() {
+	start := time.Now()
+	return func() { *d += time.Since(start) }
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// linkTracker 在 --follow-symlinks 时记录已经进入的目录和已经处理的文件。
+// 符号链接可以让同一个目录或文件出现多次，甚至形成 a -> b -> a 的环；
+// 每个目录只遍历一次、每个文件只处理一次。
+type linkTracker struct {
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string]bool
+}
+
+func newLinkTracker() *linkTracker {
+	return &linkTracker{dirs: make(map[string]bool), files: make(map[string]bool)}
+}
+
+// enterDir 记录进入目录，返回 false 表示该目录已经遍历过
+func (t *linkTracker) enterDir(path string, info fs.FileInfo) bool {
+	return t.first(t.dirs, fileKey(path, info))
+}
+
+// visitFile 记录要处理的文件，返回 false 表示已经通过另一条路径处理过
+func (t *linkTracker) visitFile(path string, info fs.FileInfo) bool {
+	return t.first(t.files, fileKey(path, info))
+}
+
+func (t *linkTracker) first(seen map[string]bool, key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// resolvedKey 以解析所有符号链接后的绝对路径标识文件，用于没有 inode 的平台
+func resolvedKey(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if isCaseInsensitiveFS() {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// considerSymlink 处理遍历中遇到的符号链接。没有 --follow-symlinks 时跳过；
+// 否则指向目录时遍历目标目录，指向文件时处理目标文件本身，
+// 替换通过重命名覆盖目标文件，链接保持不变。
+// 没有明确指定的链接先按它自己的路径和名字过滤，与它所在位置上的普通目录或文件相同，
+// 名为 .git 或被 --exclude 排除的链接不会把目标带进运行。
+func considerSymlink(config *Config, result *Result, path string, d fs.DirEntry, explicit bool, queue *workQueue) error {
+	reporter := config.Reporter
+	if config.links == nil {
+		countSkip(result, SkipSymlink)
+		reporter.FileSkipped(path, false, SkipSymlink)
+		return nil
+	}
+
+	// Dangling links and loops of links themselves (ELOOP) end here
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		countSkip(result, SkipSymlink)
+		reporter.Notice(path, fmt.Sprintf("跳过无法解析的符号链接（%v）", err))
+		return nil
+	}
+	info, err := config.FS.Lstat(target)
+	if skipVanished(config, result, path, err) {
+		return nil
+	}
+	if err != nil {
+		reporter.Error(path, fmt.Errorf("访问符号链接 %s 的目标时发生错误: %w", path, err))
+		return nil
+	}
+
+	if !explicit && skipLink(config, result, path, d, info.IsDir()) {
+		return nil
+	}
+
+	if !info.IsDir() {
+		return considerFile(config, result, target, fs.FileInfoToDirEntry(info), explicit, queue)
+	}
+	// A link to / or the home directory gets the same refusal as a root
+	if reason := dangerousDirReason(target); reason != "" && !config.Force {
+		countSkip(result, SkipSymlink)
+		reporter.Notice(path, fmt.Sprintf("跳过符号链接（目标是%s，--force 时进入）", reason))
+		return nil
+	}
+	if !config.links.enterDir(target, info) {
+		countSkip(result, SkipSymlink)
+		reporter.Notice(path, "跳过指向已遍历目录的符号链接")
+		return nil
+	}
+	return walkRoot(config, result, target, queue)
+}
+
+// skipLink 按链接自己的路径和名字应用遍历中的过滤条件，顺序与遍历相同：
+// 指向目录时与子目录一样检查 VCS 目录、--exclude、隐藏和系统属性，
+// 指向文件时与文件一样检查 --include/--exclude、隐藏和系统属性
+func skipLink(config *Config, result *Result, path string, d fs.DirEntry, dir bool) bool {
+	reporter := config.Reporter
+	if dir && isVCSDir(d.Name()) && !config.IncludeVCS {
+		countSkip(result, SkipVCS)
+		reporter.FileSkipped(path, true, SkipVCS)
+		return true
+	}
+	if (dir && config.filter.excludesDir(config.SourceDir, path)) || (!dir && !config.filter.allowsFile(config.SourceDir, path)) {
+		countSkip(result, SkipFiltered)
+		reporter.FileSkipped(path, dir, SkipFiltered)
+		return true
+	}
+
+	hidden, err := isHidden(path, d)
+	if err != nil {
+		reporter.Error(path, fmt.Errorf("检查符号链接 %s 隐藏属性时发生错误: %w", path, err))
+	}
+	if hidden {
+		countSkip(result, SkipHidden)
+		reporter.FileSkipped(path, dir, SkipHidden)
+		return true
+	}
+	return skipSystem(config, result, path, d)
+}
@@ -0,0 +1,107 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 没有明确指定的符号链接按它自己的路径和名字过滤，被过滤的链接不会把目标带进运行
+func TestSymlinkFilteredByOwnName(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		dir     bool
+		include []string
+		exclude []string
+		set     func(c *Config)
+		reason  SkipReason
+	}{
+		{"隐藏的文件链接", ".notes", false, nil, nil, nil, SkipHidden},
+		{"隐藏的目录链接", ".shared", true, nil, nil, nil, SkipHidden},
+		{"名为 .git 的目录链接", ".git", true, nil, nil, nil, SkipVCS},
+		{"名为 .hg 的目录链接且 --include-vcs", ".hg", true, nil, nil, func(c *Config) { c.IncludeVCS = true }, SkipHidden},
+		{"--exclude 排除的目录链接", "vendor", true, nil, []string{"vendor"}, nil, SkipFiltered},
+		{"--exclude 排除的文件链接", "debug.log", false, nil, []string{"*.log"}, nil, SkipFiltered},
+		{"--include 不包含的文件链接", "notes.txt", false, []string{"*.go"}, nil, nil, SkipFiltered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			src := filepath.Join(root, "src")
+			outside := filepath.Join(root, "outside")
+			for _, dir := range []string{src, outside} {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The target's own name passes every filter
+			target := writeTestFile(t, outside, "main.go", "foo\n", 0o644)
+			linkTarget := target
+			if tt.dir {
+				linkTarget = outside
+			}
+			if err := os.Symlink(linkTarget, filepath.Join(src, tt.link)); err != nil {
+				t.Fatal(err)
+			}
+			filter, err := newPathFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := &Config{SourceDir: src, SourceString: "foo", TargetString: "bar", FollowSymlinks: true, filter: filter}
+			if tt.set != nil {
+				tt.set(config)
+			}
+			result := runTest(t, config)
+			if got := result.Skipped[tt.reason]; got != 1 {
+				t.Errorf("跳过原因 %v 计数为 %d，应为 1", tt.reason, got)
+			}
+			if result.FilesFound != 0 {
+				t.Errorf("找到 %d 个文件，应为 0", result.FilesFound)
+			}
+			if got := readTestFile(t, target); got != "foo\n" {
+				t.Errorf("链接目标被修改为 %q", got)
+			}
+		})
+	}
+}
+
+// 通过过滤的链接照常跟随，目标被替换而链接保留
+func TestSymlinkFollowed(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{src, filepath.Join(outside, "lib")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := writeTestFile(t, outside, "a.txt", "foo\n", 0o644)
+	nested := writeTestFile(t, filepath.Join(outside, "lib"), "b.go", "foo\n", 0o644)
+	if err := os.Symlink(file, filepath.Join(src, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(nested), filepath.Join(src, "lib")); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := newPathFilter(nil, []string{"vendor", "*.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := runTest(t, &Config{SourceDir: src, SourceString: "foo", TargetString: "bar", FollowSymlinks: true, filter: filter})
+	if result.FilesFound != 2 {
+		t.Errorf("找到 %d 个文件，应为 2", result.FilesFound)
+	}
+	for _, path := range []string{file, nested} {
+		if got := readTestFile(t, path); got != "bar\n" {
+			t.Errorf("%s 的内容为 %q，应已替换", path, got)
+		}
+	}
+	if fi, err := os.Lstat(filepath.Join(src, "a.txt")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("链接 a.txt 应保留: %v", err)
+	}
+}
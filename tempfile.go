@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 临时文件命名规则: .reStr-<原文件名>-<随机串>.tmp
+// 独特的前缀和后缀使遍历时能可靠识别并跳过这些文件，
+// 即使它们是上一次崩溃运行遗留下来的。
+const (
+	tempFilePrefix = ".reStr-"
+	tempFileSuffix = ".tmp"
+)
+
+// createTempFile 在 tempDir 中创建临时文件；tempDir 为空时使用目标文件所在目录
+func createTempFile(fsys FileSystem, filePath, tempDir string) (TempFile, error) {
+	dir, base := filepath.Split(filePath)
+	if tempDir != "" {
+		dir = tempDir
+	}
+	return fsys.CreateTemp(dir, tempFilePrefix+base+"-*"+tempFileSuffix)
+}
+
+// discardTemp 丢弃写入失败的临时文件：能够不留名字地丢弃的（O_TMPFILE）直接丢弃，
+// 其余的关闭后删除。之后再调用 Close 不会把文件链接回目录中。
+func discardTemp(fsys FileSystem, t TempFile) {
+	if a, ok := t.(interface{ abort(FileSystem) }); ok {
+		a.abort(fsys)
+		return
+	}
+	t.Close()
+	fsys.Remove(t.Name())
+}
+
+// writeOptions 控制临时文件的写入方式
+type writeOptions struct {
+	verify bool  // 重命名前重新读取并校验（--verify-write）
+	clone  bool  // 尽量克隆原文件，只重写有替换的部分（--clone）
+	size   int64 // 预计的输出大小，不小于 preallocMin 时预先分配空间，0 为未知
+
+	keepTimes bool      // 保留原文件的访问和修改时间（--keep-times）
+	atime     time.Time // 第一次读取之前原文件的访问时间；零值时使用替换时的访问时间
+}
+
+// writeOptionsFor 从配置中取出写入选项；atime 是遍历时记下的访问时间
+func writeOptionsFor(config *Config, atime time.Time) writeOptions {
+	return writeOptions{verify: config.VerifyWrite, clone: config.Clone, keepTimes: config.KeepTimes, atime: atime}
+}
+
+// preallocMin 是预先分配临时文件空间的最小输出大小，小文件不值得多一次系统调用
+const preallocMin = 1 << 20
+
+// preallocTemp 尽量按预计的输出大小为临时文件预先分配空间，
+// 避免大文件写到一半才发现空间不足，也减少碎片。
+// 返回是否已分配；分配后文件可能已被扩展，写完后必须截断到实际大小。
+func preallocTemp(f TempFile, size int64) (bool, error) {
+	if size < preallocMin {
+		return false, nil
+	}
+	file, ok := osFile(f)
+	if !ok {
+		return false, nil
+	}
+	if err := preallocate(file, size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// commitTempFile 用写好的临时文件替换目标文件，新文件保留原文件的权限、
+// 所有者和（opts.keepTimes 时）时间，见 copyAttrs；ownerErr 是未能保留所有者的原因。
+// 临时文件位于其他文件系统（--temp-dir）时重命名会失败，此时先把内容
+// 复制到目标目录中的第二个临时文件并同步到磁盘，再在同一目录内原子重命名，
+// 原文件在任何时刻都不会处于截断状态。
+func commitTempFile(fsys FileSystem, tempPath, filePath string, opts writeOptions) (ownerErr, err error) {
+	// An original deleted while it was being rewritten stays deleted
+	orig, err := fsys.Lstat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if ownerErr, err = copyAttrs(fsys, tempPath, orig, opts); err != nil {
+			return ownerErr, err
+		}
+	}
+	err = fsys.Rename(tempPath, filePath)
+	if err == nil || !isCrossDevice(err) {
+		return ownerErr, err
+	}
+	defer fsys.Remove(tempPath)
+
+	src, err := fsys.Open(tempPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	local, err := createTempFile(fsys, filePath, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			discardTemp(fsys, local)
+		}
+	}()
+
+	if _, err = io.Copy(local, src); err != nil {
+		return nil, err
+	}
+	if syncer, ok := local.(interface{ Sync() error }); ok {
+		if err = syncer.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	if err = local.Close(); err != nil {
+		return nil, err
+	}
+	if orig != nil {
+		if ownerErr, err = copyAttrs(fsys, local.Name(), orig, opts); err != nil {
+			return ownerErr, err
+		}
+	}
+	return ownerErr, fsys.Rename(local.Name(), filePath)
+}
+
+// isTempFileName 判断文件名是否符合 reStr 临时文件的命名规则
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix) && strings.HasSuffix(name, tempFileSuffix)
+}
+
+// isStaleTempFile 判断临时文件是否已超过残留阈值。
+// 比阈值新的临时文件可能属于另一个正在运行的实例，不视为残留。
+func isStaleTempFile(fsys FileSystem, path string, maxAge time.Duration) (bool, error) {
+	info, err := fsys.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+
+	return time.Since(info.ModTime()) >= maxAge, nil
+}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTmpfile 以 O_TMPFILE 打开 dir 中的匿名文件；测试中替换它来模拟不支持 O_TMPFILE 的文件系统
+var openTmpfile = func(dir string) (int, error) {
+	return unix.Open(dir, unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+}
+
+// createTemp 用 O_TMPFILE 在 dir 中创建没有名字的临时文件，关闭时才链接到
+// 符合 pattern 的名字上。写入过程中崩溃时内核自动回收文件，目录中不会出现
+// 写了一半的临时文件。文件系统或内核不支持 O_TMPFILE 时回退到 os.CreateTemp。
+func createTemp(dir, pattern string) (TempFile, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	fd, err := openTmpfile(dir)
+	if err != nil {
+		return os.CreateTemp(dir, pattern)
+	}
+
+	name, err := unusedTempName(dir, pattern)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return &anonTempFile{File: os.NewFile(uintptr(fd), name), name: name}, nil
+}
+
+// unusedTempName 按 os.CreateTemp 的规则生成 dir 中尚不存在的文件名
+func unusedTempName(dir, pattern string) (string, error) {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	for range 10000 {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		if _, err := os.Lstat(name); errors.Is(err, os.ErrNotExist) {
+			return name, nil
+		}
+	}
+	return "", &os.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: os.ErrExist}
+}
+
+// anonTempFile 是 O_TMPFILE 创建的临时文件，Name 返回关闭时将链接到的名字
+type anonTempFile struct {
+	*os.File
+	name      string
+	linked    bool
+	discarded bool // 已经不链接地关闭，见 abort
+}
+
+func (f *anonTempFile) Name() string     { return f.name }
+func (f *anonTempFile) osFile() *os.File { return f.File }
+
+// Close 把文件链接到它的名字上再关闭，之后可以像普通临时文件一样重命名或删除
+func (f *anonTempFile) Close() error {
+	if f.linked || f.discarded {
+		return f.File.Close()
+	}
+	f.linked = true
+
+	err := linkTempFile(f.File, f.name)
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// abort 丢弃临时文件。还没有链接时只关闭文件描述符，内核随即回收它，
+// 名字从未出现在目录中，也就不会误删别人此后用这个名字创建的文件
+func (f *anonTempFile) abort(fsys FileSystem) {
+	if f.linked {
+		f.File.Close()
+		fsys.Remove(f.name)
+		return
+	}
+	f.discarded = true
+	f.File.Close()
+}
+
+// linkTempFile 给匿名文件起名。AT_EMPTY_PATH 需要 CAP_DAC_READ_SEARCH，
+// 没有权限时通过 /proc/self/fd 链接
+func linkTempFile(f *os.File, name string) error {
+	fd := int(f.Fd())
+	err := unix.Linkat(fd, "", unix.AT_FDCWD, name, unix.AT_EMPTY_PATH)
+	if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EPERM) {
+		err = unix.Linkat(unix.AT_FDCWD, "/proc/self/fd/"+strconv.Itoa(fd), unix.AT_FDCWD, name, unix.AT_SYMLINK_FOLLOW)
+	}
+	if err != nil {
+		return &os.LinkError{Op: "linkat", Old: f.Name(), New: name, Err: err}
+	}
+	return nil
+}
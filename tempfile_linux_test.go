@@ -0,0 +1,254 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 临时文件以 0600 创建；重命名替换原文件后必须保留原文件的权限
+func TestReplaceKeepsMode(t *testing.T) {
+	for _, mode := range []os.FileMode{0o644, 0o640, 0o664, 0o600} {
+		dir := t.TempDir()
+		path := writeTestFile(t, dir, "a.txt", "old text\n", mode)
+
+		if _, err := replaceTest(t, osFS{}, path, newLiteralMatcher("old", "new"), writeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != mode {
+			t.Errorf("权限 %v 替换后变为 %v", mode, got)
+		}
+		if got := readTestFile(t, path); got != "new text\n" {
+			t.Errorf("内容 = %q", got)
+		}
+		assertNoTempFiles(t, dir)
+	}
+}
+
+// crossDeviceFS 让从 tempDir 移出的重命名像跨文件系统一样以 EXDEV 失败
+type crossDeviceFS struct {
+	osFS
+	tempDir  string
+	failCopy bool // 在 tempDir 之外创建临时文件（复制回目标目录）时失败
+	refused  int  // 以 EXDEV 拒绝的重命名次数
+}
+
+func (f *crossDeviceFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	if f.failCopy && filepath.Clean(dir) != f.tempDir {
+		return nil, errInjected
+	}
+	return f.osFS.CreateTemp(dir, pattern)
+}
+
+func (f *crossDeviceFS) Rename(oldpath, newpath string) error {
+	if filepath.Dir(oldpath) == f.tempDir {
+		f.refused++
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	return f.osFS.Rename(oldpath, newpath)
+}
+
+// --temp-dir 位于其他文件系统时，内容复制到目标目录后再重命名，
+// 权限和（--keep-times 时）时间照常保留，两个目录中都不留临时文件
+func TestReplaceCrossDevice(t *testing.T) {
+	dir, tempDir := t.TempDir(), t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "old text\n", 0o640)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := &crossDeviceFS{tempDir: tempDir}
+	opts := writeOptions{keepTimes: true}
+	if _, err := replaceInFile(fsys, path, tempDir, newLiteralMatcher("old", "new"), 0, opts, &IOStats{}); err != nil {
+		t.Fatal(err)
+	}
+	if fsys.refused != 1 {
+		t.Errorf("EXDEV 重命名 %d 次，应为 1 次", fsys.refused)
+	}
+
+	if got := readTestFile(t, path); got != "new text\n" {
+		t.Errorf("内容 = %q", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0o640 {
+		t.Errorf("权限 = %v，应为 0640", got)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("修改时间 = %v，应为 %v", info.ModTime(), mtime)
+	}
+	assertNoTempFiles(t, dir)
+	assertNoTempFiles(t, tempDir)
+}
+
+// 复制回目标目录失败时原文件不变，临时文件都被删除
+func TestReplaceCrossDeviceCopyFails(t *testing.T) {
+	dir, tempDir := t.TempDir(), t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "old text\n", 0o644)
+
+	fsys := &crossDeviceFS{tempDir: tempDir, failCopy: true}
+	_, err := replaceInFile(fsys, path, tempDir, newLiteralMatcher("old", "new"), 0, writeOptions{}, &IOStats{})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("错误 = %v，应为注入的故障", err)
+	}
+	if got := readTestFile(t, path); got != "old text\n" {
+		t.Errorf("内容 = %q，应保持不变", got)
+	}
+	assertNoTempFiles(t, dir)
+	assertNoTempFiles(t, tempDir)
+}
+
+// squattingFS 在 O_TMPFILE 临时文件链接之前，抢先用它将要链接到的名字创建一个文件，
+// 模拟另一个进程恰好用了同一个名字
+type squattingFS struct {
+	osFS
+	squatted []string
+}
+
+func (f *squattingFS) CreateTemp(dir, pattern string) (TempFile, error) {
+	file, err := f.osFS.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := file.(*anonTempFile); ok {
+		if err := os.WriteFile(file.Name(), []byte("someone else's\n"), 0o644); err != nil {
+			return nil, err
+		}
+		f.squatted = append(f.squatted, file.Name())
+	}
+	return file, nil
+}
+
+// failAfterMatcher 在第一处匹配之后的行上返回错误
+type failAfterMatcher struct {
+	Matcher
+	seen bool
+}
+
+func (m *failAfterMatcher) FindAll(line string) []Match {
+	matches := m.Matcher.FindAll(line)
+	if m.seen {
+		return []Match{{Err: errInjected}}
+	}
+	m.seen = len(matches) > 0
+	return matches
+}
+
+// 替换中途失败时，O_TMPFILE 临时文件不链接就丢弃：不会按名字删除文件，
+// 同名的其他文件保持不变，目录中也不留临时文件
+func TestReplaceFailureDiscardsAnonTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "old text\nmore text\n", 0o644)
+
+	fsys := &squattingFS{}
+	matcher := &failAfterMatcher{Matcher: newLiteralMatcher("old", "new")}
+	_, err := replaceInFile(fsys, path, "", matcher, 0, writeOptions{}, &IOStats{})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("错误 = %v，应为注入的故障", err)
+	}
+	if len(fsys.squatted) == 0 {
+		t.Skip("文件系统不支持 O_TMPFILE")
+	}
+	for _, name := range fsys.squatted {
+		if got := readTestFile(t, name); got != "someone else's\n" {
+			t.Errorf("同名文件 %s 被改为 %q", filepath.Base(name), got)
+		}
+		os.Remove(name)
+	}
+	if got := readTestFile(t, path); got != "old text\nmore text\n" {
+		t.Errorf("内容 = %q，应保持不变", got)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+// discardTemp 对已经链接的临时文件按名字删除；经过编码包装的临时文件同样不链接就丢弃
+func TestDiscardTemp(t *testing.T) {
+	dir := t.TempDir()
+
+	linked, err := createTemp(dir, tempFilePrefix+"*"+tempFileSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := linked.Close(); err != nil {
+		t.Fatal(err)
+	}
+	discardTemp(osFS{}, linked)
+	assertNoTempFiles(t, dir)
+
+	fsys := encodingFS{FileSystem: osFS{}, enc: unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)}
+	encoded, err := fsys.CreateTemp(dir, tempFilePrefix+"*"+tempFileSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encoded.Write([]byte("half written")); err != nil {
+		t.Fatal(err)
+	}
+	discardTemp(fsys, encoded)
+	encoded.Close()
+	assertNoTempFiles(t, dir)
+}
+
+// 不支持 O_TMPFILE 时回退到 os.CreateTemp：替换照常完成，成功和失败后目录中都不留临时文件
+func TestCreateTempFallback(t *testing.T) {
+	defer func(open func(string) (int, error)) { openTmpfile = open }(openTmpfile)
+	calls := 0
+	openTmpfile = func(string) (int, error) {
+		calls++
+		return -1, syscall.EOPNOTSUPP
+	}
+
+	dir := t.TempDir()
+	f, err := createTemp(dir, tempFilePrefix+"*"+tempFileSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.(*os.File); !ok {
+		t.Errorf("临时文件类型为 %T，应回退为 *os.File", f)
+	}
+	// The fallback file has its name from the start
+	if _, err := os.Lstat(f.Name()); err != nil {
+		t.Errorf("回退创建的临时文件应已存在: %v", err)
+	}
+	discardTemp(osFS{}, f)
+	assertNoTempFiles(t, dir)
+
+	path := writeTestFile(t, dir, "a.txt", "old text\nmore text\n", 0o640)
+	if _, err := replaceTest(t, osFS{}, path, newLiteralMatcher("old", "new"), writeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); got != "new text\nmore text\n" {
+		t.Errorf("内容 = %q", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("权限没有保留: %v", err)
+	}
+	assertNoTempFiles(t, dir)
+
+	matcher := &failAfterMatcher{Matcher: newLiteralMatcher("new", "old")}
+	if _, err := replaceInFile(osFS{}, path, "", matcher, 0, writeOptions{}, &IOStats{}); !errors.Is(err, errInjected) {
+		t.Fatalf("错误 = %v，应为注入的故障", err)
+	}
+	if got := readTestFile(t, path); got != "new text\nmore text\n" {
+		t.Errorf("失败后内容 = %q，应保持不变", got)
+	}
+	assertNoTempFiles(t, dir)
+
+	if calls < 3 {
+		t.Errorf("O_TMPFILE 只尝试了 %d 次，应每个临时文件一次", calls)
+	}
+}
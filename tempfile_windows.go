@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// createTemp 在 dir 中创建有名字的临时文件
+func createTemp(dir, pattern string) (TempFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
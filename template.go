@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// --template-replace 把 --to 作为 text/template 模板，对每处匹配求值一次。
+// 模板中 .G0 是整个匹配，.G1、.G2 … 是编号分组，.Groups.name 是命名分组；
+// 没有参与匹配的分组为空字符串。除了内置的 printf，还可以使用下面的函数。
+var templateFuncs = template.FuncMap{
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"title":     func(s string) string { return cases.Title(language.Und).String(s) },
+	"trimSpace": strings.TrimSpace,
+}
+
+// compileReplaceTemplate 编译替换模板，并以空的分组试执行一次，
+// 使引用不存在的分组（.G9、.Groups.nope）在启动时就报错
+func compileReplaceTemplate(pattern *regexp.Regexp, text string) (*template.Template, error) {
+	tmpl, err := template.New("--to").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	empty := make([]int, 2*(pattern.NumSubexp()+1))
+	for i := range empty {
+		empty[i] = -1
+	}
+	if err := tmpl.Execute(&strings.Builder{}, templateData(pattern, "", empty)); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// templateData 返回模板求值时使用的数据：G0…Gn 和按名称索引的 Groups
+func templateData(pattern *regexp.Regexp, line string, loc []int) map[string]any {
+	data := make(map[string]any, pattern.NumSubexp()+2)
+	groups := map[string]string{}
+	for i, name := range pattern.SubexpNames() {
+		value := ""
+		if loc[2*i] >= 0 {
+			value = line[loc[2*i]:loc[2*i+1]]
+		}
+		data["G"+strconv.Itoa(i)] = value
+		if name != "" {
+			groups[name] = value
+		}
+	}
+	data["Groups"] = groups
+	return data
+}
+
+// templateMatcher 用模板生成每处正则匹配的替换文本。
+// 求值失败的匹配带有 Err，使整个文件失败而不是写入不完整的替换。
+type templateMatcher struct {
+	pattern *regexp.Regexp
+	tmpl    *template.Template
+}
+
+// FindAll 从左到右查找所有不重叠的匹配
+func (m *templateMatcher) FindAll(line string) []Match {
+	var matches []Match
+	for _, loc := range m.pattern.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		match := Match{Start: loc[0], End: loc[1]}
+		var sb strings.Builder
+		if err := m.tmpl.Execute(&sb, templateData(m.pattern, line, loc)); err != nil {
+			match.Err = fmt.Errorf("替换模板对匹配 %q 求值失败: %w", line[loc[0]:loc[1]], err)
+		}
+		match.Replacement = sb.String()
+		matches = append(matches, match)
+	}
+	return matches
+}
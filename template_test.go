@@ -0,0 +1,81 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+// 模板对每处匹配求值，分组和辅助函数都可以使用
+func TestTemplateMatcher(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		template string
+		line     string
+		want     string
+	}{
+		{`(\w+)=(\w+)`, `{{upper .G1}}={{lower .G2}}`, "key=VALUE a=B", "KEY=value A=b"},
+		{`(?P<major>\d+)\.(?P<minor>\d+)`, `{{.Groups.major}}.{{printf "%03s" .Groups.minor}}`, "v1.2 and 10.20", "v1.002 and 10.020"},
+		{`name: (.*)`, `{{title .G1}}`, "name: jane doe", "Jane Doe"},
+		{`\[(.*?)\]`, `[{{trimSpace .G1}}]`, "[  a ] [b ]", "[a] [b]"},
+		{`a(b)?c`, `<{{.G1}}>`, "ac abc", "<> <b>"},
+		{`(x)`, `{{.G0}}{{.G0}}`, "axb", "axxb"},
+		{`(é)`, `{{upper .G1}}`, "café", "cafÉ"},
+	}
+	for _, tt := range tests {
+		pattern := regexp.MustCompile(tt.pattern)
+		tmpl, err := compileReplaceTemplate(pattern, tt.template)
+		if err != nil {
+			t.Errorf("%q: %v", tt.template, err)
+			continue
+		}
+		m := &templateMatcher{pattern: pattern, tmpl: tmpl}
+		if got := applyMatches(tt.line, m.FindAll(tt.line)); got != tt.want {
+			t.Errorf("%q 作用于 %q = %q，应为 %q", tt.template, tt.line, got, tt.want)
+		}
+	}
+}
+
+// 语法错误和引用不存在的分组在编译时报错
+func TestCompileReplaceTemplate(t *testing.T) {
+	pattern := regexp.MustCompile(`(?P<name>\w+)(\d)`)
+	for _, text := range []string{
+		`{{.G1`,
+		`{{.G3}}`,
+		`{{.Groups.nope}}`,
+		`{{shout .G1}}`,
+		`{{upper}}`,
+	} {
+		if _, err := compileReplaceTemplate(pattern, text); err == nil {
+			t.Errorf("%q 应在编译时报错", text)
+		}
+	}
+	if _, err := compileReplaceTemplate(pattern, `{{.Groups.name}}{{.G2}}`); err != nil {
+		t.Errorf("有效的模板: %v", err)
+	}
+}
+
+// 求值失败时整个文件报错且保持原样，其他文件照常替换
+func TestTemplateRuntimeError(t *testing.T) {
+	dir := t.TempDir()
+	bad := writeTestFile(t, dir, "bad.txt", "id=1\nid=ab\n", 0o644)
+	good := writeTestFile(t, dir, "good.txt", "id=123456\n", 0o644)
+
+	pattern := regexp.MustCompile(`id=(\w*)`)
+	// index fails only on a group shorter than 6 bytes
+	tmpl, err := compileReplaceTemplate(pattern, `{{if .G1}}{{printf "%c" (index .G1 5)}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{SourceDir: dir, SourceString: pattern.String(), Regex: true, TemplateReplace: true, pattern: pattern, template: tmpl}
+	result := runTest(t, config)
+
+	if result.Errors != 1 {
+		t.Errorf("错误 %d 个，应为 1 个", result.Errors)
+	}
+	if got := readTestFile(t, bad); got != "id=1\nid=ab\n" {
+		t.Errorf("求值失败的文件被修改: %q", got)
+	}
+	if got := readTestFile(t, good); got != "6\n" {
+		t.Errorf("替换后 %q，应为 %q", got, "6\n")
+	}
+}
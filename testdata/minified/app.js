@@ -0,0 +1,17 @@
+// app.js is the readable source of app.min.js
+'use strict';
+
+const defaults = {
+  apiUrl: '/api/v1',
+  retries: 3,
+};
+
+function request(options, path) {
+  const apiUrl = options.apiUrl || defaults.apiUrl;
+  if (!path) {
+    return Promise.resolve(null);
+  }
+  return fetch(apiUrl + '/' + path).then((response) => response.json());
+}
+
+module.exports = { request };
@@ -0,0 +1,4 @@
+/*! app v1.4.2 | MIT */
+function t0(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t1(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t2(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t3(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t4(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t5(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t6(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t7(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t8(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t9(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t10(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t11(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t12(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t13(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t14(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t15(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t16(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t17(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t18(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t19(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};
+function t20(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t21(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t22(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t23(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t24(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t25(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t26(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t27(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t28(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t29(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t30(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t31(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t32(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t33(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t34(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t35(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t36(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t37(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t38(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t39(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};
+function t40(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t41(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t42(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t43(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t44(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t45(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t46(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t47(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t48(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t49(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t50(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t51(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t52(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t53(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t54(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t55(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t56(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t57(e,n){var r=e.apiUrl||"/api/v0";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t58(e,n){var r=e.apiUrl||"/api/v1";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};function t59(e,n){var r=e.apiUrl||"/api/v2";return n?fetch(r+"/"+n).then(function(o){return o.json()}):Promise.resolve(null)};
@@ -0,0 +1 @@
+var apiUrl="/api";
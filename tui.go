@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// tuiPreviewLines 审阅界面中每个文件最多显示的匹配行数
+const tuiPreviewLines = 200
+
+// tuiEntry 审阅列表中的一个文件
+type tuiEntry struct {
+	ev       FileEvent
+	excluded bool
+}
+
+// tuiCollector 把预扫描中有匹配的文件实时交给审阅界面
+type tuiCollector struct {
+	silentReporter
+	events chan FileEvent
+}
+
+func (r *tuiCollector) FileMatched(ev FileEvent) {
+	r.events <- ev
+}
+
+// tui 全屏审阅界面：左侧是文件列表，右侧是选中文件的差异
+type tui struct {
+	config   *Config
+	entries  []tuiEntry
+	cursor   int // 选中的文件
+	top      int // 文件列表的滚动位置
+	scroll   int // 差异的滚动位置
+	scanning bool
+	restore  *term.State
+}
+
+// reviewChanges 以试验模式扫描，并在全屏界面中逐个文件确认是否替换。
+// 扫描结果实时加入列表；用户确认后把列出的文件登记到 config.selected，
+// 正式运行只修改其中包含的文件。用户取消或没有包含任何文件时返回 ErrCanceled。
+func reviewChanges(config *Config, rules []string) error {
+	if !enableVirtualTerminal(os.Stdout) {
+		return kindErrorf(ErrSetupFailed, "--tui: 此控制台不支持 ANSI 转义序列")
+	}
+
+	ui := &tui{config: config, scanning: true}
+	if err := ui.open(); err != nil {
+		return kindErrorf(ErrSetupFailed, "无法启动审阅界面: %w", err)
+	}
+
+	collector := &tuiCollector{events: make(chan FileEvent, 64)}
+	scanErr := make(chan error, 1)
+	reportPreview := config.reportPreview
+	config.reportPreview = max(reportPreview, tuiPreviewLines)
+	go func() {
+		_, err := prescan(config, rules, collector)
+		close(collector.events)
+		scanErr <- err
+	}()
+
+	apply := ui.run(collector.events, readKeys())
+	ui.close()
+	if !apply {
+		// Let a scan that is still running finish without blocking
+		go func() {
+			for range collector.events {
+			}
+		}()
+		return kindErrorf(ErrCanceled, "已取消，未修改任何文件")
+	}
+
+	if err := <-scanErr; err != nil {
+		return kindErrorf(ErrWalkFailed, "预扫描目录时发生错误: %w", err)
+	}
+	config.reportPreview = reportPreview
+
+	config.selected = make(map[string]bool)
+	for _, e := range ui.entries {
+		config.selected[e.ev.Path] = !e.excluded
+	}
+	included := ui.included()
+	fmt.Fprintf(os.Stderr, "审阅结果: 包含 %d 个文件，排除 %d 个\n\n", included, len(ui.entries)-included)
+	if included == 0 {
+		return kindErrorf(ErrCanceled, "没有包含任何文件，未修改任何文件")
+	}
+	return nil
+}
+
+// readKeys 在后台读取终端输入并拆分为按键
+func readKeys() <-chan string {
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			for input := string(buf[:n]); input != ""; {
+				key := nextKey(input)
+				keys <- key
+				input = input[len(key):]
+			}
+		}
+	}()
+	return keys
+}
+
+// nextKey 返回输入开头的一个按键：一个字符，或一个完整的 ESC [ / ESC O 序列。
+// 单独的 ESC 是 Esc 键。
+func nextKey(input string) string {
+	if len(input) < 3 || input[0] != '\x1b' || (input[1] != '[' && input[1] != 'O') {
+		_, size := utf8.DecodeRuneInString(input)
+		return input[:size]
+	}
+	for i := 2; i < len(input); i++ {
+		if c := input[i]; c == '~' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') {
+			return input[:i+1]
+		}
+	}
+	return input
+}
+
+// open 切换到备用屏幕并进入原始模式
+func (t *tui) open() error {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	t.restore = state
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	return nil
+}
+
+// close 恢复终端
+func (t *tui) close() {
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	term.Restore(int(os.Stdin.Fd()), t.restore)
+}
+
+// run 处理扫描事件和按键，直到用户确认（返回 true）或取消。
+// 扫描结束前不能确认，避免只替换了一部分尚未列出的文件。
+func (t *tui) run(events <-chan FileEvent, keys <-chan string) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	dirty := true
+	for {
+		if dirty {
+			t.draw()
+			dirty = false
+		}
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				t.scanning = false
+			} else {
+				t.entries = append(t.entries, tuiEntry{ev: ev})
+			}
+			// Redraws during the scan are batched by the ticker
+			dirty = !t.scanning
+		case <-ticker.C:
+			dirty = t.scanning
+		case key, ok := <-keys:
+			if !ok {
+				return false
+			}
+			switch t.handleKey(key) {
+			case tuiApply:
+				return true
+			case tuiCancel:
+				return false
+			}
+			dirty = true
+		}
+	}
+}
+
+// 按键处理结果
+const (
+	tuiContinue = iota
+	tuiApply
+	tuiCancel
+)
+
+// handleKey 处理一次按键
+func (t *tui) handleKey(key string) int {
+	_, height := t.size()
+	page := max(height-3, 1)
+
+	switch key {
+	case "q", "Q", "\x1b", "\x03":
+		return tuiCancel
+	case "\r", "\n", "y", "Y":
+		if !t.scanning && len(t.entries) > 0 {
+			return tuiApply
+		}
+	case "\x1b[A", "\x1bOA", "k":
+		t.move(-1)
+	case "\x1b[B", "\x1bOB", "j":
+		t.move(1)
+	case "\x1b[H", "\x1bOH", "g":
+		t.move(-len(t.entries))
+	case "\x1b[F", "\x1bOF", "G":
+		t.move(len(t.entries))
+	case "\x1b[5~":
+		t.scroll = max(t.scroll-page, 0)
+	case "\x1b[6~":
+		t.scroll += page
+	case " ":
+		if t.cursor < len(t.entries) {
+			t.entries[t.cursor].excluded = !t.entries[t.cursor].excluded
+			t.move(1)
+		}
+	case "a", "A":
+		// Include everything unless everything is already included
+		exclude := t.included() == len(t.entries)
+		for i := range t.entries {
+			t.entries[i].excluded = exclude
+		}
+	}
+	return tuiContinue
+}
+
+// move 移动选中的文件，差异回到顶部
+func (t *tui) move(delta int) {
+	cursor := min(max(t.cursor+delta, 0), max(len(t.entries)-1, 0))
+	if cursor != t.cursor {
+		t.cursor = cursor
+		t.scroll = 0
+	}
+}
+
+// included 返回包含的文件数
+func (t *tui) included() int {
+	n := 0
+	for _, e := range t.entries {
+		if !e.excluded {
+			n++
+		}
+	}
+	return n
+}
+
+// size 返回终端的列数和行数
+func (t *tui) size() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 5 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// draw 重绘整个屏幕
+func (t *tui) draw() {
+	width, height := t.size()
+	rows := height - 2
+	listWidth := max(width*2/5, 20)
+	diffWidth := max(width-listWidth-1, 1)
+
+	// Keep the selected file visible
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if t.cursor >= t.top+rows {
+		t.top = t.cursor - rows + 1
+	}
+
+	diff := t.diffLines(diffWidth)
+	t.scroll = max(min(t.scroll, len(diff)-rows), 0)
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H")
+
+	matches := 0
+	for _, e := range t.entries {
+		if !e.excluded {
+			matches += e.ev.Matches
+		}
+	}
+	status := ""
+	if t.scanning {
+		status = "  扫描中…"
+	}
+	header := fmt.Sprintf(" reStr 审阅: %s   包含 %d/%d 个文件, %d %s%s",
+		tuiText(baseRuleName(t.config)), t.included(), len(t.entries), matches, matchUnit(t.config), status)
+	sb.WriteString("\x1b[7m" + tuiClip(header, width) + ansiReset + "\r\n")
+
+	for row := 0; row < rows; row++ {
+		i := t.top + row
+		item := ""
+		if i < len(t.entries) {
+			e := t.entries[i]
+			mark := "[x]"
+			if e.excluded {
+				mark = "[ ]"
+			}
+			item = fmt.Sprintf("%s %5d %s", mark, e.ev.Matches, tuiText(t.relPath(e.ev.Path)))
+		}
+		item = tuiClip(item, listWidth)
+		if i == t.cursor && i < len(t.entries) {
+			item = "\x1b[7m" + item + ansiReset
+		}
+		sb.WriteString(item)
+		sb.WriteString(paint("│", ansiSep, true))
+		if j := t.scroll + row; j < len(diff) {
+			sb.WriteString(diff[j])
+		}
+		sb.WriteString("\x1b[K\r\n")
+	}
+
+	help := " ↑↓/jk 选择  空格 包含/排除  a 全部  PgUp/PgDn 滚动差异  Enter 应用  q 取消"
+	switch {
+	case t.scanning:
+		help = " 扫描完成后才能应用  ↑↓/jk 选择  空格 包含/排除  q 取消"
+	case len(t.entries) == 0:
+		help = " 没有匹配的文件  q 退出"
+	}
+	sb.WriteString("\x1b[7m" + tuiClip(help, width) + ansiReset + "\x1b[J")
+
+	os.Stdout.WriteString(sb.String())
+}
+
+// diffLines 生成选中文件的差异行：原行和替换后的行，以及上下文
+func (t *tui) diffLines(width int) []string {
+	if t.cursor >= len(t.entries) {
+		return nil
+	}
+	ev := t.entries[t.cursor].ev
+
+	var lines []string
+	shown := 0
+	for _, lm := range ev.Preview {
+		prefix := fmt.Sprintf("%6d ", lm.LineNo)
+		if len(lm.Matches) == 0 {
+			lines = append(lines, tuiClip(prefix+"  "+tuiText(lm.Line), width))
+			continue
+		}
+		lines = append(lines,
+			paint(tuiClip(prefix+"- "+tuiText(lm.Line), width), ansiMatch, true),
+			paint(tuiClip(prefix+"+ "+tuiText(applyMatches(lm.Line, lm.Matches)), width), ansiReplace, true))
+		shown += len(lm.Matches)
+	}
+	if ev.Matches > shown {
+		lines = append(lines, tuiClip(fmt.Sprintf("  … 该文件还有 %d %s未显示", ev.Matches-shown, matchUnit(t.config)), width))
+	}
+	return lines
+}
+
+// relPath 列表中的路径相对于源目录显示
+func (t *tui) relPath(path string) string {
+	if rel, err := filepath.Rel(t.config.SourceDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// tuiText 转义控制字符并展开制表符，保证每个字符只占据自己的位置
+func tuiText(s string) string {
+	return strings.ReplaceAll(escapeControl(s), "\t", "    ")
+}
+
+// tuiClip 按显示宽度截断或用空格补齐到 width 列
+func tuiClip(s string, width int) string {
+	var sb strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	sb.WriteString(strings.Repeat(" ", width-used))
+	return sb.String()
+}
+
+// runeWidth 估算字符在终端中占据的列数：东亚宽字符占两列
+func runeWidth(r rune) int {
+	switch {
+	case r == utf8.RuneError:
+		return 1
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1faff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
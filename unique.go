@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// uniqueLine 统计一种不同的匹配行
+type uniqueLine struct {
+	text  string
+	files int // 包含该行的文件数
+	lines int // 出现的总行数
+}
+
+// uniqueReporter 是 find --unique-lines 的输出：不逐行输出匹配，而是把各文件中
+// 的匹配行去掉首尾空白后汇总，结束时按包含它的文件数从多到少列出前 limit 种
+type uniqueReporter struct {
+	*findReporter
+	limit int
+
+	mu    sync.Mutex
+	lines map[string]*uniqueLine
+}
+
+// newUniqueReporter 创建最多列出 limit 种行的汇总输出
+func newUniqueReporter(find *findReporter, limit int) *uniqueReporter {
+	return &uniqueReporter{findReporter: find, limit: limit, lines: make(map[string]*uniqueLine)}
+}
+
+func (r *uniqueReporter) FileMatched(ev FileEvent) {
+	seen := make(map[string]bool)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, lm := range ev.Preview {
+		if len(lm.Matches) == 0 {
+			continue
+		}
+		text := strings.TrimSpace(lm.Line)
+		u := r.lines[text]
+		if u == nil {
+			u = &uniqueLine{text: text}
+			r.lines[text] = u
+		}
+		u.lines++
+		if !seen[text] {
+			seen[text] = true
+			u.files++
+		}
+	}
+}
+
+func (r *uniqueReporter) FileReplaced(ev FileEvent) { r.FileMatched(ev) }
+
+func (r *uniqueReporter) Summary(config *Config, result *Result) {
+	r.mu.Lock()
+	sorted := make([]*uniqueLine, 0, len(r.lines))
+	for _, u := range r.lines {
+		sorted = append(sorted, u)
+	}
+	r.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.files != b.files {
+			return a.files > b.files
+		}
+		if a.lines != b.lines {
+			return a.lines > b.lines
+		}
+		return a.text < b.text
+	})
+
+	var sb strings.Builder
+	for i, u := range sorted {
+		if i == r.limit {
+			fmt.Fprintf(&sb, "（另有 %d 种不同的行未列出）\n", len(sorted)-r.limit)
+			break
+		}
+		fmt.Fprintf(&sb, "%6d 个文件 %6d 行  %s\n", u.files, u.lines, paint(escapeControl(u.text), ansiMatch, r.color))
+	}
+	r.out.Print(sb.String())
+	r.findReporter.Summary(config, result)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// writeVerifier 在写入临时文件时计算内容的哈希，重命名前重新读取临时文件
+// 并与之比较（--verify-write），发现存储上被静默截断或损坏的写入
+type writeVerifier struct {
+	hash hash.Hash
+}
+
+// newWriteVerifier 创建校验器，未启用时返回 nil，nil 校验器不做任何额外工作
+func newWriteVerifier(enabled bool) *writeVerifier {
+	if !enabled {
+		return nil
+	}
+	return &writeVerifier{hash: sha256.New()}
+}
+
+// wrap 让写入 w 的内容同时计入哈希
+func (v *writeVerifier) wrap(w io.Writer) io.Writer {
+	if v == nil {
+		return w
+	}
+	return io.MultiWriter(w, v.hash)
+}
+
+// add 把克隆中就地修补的一行（替换后的内容）计入哈希
+func (v *writeVerifier) add(line []byte, matches []Match) {
+	if v == nil {
+		return
+	}
+	last := 0
+	for _, m := range matches {
+		v.hash.Write(line[last:m.Start])
+		io.WriteString(v.hash, m.Replacement)
+		last = m.End
+	}
+	v.hash.Write(line[last:])
+}
+
+// finish 关闭临时文件；启用校验时先同步到磁盘，关闭后重新读取，
+// 确认大小为 size 且哈希与写入的内容一致
+func (v *writeVerifier) finish(fsys FileSystem, f TempFile, size int64, stats *IOStats) error {
+	if v == nil {
+		return f.Close()
+	}
+
+	if syncer, ok := f.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	file, err := stats.open(fsys, f.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	onDisk := sha256.New()
+	n, err := io.Copy(onDisk, stats.reader(file))
+	if err != nil {
+		return fmt.Errorf("校验临时文件时读取失败: %w", err)
+	}
+	if n != size {
+		return fmt.Errorf("校验失败: 临时文件 %s 为 %d 字节，应为 %d 字节，未替换原文件", f.Name(), n, size)
+	}
+	if !bytes.Equal(onDisk.Sum(nil), v.hash.Sum(nil)) {
+		return fmt.Errorf("校验失败: 临时文件 %s 的内容与写入的不一致，未替换原文件", f.Name())
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// version 在构建时通过 ldflags 注入：
+//
+//	go build -ldflags "-X main.version=v1.2.3"
+//
+// 未注入时为 dev，此时 self-update 无法判断是否有新版本。
+var version = "dev"
+
+// compareVersions 比较 v1.2.3 形式的版本号，返回 -1、0 或 1。
+// 预发布后缀（如 -rc1）被忽略；无法解析的部分按 0 处理。
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts 把版本号拆分为数字部分
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(field)
+		parts = append(parts, n)
+	}
+	return parts
+}
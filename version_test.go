@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+// 版本号按数字逐段比较，缺少的段按 0 处理，v 前缀和预发布后缀被忽略
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"1.2.3", "v1.2.3", 0},
+		{"v1.2.4", "v1.2.3", 1},
+		{"v1.2.3", "v1.3.0", -1},
+		{"v2.0.0", "v1.99.99", 1},
+		// Numeric, not lexical
+		{"v1.10.0", "v1.9.0", 1},
+		{"v1.2", "v1.2.0", 0},
+		{"v1.2", "v1.2.1", -1},
+		{"v1.2.0.1", "v1.2", 1},
+		{"v1.2.3-rc1", "v1.2.3", 0},
+		{"v1.2.4-rc1", "v1.2.3", 1},
+		{"v1.2.3+build.7", "v1.2.3", 0},
+		// Unparsable parts count as 0, so dev is older than any release
+		{"dev", "v0.0.1", -1},
+		{"v1.x.0", "v1.0.0", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d，应为 %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d，应为 %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
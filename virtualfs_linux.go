@@ -0,0 +1,40 @@
+//go:build linux
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// virtualFilesystems 是内核提供的虚拟文件系统：读取其中的文件可能阻塞或产生
+// 无意义的内容，写入更可能改变内核状态
+var virtualFilesystems = map[string]bool{
+	"proc": true, "sysfs": true, "devpts": true, "debugfs": true, "tracefs": true,
+	"securityfs": true, "cgroup": true, "cgroup2": true, "pstore": true, "bpf": true,
+	"configfs": true, "binfmt_misc": true, "mqueue": true, "hugetlbfs": true,
+	"fusectl": true, "autofs": true, "efivarfs": true, "selinuxfs": true, "nsfs": true,
+}
+
+// runtimeDirs 下的 tmpfs 也按虚拟文件系统对待：devtmpfs 与 tmpfs 的魔数相同，
+// /run 中是套接字、PID 文件等运行时状态
+var runtimeDirs = []string{"/dev", "/run"}
+
+// virtualFilesystem 判断目录是否位于虚拟文件系统上，返回文件系统类型
+func virtualFilesystem(path string) (string, bool) {
+	name, _, err := filesystemType(path)
+	if err != nil {
+		return "", false
+	}
+	if virtualFilesystems[name] {
+		return name, true
+	}
+	if name == "tmpfs" {
+		for _, dir := range runtimeDirs {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
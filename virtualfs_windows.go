@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// virtualFilesystem 在 Windows 上没有对应的虚拟文件系统
+func virtualFilesystem(path string) (string, bool) {
+	return "", false
+}
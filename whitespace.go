@@ -0,0 +1,138 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// whitespaceMode 判断是否处于不需要 --from/--to 的空白转换模式
+func whitespaceMode(config *Config) bool {
+	return config.Detab > 0 || config.Retab > 0 || config.EOL != EOLNone || config.EOLReport
+}
+
+// matchUnit 返回控制台输出中匹配数的单位
+func matchUnit(config *Config) string {
+	switch {
+	case config.EOL != EOLNone:
+		return "行换行符"
+	case whitespaceMode(config) && config.TrimTrailing:
+		return "处修改"
+	case whitespaceMode(config):
+		return "行缩进"
+	case trimOnly(config):
+		return "处行尾空白"
+	}
+	return "处字符串"
+}
+
+// indentMatcher 转换行首缩进：detab 把制表符展开为空格，retab 把空格折叠为制表符。
+// 每个发生变化的行产生一处覆盖整个缩进的匹配，行内其余内容保持不变。
+type indentMatcher struct {
+	tabWidth int
+	toTabs   bool
+}
+
+// FindAll 缩进转换前后相同的行不产生匹配
+func (m *indentMatcher) FindAll(line string) []Match {
+	end := len(line) - len(strings.TrimLeft(line, " \t"))
+	if end == 0 {
+		return nil
+	}
+
+	indent := line[:end]
+	converted := convertIndent(indent, m.tabWidth, m.toTabs)
+	if converted == indent {
+		return nil
+	}
+	return []Match{{Start: 0, End: end, Replacement: converted}}
+}
+
+// convertIndent 按制表位计算缩进宽度，再以空格或制表符加空格重新生成
+func convertIndent(indent string, tabWidth int, toTabs bool) string {
+	width := 0
+	for _, c := range indent {
+		if c == '\t' {
+			width += tabWidth - width%tabWidth
+		} else {
+			width++
+		}
+	}
+
+	if !toTabs {
+		return strings.Repeat(" ", width)
+	}
+	return strings.Repeat("\t", width/tabWidth) + strings.Repeat(" ", width%tabWidth)
+}
+
+// trimOnly 判断是否只清理行尾空白而不做字符串替换
+func trimOnly(config *Config) bool {
+	return config.TrimTrailing && !whitespaceMode(config) && config.SourceString == "" && config.TargetString == "" && config.Map == ""
+}
+
+// trailingMatcher 在内层匹配之后清理行尾的空格和制表符（不含行结束符）。
+// 内层匹配延伸到行尾空白时，替换文本末尾的空白也一并去掉，即先替换后清理。
+type trailingMatcher struct {
+	inner      Matcher // 为 nil 时只清理行尾空白
+	rule       int
+	keepBreaks bool // 保留 Markdown 用两个空格表示的换行
+}
+
+// FindAll 返回内层匹配，并在行尾有空白时追加一处删除空白的匹配
+func (m *trailingMatcher) FindAll(line string) []Match {
+	content := strings.TrimSuffix(line, "\r")
+	wsStart := len(strings.TrimRight(content, " \t"))
+
+	var matches []Match
+	if m.inner != nil {
+		matches = m.inner.FindAll(line)
+	}
+
+	start := wsStart
+	if n := len(matches); n > 0 && matches[n-1].End >= wsStart {
+		last := &matches[n-1]
+		if last.End > len(content) {
+			return matches
+		}
+		last.Replacement = strings.TrimRight(last.Replacement, " \t")
+		start = last.End
+	}
+
+	if start >= len(content) {
+		return matches
+	}
+
+	replacement := ""
+	if m.keepBreaks && start == wsStart && wsStart > 0 && isMarkdownBreak(content[wsStart:]) {
+		if content[wsStart:] == markdownBreak {
+			return matches
+		}
+		replacement = markdownBreak
+	}
+
+	return append(matches, Match{Start: start, End: len(content), Replacement: replacement, Rule: m.rule})
+}
+
+// markdownBreak 是 Markdown 中表示强制换行的行尾空白
+const markdownBreak = "  "
+
+// isMarkdownBreak 判断行尾空白是否为两个或更多空格组成的 Markdown 换行
+func isMarkdownBreak(ws string) bool {
+	return len(ws) >= len(markdownBreak) && strings.Trim(ws, " ") == ""
+}
+
+// isMarkdownFile 判断文件是否为 Markdown 文档
+func isMarkdownFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// matcherFor 返回处理指定文件时使用的匹配器
+func matcherFor(config *Config, path string) Matcher {
+	if config.mdMatcher != nil && isMarkdownFile(path) {
+		return config.mdMatcher
+	}
+	return config.matcher
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkWritable 以有效用户身份检查文件可写、所在目录可写可进入。
+// 替换时要在目录中创建临时文件并重命名，所以目录权限同样必要。
+func checkWritable(path string) error {
+	if err := unix.Faccessat(unix.AT_FDCWD, path, unix.W_OK, unix.AT_EACCESS); err != nil {
+		return fmt.Errorf("文件不可写: %w", err)
+	}
+	if err := unix.Faccessat(unix.AT_FDCWD, filepath.Dir(path), unix.W_OK|unix.X_OK, unix.AT_EACCESS); err != nil {
+		return fmt.Errorf("目录不可写: %w", err)
+	}
+	return nil
+}
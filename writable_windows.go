@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkWritable 检查文件可写、所在目录可创建临时文件。
+// Windows 的 ACL 无法从模式位判断，因此直接以写方式打开文件（不截断、不写入），
+// 并在目录中创建再删除一个临时文件。
+func checkWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("文件不可写: %w", err)
+	}
+	f.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempFilePrefix+"preflight-*"+tempFileSuffix)
+	if err != nil {
+		return fmt.Errorf("目录不可写: %w", err)
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+	return nil
+}